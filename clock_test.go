@@ -0,0 +1,37 @@
+package evdev
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFakeClock(start)
+	var fired []string
+	at := func(name string) func() {
+		return func() { fired = append(fired, name+"@"+c.Now().Sub(start).String()) }
+	}
+	c.AfterFunc(30*time.Millisecond, at("b"))
+	c.AfterFunc(10*time.Millisecond, func() {
+		at("a")()
+		c.AfterFunc(5*time.Millisecond, at("a2"))
+	})
+	stopped := c.AfterFunc(20*time.Millisecond, at("stopped"))
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop() = false first or true second")
+	}
+
+	c.Advance(20 * time.Millisecond)
+	if want := []string{"a@10ms", "a2@15ms"}; !slices.Equal(fired, want) {
+		t.Errorf("after 20ms fired %v, want %v", fired, want)
+	}
+	if got := c.Now().Sub(start); got != 20*time.Millisecond {
+		t.Errorf("Now() = start+%v, want start+20ms", got)
+	}
+	c.Advance(10 * time.Millisecond)
+	if want := []string{"a@10ms", "a2@15ms", "b@30ms"}; !slices.Equal(fired, want) {
+		t.Errorf("after 30ms fired %v, want %v", fired, want)
+	}
+}
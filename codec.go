@@ -0,0 +1,112 @@
+package evdev
+
+import (
+	"fmt"
+	"io"
+)
+
+// Decoder reads input_event records from any io.Reader — a saved capture, an
+// SSH pipe carrying a remote device's bytes, a test fixture — and decodes them
+// into InputEvents. It reads the native layout unless SetLayout selects another,
+// so captures from a different architecture decode correctly too. A Decoder is
+// an EventSource, so it can feed a Remapper directly (see NewRemapperFrom).
+type Decoder struct {
+	r      io.Reader
+	layout EventLayout
+	buf    []byte
+}
+
+// NewDecoder returns a Decoder reading native-layout records from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, layout: NativeLayout}
+}
+
+// SetLayout selects the record layout to decode, e.g. Layout32LE for a capture
+// taken on armv7 and decoded on amd64. Call it before reading.
+func (d *Decoder) SetLayout(l EventLayout) { d.layout = l }
+
+// ReadOne reads and decodes one event. It returns io.EOF at a clean end of
+// stream and io.ErrUnexpectedEOF if the stream ends partway through a record.
+func (d *Decoder) ReadOne() (InputEvent, error) {
+	size := d.layout.Size()
+	b := d.scratch(size)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return InputEvent{}, err
+	}
+	return d.layout.Decode(b), nil
+}
+
+// Read fills buf with the whole records that a single read of the underlying
+// reader yields, blocking until at least one is available, and returns the
+// count; a record split across reads is completed before returning. It mirrors
+// Device.Read and, like io.Reader, may return events along with an error.
+func (d *Decoder) Read(buf []InputEvent) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	size := d.layout.Size()
+	b := d.scratch(len(buf) * size)
+	n, err := io.ReadAtLeast(d.r, b, size)
+	if rem := n % size; rem != 0 && err == nil {
+		var m int
+		m, err = io.ReadFull(d.r, b[n:n+size-rem])
+		n += m
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	count := n / size
+	for i := range count {
+		buf[i] = d.layout.Decode(b[i*size:])
+	}
+	return count, err
+}
+
+// scratch returns a reusable buffer of n bytes.
+func (d *Decoder) scratch(n int) []byte {
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	return d.buf[:n]
+}
+
+// Encoder writes InputEvents as input_event records to any io.Writer, in the
+// native layout unless SetLayout selects another. Unlike VirtualDevice.Write it
+// keeps each event's timestamp, so a recording replays with its original
+// timing information. An Encoder is an EventSink.
+type Encoder struct {
+	w      io.Writer
+	layout EventLayout
+	buf    []byte
+}
+
+// NewEncoder returns an Encoder writing native-layout records to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, layout: NativeLayout}
+}
+
+// SetLayout selects the record layout to encode. Call it before writing.
+func (e *Encoder) SetLayout(l EventLayout) { e.layout = l }
+
+// Write encodes one event and writes it in a single call to the underlying
+// writer.
+func (e *Encoder) Write(ev InputEvent) error {
+	e.buf = e.layout.Append(e.buf[:0], ev)
+	if _, err := e.w.Write(e.buf); err != nil {
+		return fmt.Errorf("evdev: encode event: %w", err)
+	}
+	return nil
+}
+
+// WriteEvents encodes evs and writes them in a single call to the underlying
+// writer, so a frame reaches a pipe or socket as one unit.
+func (e *Encoder) WriteEvents(evs []InputEvent) error {
+	e.buf = e.buf[:0]
+	for _, ev := range evs {
+		e.buf = e.layout.Append(e.buf, ev)
+	}
+	if _, err := e.w.Write(e.buf); err != nil {
+		return fmt.Errorf("evdev: encode events: %w", err)
+	}
+	return nil
+}
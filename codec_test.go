@@ -0,0 +1,97 @@
+package evdev
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+)
+
+var codecEvents = []InputEvent{
+	{Time: timeval(1700000000, 1), Type: EV_KEY, Code: KEY_A, Value: 1},
+	{Time: timeval(1700000000, 2), Type: EV_SYN, Code: SYN_REPORT},
+	{Time: timeval(1700000000, 3), Type: EV_REL, Code: REL_X, Value: -5},
+}
+
+// TestCodecRoundTrip encodes events in each layout and decodes them back, one
+// at a time and in batches.
+func TestCodecRoundTrip(t *testing.T) {
+	for _, l := range []EventLayout{NativeLayout, Layout32LE, Layout64BE} {
+		t.Run(l.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.SetLayout(l)
+			if err := enc.Write(codecEvents[0]); err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.WriteEvents(codecEvents[1:]); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := buf.Len(), len(codecEvents)*l.Size(); got != want {
+				t.Fatalf("encoded %d bytes, want %d", got, want)
+			}
+
+			dec := NewDecoder(&buf)
+			dec.SetLayout(l)
+			first, err := dec.ReadOne()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rest := make([]InputEvent, 8)
+			n, err := dec.Read(rest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := append([]InputEvent{first}, rest[:n]...); !slices.Equal(got, codecEvents) {
+				t.Errorf("decoded %v, want %v", got, codecEvents)
+			}
+			if _, err := dec.ReadOne(); err != io.EOF {
+				t.Errorf("ReadOne at end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+// TestDecoderSplitRecords feeds the stream one byte per read: Read must still
+// return only whole records.
+func TestDecoderSplitRecords(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).WriteEvents(codecEvents); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(iotest.OneByteReader(&buf))
+	var got []InputEvent
+	evs := make([]InputEvent, 2)
+	for {
+		n, err := dec.Read(evs)
+		got = append(got, evs[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(got, codecEvents) {
+		t.Errorf("decoded %v, want %v", got, codecEvents)
+	}
+}
+
+// TestDecoderTruncated reports a record cut short as io.ErrUnexpectedEOF.
+func TestDecoderTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Write(codecEvents[0]); err != nil {
+		t.Fatal(err)
+	}
+	buf.Truncate(buf.Len() - 1)
+
+	if _, err := NewDecoder(bytes.NewReader(buf.Bytes())).ReadOne(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadOne = %v, want io.ErrUnexpectedEOF", err)
+	}
+	evs := make([]InputEvent, 1)
+	if _, err := NewDecoder(bytes.NewReader(buf.Bytes())).Read(evs); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Read = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -78,6 +78,29 @@ const (
 	ABS_MT_TOOL_Y                EvCode = 0x3d
 	ABS_MAX                      EvCode = 0x3f
 	ABS_CNT                      EvCode = 0x40
+	FF_RUMBLE                    EvCode = 0x50
+	FF_PERIODIC                  EvCode = 0x51
+	FF_CONSTANT                  EvCode = 0x52
+	FF_SPRING                    EvCode = 0x53
+	FF_FRICTION                  EvCode = 0x54
+	FF_DAMPER                    EvCode = 0x55
+	FF_INERTIA                   EvCode = 0x56
+	FF_RAMP                      EvCode = 0x57
+	FF_EFFECT_MIN                EvCode = 0x50
+	FF_EFFECT_MAX                EvCode = 0x57
+	FF_SQUARE                    EvCode = 0x58
+	FF_TRIANGLE                  EvCode = 0x59
+	FF_SINE                      EvCode = 0x5a
+	FF_SAW_UP                    EvCode = 0x5b
+	FF_SAW_DOWN                  EvCode = 0x5c
+	FF_CUSTOM                    EvCode = 0x5d
+	FF_WAVEFORM_MIN              EvCode = 0x58
+	FF_WAVEFORM_MAX              EvCode = 0x5d
+	FF_GAIN                      EvCode = 0x60
+	FF_AUTOCENTER                EvCode = 0x61
+	FF_MAX_EFFECTS               EvCode = 0x60
+	FF_MAX                       EvCode = 0x7f
+	FF_CNT                       EvCode = 0x80
 	KEY_RESERVED                 EvCode = 0x0
 	KEY_ESC                      EvCode = 0x1
 	KEY_1                        EvCode = 0x2
@@ -922,6 +945,24 @@ var evCodeNames = map[EvType]map[EvCode]string{
 		0x3c: "ABS_MT_TOOL_X",
 		0x3d: "ABS_MT_TOOL_Y",
 	},
+	EV_FF: {
+		0x50: "FF_RUMBLE",
+		0x51: "FF_PERIODIC",
+		0x52: "FF_CONSTANT",
+		0x53: "FF_SPRING",
+		0x54: "FF_FRICTION",
+		0x55: "FF_DAMPER",
+		0x56: "FF_INERTIA",
+		0x57: "FF_RAMP",
+		0x58: "FF_SQUARE",
+		0x59: "FF_TRIANGLE",
+		0x5a: "FF_SINE",
+		0x5b: "FF_SAW_UP",
+		0x5c: "FF_SAW_DOWN",
+		0x5d: "FF_CUSTOM",
+		0x60: "FF_GAIN",
+		0x61: "FF_AUTOCENTER",
+	},
 	EV_KEY: {
 		0x0:   "KEY_RESERVED",
 		0x1:   "KEY_ESC",
@@ -2376,6 +2417,25 @@ var evCodeByName = map[string]EvCode{
 	"SND_PROFILE_SILENT":           0x0,
 	"SND_PROFILE_VIBRATE":          0x1,
 	"SND_PROFILE_RING":             0x2,
+	"FF_RUMBLE":                    0x50,
+	"FF_PERIODIC":                  0x51,
+	"FF_CONSTANT":                  0x52,
+	"FF_SPRING":                    0x53,
+	"FF_FRICTION":                  0x54,
+	"FF_DAMPER":                    0x55,
+	"FF_INERTIA":                   0x56,
+	"FF_RAMP":                      0x57,
+	"FF_EFFECT_MIN":                0x50,
+	"FF_SQUARE":                    0x58,
+	"FF_TRIANGLE":                  0x59,
+	"FF_SINE":                      0x5a,
+	"FF_SAW_UP":                    0x5b,
+	"FF_SAW_DOWN":                  0x5c,
+	"FF_CUSTOM":                    0x5d,
+	"FF_WAVEFORM_MIN":              0x58,
+	"FF_GAIN":                      0x60,
+	"FF_AUTOCENTER":                0x61,
+	"FF_MAX_EFFECTS":               0x60,
 }
 
 var busNames = map[BusType]string{
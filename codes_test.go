@@ -16,6 +16,8 @@ func TestKnownConstants(t *testing.T) {
 		{"BTN_LEFT", uint16(BTN_LEFT), 0x110},
 		{"KEY_MAX", uint16(KEY_MAX), 0x2ff},
 		{"BUS_USB", uint16(BUS_USB), 0x03},
+		{"FF_RUMBLE", uint16(FF_RUMBLE), 0x50},
+		{"FF_GAIN", uint16(FF_GAIN), 0x60},
 	}
 	for _, tt := range tests {
 		if tt.got != tt.want {
@@ -31,6 +33,9 @@ func TestCodeName(t *testing.T) {
 	if got := CodeName(EV_REL, REL_X); got != "REL_X" {
 		t.Errorf("CodeName(EV_REL, REL_X) = %q, want REL_X", got)
 	}
+	if got := CodeName(EV_FF, FF_RUMBLE); got != "FF_RUMBLE" {
+		t.Errorf("CodeName(EV_FF, FF_RUMBLE) = %q, want FF_RUMBLE", got)
+	}
 	// Unknown code falls back to a typed numeric form.
 	if got := CodeName(EV_KEY, 0xfff); got != "KEY_?(0xfff)" {
 		t.Errorf("CodeName fallback = %q, want KEY_?(0xfff)", got)
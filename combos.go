@@ -0,0 +1,220 @@
+package evdev
+
+import (
+	"slices"
+	"time"
+)
+
+// Combos is a Mapper for key combos: keys pressed together, within a short
+// window, that emit something else — J and K for Escape, or the multi-key
+// chords of stenography-style input. Presses of keys that are part of a combo
+// are held back until the keys pressed so far match a combo, cannot become
+// one, another key is pressed, one of them is released, or the window runs
+// out; if they form no combo, they are replayed in order as typed. Releases of
+// other keys pass meanwhile. The window is timed on the Remapper's clock.
+//
+//	c := evdev.NewCombos(evdev.WithComboWindow(30 * time.Millisecond))
+//	c.Add([]evdev.EvCode{evdev.KEY_J, evdev.KEY_K}, evdev.KEY_ESC)
+//	r, err := evdev.NewRemapper(src, nil, append(c.RemapOptions(), evdev.WithMapper(c))...)
+//
+// A combo's output is held down until the first of its keys is released;
+// releasing the rest then emits nothing, so every press emitted is released
+// exactly once. Configure a Combos before using it, and use it for one
+// Remapper.
+type Combos struct {
+	window time.Duration
+	combos []combo
+	keys   map[EvCode]bool // keys in any combo
+
+	buf   []EvCode           // combo keys pressed and held back, in order
+	timer Timer              // running out the window for buf
+	gen   int                // bumped when buf is decided; older timers do nothing
+	held  map[EvCode]*firing // keys pressed for a combo that fired
+}
+
+// DefaultComboWindow is how close together a combo's keys must be pressed,
+// unless WithComboWindow says otherwise.
+const DefaultComboWindow = 50 * time.Millisecond
+
+// CombosOption configures a Combos.
+type CombosOption func(*Combos)
+
+// WithComboWindow sets how long after the first of a combo's keys the rest
+// may be pressed.
+func WithComboWindow(d time.Duration) CombosOption {
+	return func(c *Combos) { c.window = d }
+}
+
+type combo struct {
+	keys []EvCode // sorted
+	out  []EvCode
+}
+
+// firing is a combo whose output is down, or was until on turned false.
+type firing struct {
+	out []EvCode
+	on  bool
+}
+
+// NewCombos returns a Combos with no combos, which passes every event through.
+func NewCombos(opts ...CombosOption) *Combos {
+	c := &Combos{window: DefaultComboWindow, keys: map[EvCode]bool{}, held: map[EvCode]*firing{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Add makes keys, pressed together in any order, a combo that presses out —
+// one key, or a chord such as Ctrl and C, pressed in order — instead. When
+// the keys of one combo are part of a larger one, it waits for the window to
+// run out, or one of the keys to be released, before deciding.
+func (c *Combos) Add(keys []EvCode, out ...EvCode) {
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	for _, k := range keys {
+		c.keys[k] = true
+	}
+	c.combos = append(c.combos, combo{keys: keys, out: slices.Clone(out)})
+}
+
+// RemapOptions returns the NewRemapper options registering the keys the
+// combos emit, which the source may lack.
+func (c *Combos) RemapOptions() []RemapOption {
+	var keys []EvCode
+	for _, cb := range c.combos {
+		keys = append(keys, cb.out...)
+	}
+	return []RemapOption{WithExtraKeys(keys...)}
+}
+
+// Map implements Mapper.
+func (c *Combos) Map(ctx MapContext, ev InputEvent) {
+	ctx.Emit(frames(c.process(ctx, ev))...)
+}
+
+// process handles one event, returning the key changes to emit.
+func (c *Combos) process(ctx MapContext, ev InputEvent) []InputEvent {
+	if ev.Type != EV_KEY {
+		return []InputEvent{ev}
+	}
+	buffered := slices.Contains(c.buf, ev.Code)
+	switch {
+	case ev.Value == 1 && c.keys[ev.Code]:
+		var out []InputEvent
+		if len(c.buf) > 0 && !c.viable(append(slices.Clone(c.buf), ev.Code)) {
+			out = c.decide()
+		}
+		return append(out, c.hold(ctx, ev.Code)...)
+	case ev.Value == 1:
+		// Another key: what was held back came first.
+		return append(c.decide(), ev)
+	case ev.Value == 0 && buffered:
+		return append(c.decide(), c.release(ev.Code)...)
+	case ev.Value == 0:
+		return c.release(ev.Code)
+	case buffered:
+		return nil // repeats of a key held back
+	}
+	if _, ok := c.held[ev.Code]; ok {
+		return nil // repeats of a combo's key
+	}
+	return []InputEvent{ev}
+}
+
+// hold holds back a press of a combo key, deciding at once if the keys held
+// back match a combo no larger one could still become.
+func (c *Combos) hold(ctx MapContext, code EvCode) []InputEvent {
+	c.buf = append(c.buf, code)
+	if len(c.buf) == 1 {
+		gen := c.gen
+		c.timer = ctx.AfterFunc(c.window, func() {
+			if c.gen == gen {
+				ctx.Emit(frames(c.decide())...)
+			}
+		})
+	}
+	if c.match(c.buf) != nil && !c.extends(c.buf) {
+		return c.decide()
+	}
+	return nil
+}
+
+// decide fires the combo the keys held back match, or replays them.
+func (c *Combos) decide() []InputEvent {
+	if len(c.buf) == 0 {
+		return nil
+	}
+	buf := c.buf
+	c.buf = nil
+	c.timer.Stop()
+	c.gen++
+
+	var out []InputEvent
+	if cb := c.match(buf); cb != nil {
+		f := &firing{out: cb.out, on: true}
+		for _, k := range buf {
+			c.held[k] = f
+		}
+		for _, k := range cb.out {
+			out = append(out, keyEv(k, 1))
+		}
+		return out
+	}
+	for _, k := range buf {
+		out = append(out, keyEv(k, 1))
+	}
+	return out
+}
+
+// release handles a key's release once it is not held back.
+func (c *Combos) release(code EvCode) []InputEvent {
+	f, ok := c.held[code]
+	if !ok {
+		return []InputEvent{keyEv(code, 0)}
+	}
+	delete(c.held, code)
+	if !f.on {
+		return nil
+	}
+	f.on = false
+	var out []InputEvent
+	for _, k := range slices.Backward(f.out) {
+		out = append(out, keyEv(k, 0))
+	}
+	return out
+}
+
+// match returns the combo whose keys are exactly keys, or nil.
+func (c *Combos) match(keys []EvCode) *combo {
+	set := slices.Sorted(slices.Values(keys))
+	for i := range c.combos {
+		if slices.Equal(c.combos[i].keys, set) {
+			return &c.combos[i]
+		}
+	}
+	return nil
+}
+
+// viable reports whether keys are all part of one combo.
+func (c *Combos) viable(keys []EvCode) bool {
+	return slices.ContainsFunc(c.combos, func(cb combo) bool { return hasAll(cb.keys, keys) })
+}
+
+// extends reports whether a combo has keys and more.
+func (c *Combos) extends(keys []EvCode) bool {
+	return slices.ContainsFunc(c.combos, func(cb combo) bool {
+		return len(cb.keys) > len(keys) && hasAll(cb.keys, keys)
+	})
+}
+
+// hasAll reports whether every key of sub is in set.
+func hasAll(set, sub []EvCode) bool {
+	for _, k := range sub {
+		if !slices.Contains(set, k) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package evdev
+
+import (
+	"testing"
+	"time"
+)
+
+func newCombosHarness(t *testing.T) *mapperHarness {
+	c := NewCombos(WithComboWindow(30 * time.Millisecond))
+	c.Add([]EvCode{KEY_J, KEY_K}, KEY_ESC)
+	c.Add([]EvCode{KEY_J, KEY_K, KEY_L}, KEY_LEFTCTRL, KEY_C)
+	c.Add([]EvCode{KEY_D, KEY_F}, KEY_TAB)
+	return newMapperHarness(t, c)
+}
+
+func TestCombos(t *testing.T) {
+	h := newCombosHarness(t)
+
+	// D and F make only Tab: fired at once, held until the first release.
+	h.key(KEY_F, 1)
+	h.wait(10)
+	h.key(KEY_D, 1)
+	h.expect("KEY_TAB 1")
+	h.key(KEY_D, 2)
+	h.key(KEY_F, 0)
+	h.expect("KEY_TAB 0")
+	h.key(KEY_D, 0)
+	h.expect()
+
+	// J and K could still become J, K and L: decided by the window...
+	h.key(KEY_J, 1)
+	h.key(KEY_K, 1)
+	h.wait(29)
+	h.expect()
+	h.wait(1)
+	h.expect("KEY_ESC 1")
+	h.key(KEY_J, 0)
+	h.key(KEY_K, 0)
+	h.expect("KEY_ESC 0")
+
+	// ...or a release.
+	h.key(KEY_K, 1)
+	h.key(KEY_J, 1)
+	h.key(KEY_K, 0)
+	h.expect("KEY_ESC 1", "KEY_ESC 0")
+	h.key(KEY_J, 0)
+	h.expect()
+
+	// L completes the larger combo, whose chord is pressed in order.
+	h.key(KEY_J, 1)
+	h.key(KEY_L, 1)
+	h.key(KEY_K, 1)
+	h.expect("KEY_LEFTCTRL 1", "KEY_C 1")
+	h.key(KEY_L, 0)
+	h.expect("KEY_C 0", "KEY_LEFTCTRL 0")
+	h.key(KEY_J, 0)
+	h.key(KEY_K, 0)
+	h.expect()
+}
+
+// TestCombosReplay checks keys that form no combo come out as typed, each
+// release after its press.
+func TestCombosReplay(t *testing.T) {
+	h := newCombosHarness(t)
+
+	// The window runs out.
+	h.key(KEY_J, 1)
+	h.key(KEY_J, 2)
+	h.wait(30)
+	h.expect("KEY_J 1")
+	h.key(KEY_J, 2)
+	h.key(KEY_J, 0)
+	h.expect("KEY_J 2", "KEY_J 0")
+
+	// Another key is pressed.
+	h.key(KEY_J, 1)
+	h.key(KEY_A, 1)
+	h.expect("KEY_J 1", "KEY_A 1")
+	h.key(KEY_A, 0)
+	h.key(KEY_J, 0)
+	h.expect("KEY_A 0", "KEY_J 0")
+
+	// A key of another combo is pressed: J is replayed, D held back, even
+	// past J's release.
+	h.key(KEY_J, 1)
+	h.key(KEY_D, 1)
+	h.expect("KEY_J 1")
+	h.key(KEY_J, 0)
+	h.expect("KEY_J 0")
+	h.key(KEY_D, 0)
+	h.expect("KEY_D 1", "KEY_D 0")
+
+	// The key held back is released first.
+	h.key(KEY_K, 1)
+	h.key(KEY_K, 0)
+	h.expect("KEY_K 1", "KEY_K 0")
+	h.wait(30)
+	h.expect()
+}
@@ -0,0 +1,617 @@
+package evdev
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Config is a remapping described in a JSON file rather than Go code, so a
+// mapping can change without rebuilding the program that applies it. Load one
+// with LoadConfig or ParseConfig, pick the device with Matches, and pass
+// MapFunc and RemapOptions to NewRemapper:
+//
+//	{
+//	  "name": "desk keyboard",
+//	  "match": {"name": "*Keyboard*", "bus": "BUS_USB", "vendor": "0x046d"},
+//	  "swap": [["KEY_CAPSLOCK", "KEY_ESC"]],
+//	  "keys": {
+//	    "BTN_SIDE": "KEY_BACK",
+//	    "KEY_F13": ["KEY_LEFTCTRL", "KEY_C"],
+//	    "KEY_INSERT": null
+//	  },
+//	  "axes": {
+//	    "REL_WHEEL": {"scale": -1},
+//	    "REL_X": {"to": "REL_Y", "scale": 0.5}
+//	  }
+//	}
+//
+// "keys" maps a key or button to another, to a combo pressed in order and
+// released in reverse, or to null to drop it; "swap" is shorthand for a pair of
+// keys entries. "axes" moves a relative or absolute axis to another of the same
+// type ("to") and multiplies its values ("scale", default 1). Codes are written
+// by name, as TypedCode parses them. Everything not mentioned passes through.
+type Config struct {
+	// Name is the virtual device's name, if set.
+	Name string
+	// Match selects the devices the mapping is meant for.
+	Match DeviceMatch
+
+	keys map[EvCode][]EvCode // nil to drop
+	axes map[TypedCode]axisMap
+}
+
+// DeviceMatch selects devices by identity. Name and Phys are glob patterns, in
+// which * matches any run of characters, '/' included, ? any one character,
+// and [...] a character class as in path.Match; zero fields match anything.
+type DeviceMatch struct {
+	Name, Phys      string
+	Bus             BusType
+	Vendor, Product uint16
+}
+
+// axisMap is one "axes" entry.
+type axisMap struct {
+	to    EvCode
+	scale float64
+}
+
+// LoadConfig reads and parses the config file at path. Errors name the file
+// and line.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("evdev: read config: %w", err)
+	}
+	return parseConfig(data, path)
+}
+
+// ParseConfig parses a config from r. Errors name the offending line.
+func ParseConfig(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("evdev: read config: %w", err)
+	}
+	return parseConfig(data, "config")
+}
+
+// Matches reports whether d is a device c.Match selects.
+func (c *Config) Matches(d *Device) (bool, error) {
+	m := c.Match
+	if m.Name != "" {
+		name, err := d.Name()
+		if err != nil {
+			return false, err
+		}
+		if ok, err := matchGlob(m.Name, name); !ok {
+			return false, err
+		}
+	}
+	if m.Phys != "" {
+		// A device without a phys fails EVIOCGPHYS, and matches no pattern.
+		phys, _ := d.Phys()
+		if ok, err := matchGlob(m.Phys, phys); !ok {
+			return false, err
+		}
+	}
+	if m.Bus == 0 && m.Vendor == 0 && m.Product == 0 {
+		return true, nil
+	}
+	id, err := d.ID()
+	if err != nil {
+		return false, err
+	}
+	return (m.Bus == 0 || id.BusType == m.Bus) &&
+		(m.Vendor == 0 || id.Vendor == m.Vendor) &&
+		(m.Product == 0 || id.Product == m.Product), nil
+}
+
+// matchGlob reports whether s matches the DeviceMatch pattern.
+func matchGlob(pattern, s string) (bool, error) {
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// compileGlob translates a DeviceMatch pattern to a regexp. Unlike path.Match,
+// it lets * match '/', which phys paths such as "usb-0000:00:14.0-1/input0"
+// contain.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '\\':
+			i++
+			if i == len(pattern) {
+				return nil, fmt.Errorf("evdev: pattern %q ends in a backslash", pattern)
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("evdev: pattern %q has an unclosed [", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			b.WriteString("[")
+			if strings.HasPrefix(class, "^") {
+				b.WriteString("^")
+				class = class[1:]
+			}
+			for _, r := range class {
+				if r == '-' {
+					b.WriteRune(r)
+				} else {
+					b.WriteString(regexp.QuoteMeta(string(r)))
+				}
+			}
+			b.WriteString("]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("evdev: pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// MapFunc compiles the mapping. Scaled relative axes carry fractional
+// remainders from event to event, so each call returns a MapFunc with its own
+// state, for one Remapper. Combos ignore key repeats.
+func (c *Config) MapFunc() MapFunc {
+	remainders := map[TypedCode]float64{}
+	return func(ev InputEvent) []InputEvent {
+		switch ev.Type {
+		case EV_KEY:
+			out, ok := c.keys[ev.Code]
+			if !ok {
+				break
+			}
+			switch {
+			case len(out) == 1:
+				ev.Code = out[0]
+				return []InputEvent{ev}
+			case len(out) == 0 || ev.Value == 2:
+				return nil
+			}
+			return comboEvents(out, ev.Value)
+		case EV_REL, EV_ABS:
+			tc := TypedCode{ev.Type, ev.Code}
+			a, ok := c.axes[tc]
+			if !ok {
+				break
+			}
+			v := float64(ev.Value) * a.scale
+			ev.Code = a.to
+			if ev.Type == EV_ABS {
+				ev.Value = int32(math.Round(v))
+				return []InputEvent{ev}
+			}
+			v += remainders[tc]
+			ev.Value = int32(v)
+			remainders[tc] = v - float64(ev.Value)
+			if ev.Value == 0 {
+				return nil
+			}
+			return []InputEvent{ev}
+		}
+		return []InputEvent{ev}
+	}
+}
+
+// comboEvents presses keys in order or releases them in reverse, one frame
+// per key so each is seen in turn.
+func comboEvents(keys []EvCode, value int32) []InputEvent {
+	evs := make([]InputEvent, len(keys))
+	for i, k := range keys {
+		if value == 0 {
+			i = len(keys) - 1 - i
+		}
+		evs[i] = InputEvent{Type: EV_KEY, Code: k, Value: value}
+	}
+	return frames(evs)
+}
+
+// RemapOptions returns the NewRemapper options the mapping needs: its Name,
+// and the keys and axes it emits, which the source may lack. An absolute axis
+// moved to one the source lacks is registered with the range of the axis
+// moved.
+func (c *Config) RemapOptions() []RemapOption {
+	var opts []RemapOption
+	if c.Name != "" {
+		opts = append(opts, WithName(c.Name))
+	}
+	var extra Capabilities
+	for _, out := range c.keys {
+		extra.Keys = append(extra.Keys, out...)
+	}
+	var moves []absMove
+	for from, a := range c.axes {
+		switch {
+		case from.Type == EV_REL:
+			extra.Rels = append(extra.Rels, a.to)
+		case a.to != from.Code:
+			moves = append(moves, absMove{from: from.Code, to: a.to})
+		}
+	}
+	slices.SortFunc(moves, func(a, b absMove) int { return cmp.Compare(a.to, b.to) })
+	opts = append(opts, func(o *remapOptions) { o.absMoves = append(o.absMoves, moves...) })
+	return append(opts, WithExtraCapabilities(extra))
+}
+
+// parseConfig parses data, naming it src in errors.
+func parseConfig(data []byte, src string) (*Config, error) {
+	p := &configParser{src: src, data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	p.dec.UseNumber()
+	c := &Config{keys: map[EvCode][]EvCode{}, axes: map[TypedCode]axisMap{}}
+	if err := p.parse(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// configParser decodes a config into a tree of jsonValues that remember their
+// lines, then interprets it.
+type configParser struct {
+	src  string
+	data []byte
+	dec  *json.Decoder
+
+	keyLines map[EvCode]int // where each source key was mapped
+}
+
+// jsonValue is a decoded JSON value and the line it is on. val is a string,
+// json.Number, bool, nil, []jsonValue or []jsonMember.
+type jsonValue struct {
+	line int
+	val  any
+}
+
+// jsonMember is an object member; members stay in file order.
+type jsonMember struct {
+	key string
+	jsonValue
+}
+
+func (p *configParser) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("evdev: %s line %d: %s", p.src, line, fmt.Sprintf(format, args...))
+}
+
+// lineAt returns the line containing byte offset off.
+func (p *configParser) lineAt(off int64) int {
+	return bytes.Count(p.data[:min(off, int64(len(p.data)))], []byte("\n")) + 1
+}
+
+// token reads the next token and the line it ends on, which for JSON tokens is
+// also the line it starts on.
+func (p *configParser) token() (json.Token, int, error) {
+	tok, err := p.dec.Token()
+	if err != nil {
+		var se *json.SyntaxError
+		switch {
+		case errors.As(err, &se):
+			return nil, 0, p.errorf(p.lineAt(se.Offset), "%v", se)
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, 0, p.errorf(p.lineAt(int64(len(p.data))), "unexpected end of config")
+		}
+		return nil, 0, fmt.Errorf("evdev: %s: %w", p.src, err)
+	}
+	return tok, p.lineAt(p.dec.InputOffset()), nil
+}
+
+// value reads one value, recursively.
+func (p *configParser) value() (jsonValue, error) {
+	tok, line, err := p.token()
+	if err != nil {
+		return jsonValue{}, err
+	}
+	switch tok {
+	case json.Delim('['):
+		var elems []jsonValue
+		for p.dec.More() {
+			v, err := p.value()
+			if err != nil {
+				return jsonValue{}, err
+			}
+			elems = append(elems, v)
+		}
+		_, _, err := p.token() // ]
+		return jsonValue{line, elems}, err
+	case json.Delim('{'):
+		members := []jsonMember{}
+		for p.dec.More() {
+			key, _, err := p.token()
+			if err != nil {
+				return jsonValue{}, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return jsonValue{}, err
+			}
+			members = append(members, jsonMember{key.(string), v})
+		}
+		_, _, err := p.token() // }
+		return jsonValue{line, members}, err
+	}
+	return jsonValue{line, tok}, nil
+}
+
+func (p *configParser) parse(c *Config) error {
+	root, err := p.value()
+	if err != nil {
+		return err
+	}
+	if p.dec.More() {
+		_, line, err := p.token()
+		if err != nil {
+			return err
+		}
+		return p.errorf(line, "unexpected data after the config object")
+	}
+	top, err := p.object(root, "config")
+	if err != nil {
+		return err
+	}
+	p.keyLines = map[EvCode]int{}
+	for _, m := range top {
+		switch m.key {
+		case "name":
+			c.Name, err = p.str(m.jsonValue, "name")
+		case "match":
+			err = p.match(m.jsonValue, &c.Match)
+		case "swap":
+			err = p.swap(m.jsonValue, c)
+		case "keys":
+			err = p.keys(m.jsonValue, c)
+		case "axes":
+			err = p.axes(m.jsonValue, c)
+		default:
+			err = p.errorf(m.line, "unknown field %q", m.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *configParser) object(v jsonValue, what string) ([]jsonMember, error) {
+	members, ok := v.val.([]jsonMember)
+	if !ok {
+		return nil, p.errorf(v.line, "%s must be an object", what)
+	}
+	return members, nil
+}
+
+func (p *configParser) array(v jsonValue, what string) ([]jsonValue, error) {
+	elems, ok := v.val.([]jsonValue)
+	if !ok {
+		return nil, p.errorf(v.line, "%s must be an array", what)
+	}
+	return elems, nil
+}
+
+func (p *configParser) str(v jsonValue, what string) (string, error) {
+	s, ok := v.val.(string)
+	if !ok {
+		return "", p.errorf(v.line, "%s must be a string", what)
+	}
+	return s, nil
+}
+
+// code parses a code name of type t.
+func (p *configParser) code(v jsonValue, t EvType) (EvCode, error) {
+	s, err := p.str(v, "code")
+	if err != nil {
+		return 0, err
+	}
+	tc, err := ParseTypedCode(s)
+	if err != nil {
+		return 0, p.errorf(v.line, "unknown code %q", s)
+	}
+	if tc.Type != t {
+		return 0, p.errorf(v.line, "%s is not an %s code", s, t)
+	}
+	return tc.Code, nil
+}
+
+// id16 parses a vendor or product id, a number or a string such as "0x046d".
+func (p *configParser) id16(v jsonValue, what string) (uint16, error) {
+	var s string
+	switch x := v.val.(type) {
+	case json.Number:
+		s = x.String()
+	case string:
+		s = x
+	default:
+		return 0, p.errorf(v.line, "%s must be a number", what)
+	}
+	n, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, p.errorf(v.line, "invalid %s %q", what, s)
+	}
+	return uint16(n), nil
+}
+
+func (p *configParser) match(v jsonValue, m *DeviceMatch) error {
+	members, err := p.object(v, "match")
+	if err != nil {
+		return err
+	}
+	for _, f := range members {
+		switch f.key {
+		case "name", "phys":
+			s, err := p.str(f.jsonValue, f.key)
+			if err != nil {
+				return err
+			}
+			if _, err := compileGlob(s); err != nil {
+				return p.errorf(f.line, "invalid %s pattern %q", f.key, s)
+			}
+			if f.key == "name" {
+				m.Name = s
+			} else {
+				m.Phys = s
+			}
+		case "bus":
+			s, err := p.str(f.jsonValue, "bus")
+			if err != nil {
+				return err
+			}
+			if err := m.Bus.UnmarshalText([]byte(s)); err != nil {
+				return p.errorf(f.line, "unknown bus %q", s)
+			}
+		case "vendor":
+			m.Vendor, err = p.id16(f.jsonValue, "vendor")
+		case "product":
+			m.Product, err = p.id16(f.jsonValue, "product")
+		default:
+			err = p.errorf(f.line, "unknown match field %q", f.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapKey records from -> out, rejecting a key mapped twice.
+func (p *configParser) mapKey(c *Config, line int, from EvCode, out []EvCode) error {
+	if prev, ok := p.keyLines[from]; ok {
+		return p.errorf(line, "%s is already mapped on line %d", CodeName(EV_KEY, from), prev)
+	}
+	p.keyLines[from] = line
+	c.keys[from] = out
+	return nil
+}
+
+func (p *configParser) swap(v jsonValue, c *Config) error {
+	pairs, err := p.array(v, "swap")
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		keys, err := p.array(pair, "a swap")
+		if err != nil {
+			return err
+		}
+		if len(keys) != 2 {
+			return p.errorf(pair.line, "a swap must name two keys")
+		}
+		a, err := p.code(keys[0], EV_KEY)
+		if err != nil {
+			return err
+		}
+		b, err := p.code(keys[1], EV_KEY)
+		if err != nil {
+			return err
+		}
+		if err := p.mapKey(c, pair.line, a, []EvCode{b}); err != nil {
+			return err
+		}
+		if err := p.mapKey(c, pair.line, b, []EvCode{a}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *configParser) keys(v jsonValue, c *Config) error {
+	members, err := p.object(v, "keys")
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		from, err := p.code(jsonValue{m.line, m.key}, EV_KEY)
+		if err != nil {
+			return err
+		}
+		var out []EvCode
+		switch x := m.val.(type) {
+		case nil:
+		case []jsonValue:
+			if len(x) == 0 {
+				return p.errorf(m.line, "empty combo for %s; use null to drop a key", m.key)
+			}
+			for _, e := range x {
+				k, err := p.code(e, EV_KEY)
+				if err != nil {
+					return err
+				}
+				out = append(out, k)
+			}
+		default:
+			k, err := p.code(m.jsonValue, EV_KEY)
+			if err != nil {
+				return err
+			}
+			out = []EvCode{k}
+		}
+		if err := p.mapKey(c, m.line, from, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *configParser) axes(v jsonValue, c *Config) error {
+	members, err := p.object(v, "axes")
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		from, err := ParseTypedCode(m.key)
+		if err != nil {
+			return p.errorf(m.line, "unknown code %q", m.key)
+		}
+		if from.Type != EV_REL && from.Type != EV_ABS {
+			return p.errorf(m.line, "%s is not an axis", m.key)
+		}
+		if _, ok := c.axes[from]; ok {
+			return p.errorf(m.line, "%s is mapped twice", m.key)
+		}
+		fields, err := p.object(m.jsonValue, m.key)
+		if err != nil {
+			return err
+		}
+		a := axisMap{to: from.Code, scale: 1}
+		for _, f := range fields {
+			switch f.key {
+			case "to":
+				a.to, err = p.code(f.jsonValue, from.Type)
+			case "scale":
+				n, ok := f.val.(json.Number)
+				if !ok {
+					return p.errorf(f.line, "scale must be a number")
+				}
+				a.scale, err = n.Float64()
+			default:
+				err = p.errorf(f.line, "unknown axis field %q", f.key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		c.axes[from] = a
+	}
+	return nil
+}
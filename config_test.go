@@ -0,0 +1,150 @@
+package evdev
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+  "name": "desk keyboard",
+  "match": {"name": "*Keyboard*", "bus": "BUS_USB", "vendor": "0x046d", "product": 49944},
+  "swap": [["KEY_CAPSLOCK", "KEY_ESC"]],
+  "keys": {
+    "BTN_SIDE": "KEY_BACK",
+    "KEY_F13": ["KEY_LEFTCTRL", "KEY_LEFTSHIFT", "KEY_C"],
+    "KEY_INSERT": null
+  },
+  "axes": {
+    "REL_WHEEL": {"scale": -1},
+    "REL_X": {"to": "REL_Y", "scale": 0.5},
+    "ABS_X": {"scale": 1.5},
+    "ABS_Y": {"to": "ABS_RY"}
+  }
+}
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DeviceMatch{Name: "*Keyboard*", Bus: BUS_USB, Vendor: 0x046d, Product: 49944}
+	if c.Name != "desk keyboard" || c.Match != want {
+		t.Errorf("Name, Match = %q, %+v; want desk keyboard, %+v", c.Name, c.Match, want)
+	}
+
+	var o remapOptions
+	for _, opt := range c.RemapOptions() {
+		opt(&o)
+	}
+	slices.Sort(o.extra.Keys)
+	wantKeys := []EvCode{KEY_ESC, KEY_C, KEY_LEFTCTRL, KEY_CAPSLOCK, KEY_LEFTSHIFT, KEY_BACK}
+	slices.Sort(wantKeys)
+	if o.name != "desk keyboard" || !slices.Equal(o.extra.Keys, wantKeys) {
+		t.Errorf("RemapOptions gave name %q, keys %v; want %v", o.name, o.extra.Keys, wantKeys)
+	}
+	if slices.Sort(o.extra.Rels); !slices.Equal(o.extra.Rels, []EvCode{REL_Y, REL_WHEEL}) {
+		t.Errorf("RemapOptions gave rels %v, want [REL_Y REL_WHEEL]", o.extra.Rels)
+	}
+
+	// ABS_RY is registered with ABS_Y's range, where the source lacks it.
+	x := AbsAxis{Code: ABS_X, AbsInfo: AbsInfo{Maximum: 1023}}
+	y := AbsAxis{Code: ABS_Y, AbsInfo: AbsInfo{Maximum: 767}}
+	got := moveAbs([]AbsAxis{x, y}, o.absMoves)
+	if want := []AbsAxis{x, y, {Code: ABS_RY, AbsInfo: y.AbsInfo}}; !slices.Equal(got, want) {
+		t.Errorf("absolute axes = %v, want %v", got, want)
+	}
+	ry := AbsAxis{Code: ABS_RY, AbsInfo: AbsInfo{Maximum: 255}}
+	if got := moveAbs([]AbsAxis{y, ry}, o.absMoves); len(got) != 2 {
+		t.Errorf("absolute axes with ABS_RY present = %v, want it kept", got)
+	}
+}
+
+func TestConfigMapFunc(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := c.MapFunc()
+	key := func(code EvCode, v int32) InputEvent { return keyEvent(code, v) }
+	rel := func(code EvCode, v int32) InputEvent { return InputEvent{Type: EV_REL, Code: code, Value: v} }
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+
+	tests := []struct {
+		in   InputEvent
+		want []InputEvent
+	}{
+		{key(KEY_CAPSLOCK, 1), []InputEvent{key(KEY_ESC, 1)}},
+		{key(KEY_ESC, 2), []InputEvent{key(KEY_CAPSLOCK, 2)}},
+		{key(BTN_SIDE, 0), []InputEvent{key(KEY_BACK, 0)}},
+		{key(KEY_INSERT, 1), nil},
+		{key(KEY_F13, 1), []InputEvent{key(KEY_LEFTCTRL, 1), syn, key(KEY_LEFTSHIFT, 1), syn, key(KEY_C, 1)}},
+		{key(KEY_F13, 2), nil},
+		{key(KEY_F13, 0), []InputEvent{key(KEY_C, 0), syn, key(KEY_LEFTSHIFT, 0), syn, key(KEY_LEFTCTRL, 0)}},
+		{key(KEY_A, 1), []InputEvent{key(KEY_A, 1)}},
+		{rel(REL_WHEEL, 1), []InputEvent{rel(REL_WHEEL, -1)}},
+		// Half-scaled motion accumulates: 3 -> 1 (carry 0.5), 1 -> 1, 1 -> nothing.
+		{rel(REL_X, 3), []InputEvent{rel(REL_Y, 1)}},
+		{rel(REL_X, 1), []InputEvent{rel(REL_Y, 1)}},
+		{rel(REL_X, 1), nil},
+		{absEvent(ABS_X, 3), []InputEvent{absEvent(ABS_X, 5)}},
+		{absEvent(ABS_Y, 3), []InputEvent{absEvent(ABS_RY, 3)}},
+		{rel(REL_Y, 7), []InputEvent{rel(REL_Y, 7)}},
+	}
+	for _, tt := range tests {
+		if got := fn(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("map(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestMatchGlob checks * spans the '/' of a real phys, unlike path.Match.
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"usb-*input0", "usb-0000:00:14.0-1/input0", true},
+		{"usb-*input0", "usb-0000:00:14.0-1/input1", false},
+		{"*/input[0-1]", "usb-0000:00:14.0-1/input1", true},
+		{"*/input[^0-1]", "usb-0000:00:14.0-1/input1", false},
+		{"*Keyboard*", "Logitech USB/BT Keyboard", true},
+		{"Keyboard?", "Keyboard/", true},
+		{"a\\*", "a*", true},
+		{"a\\*", "ab", false},
+		{"1.0", "1x0", false},
+	}
+	for _, tt := range tests {
+		if got, err := matchGlob(tt.pattern, tt.s); got != tt.want || err != nil {
+			t.Errorf("matchGlob(%q, %q) = %v, %v; want %v", tt.pattern, tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"", "config line 1: unexpected end of config"},
+		{"{\n  \"keys\": {\n    \"KEY_CAPSLOK\": \"KEY_ESC\"\n  }\n}", "config line 3: unknown code \"KEY_CAPSLOK\""},
+		{"{\n  \"keys\": {\"KEY_A\": [\"KEY_B\",\n    \"KEY_FOO\"]}\n}", "config line 3: unknown code \"KEY_FOO\""},
+		{"{\n  \"keys\": {\"KEY_A\": \"REL_X\"}\n}", "config line 2: REL_X is not an EV_KEY code"},
+		{"{\n  \"swap\": [[\"KEY_A\", \"KEY_B\"]],\n  \"keys\": {\"KEY_B\": \"KEY_C\"}\n}", "config line 3: KEY_B is already mapped on line 2"},
+		{"{\n  \"axes\": {\"REL_X\": {\"to\": \"ABS_X\"}}\n}", "config line 2: ABS_X is not an EV_REL code"},
+		{"{\n  \"axes\": {\"KEY_A\": {}}\n}", "config line 2: KEY_A is not an axis"},
+		{"{\n  \"match\": {\"vendor\": \"logitech\"}\n}", "config line 2: invalid vendor \"logitech\""},
+		{"{\n  \"match\": {\"bus\": \"BUS_CAN\"}\n}", "config line 2: unknown bus \"BUS_CAN\""},
+		{"{\n  \"keymap\": {}\n}", "config line 2: unknown field \"keymap\""},
+		{"{\n  \"match\": {\"phys\": \"usb-[0-9\"}\n}", "config line 2: invalid phys pattern \"usb-[0-9\""},
+		{"{\n  \"keys\": {\n    \"KEY_A\": \"KEY_B\",\n  }\n}", "config line 3: invalid character ','"},
+		{"{}\n{}", "config line 2: unexpected data after the config object"},
+	}
+	for _, tt := range tests {
+		_, err := ParseConfig(strings.NewReader(tt.config))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseConfig(%q) error = %v, want %q", tt.config, err, tt.want)
+		}
+	}
+}
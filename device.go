@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -18,10 +20,34 @@ type InputID struct {
 	Version uint16
 }
 
+// AbsInfo mirrors the kernel's struct input_absinfo: the state and range of an
+// absolute axis.
+type AbsInfo struct {
+	Value      int32 // current position
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32 // noise filter: changes within ±Fuzz are dropped
+	Flat       int32 // dead zone around the centre, reported as centred
+	Resolution int32 // units per millimetre (per radian for rotational axes)
+}
+
+// AbsAxis is an absolute axis with its range, as registered on a virtual
+// device.
+type AbsAxis struct {
+	Code EvCode
+	AbsInfo
+}
+
 // Device is an open evdev input device (a /dev/input/event* node).
 type Device struct {
 	f    *os.File
 	path string
+
+	// w is a second, write-only handle on the node, opened by the first Write
+	// unless the Device is closed by then.
+	wmu    sync.Mutex
+	w      *os.File
+	closed bool
 }
 
 // capBufBytes sizes a capability bitmask buffer large enough for any event
@@ -45,7 +71,16 @@ func Open(path string) (*Device, error) {
 }
 
 // Close closes the underlying device file. It also unblocks a concurrent ReadOne.
-func (d *Device) Close() error { return d.f.Close() }
+func (d *Device) Close() error {
+	d.wmu.Lock()
+	w := d.w
+	d.closed = true
+	d.wmu.Unlock()
+	if w != nil {
+		return firstErr(d.f.Close(), w.Close())
+	}
+	return d.f.Close()
+}
 
 // Path returns the device path the Device was opened with.
 func (d *Device) Path() string { return d.path }
@@ -76,12 +111,18 @@ func (d *Device) control(fn func(fd uintptr) error) error {
 // ReadOne blocks until one event is available and returns it. It returns
 // io.EOF when the device disappears.
 func (d *Device) ReadOne() (InputEvent, error) {
+	if !eventIsWire {
+		var rec [sizeofInputEvent]byte
+		if _, err := io.ReadFull(d.f, rec[:]); err != nil {
+			return InputEvent{}, err
+		}
+		return NativeLayout.Decode(rec[:]), nil
+	}
 	var ev InputEvent
-	// InputEvent's memory layout matches the kernel's struct input_event byte
-	// for byte (see event.go), so we read straight into it via an aliasing byte
-	// view. This is a deliberate zero-copy fast path for the hottest call in the
-	// library; the alternative (encoding/binary into a scratch buffer) would copy
-	// and allocate on every event.
+	// Where InputEvent's memory layout matches the kernel's struct input_event
+	// byte for byte (64-bit platforms; see eventIsWire), we read straight into it
+	// via an aliasing byte view. This is a deliberate zero-copy fast path for the
+	// hottest call in the library; 32-bit platforms decode a stack copy above.
 	buf := (*[sizeofInputEvent]byte)(unsafe.Pointer(&ev))[:]
 	if _, err := io.ReadFull(d.f, buf); err != nil {
 		return InputEvent{}, err
@@ -89,6 +130,11 @@ func (d *Device) ReadOne() (InputEvent, error) {
 	return ev, nil
 }
 
+// SetReadDeadline makes a ReadOne or Read blocked past t return an error
+// wrapping os.ErrDeadlineExceeded, leaving the device open; the zero time
+// removes the deadline. A Remapper uses it to stop without closing its source.
+func (d *Device) SetReadDeadline(t time.Time) error { return d.f.SetReadDeadline(t) }
+
 // Read fills buf with as many events as are available in a single read,
 // blocking until at least one is, and returns the count. It is more efficient
 // than ReadOne for high event rates.
@@ -96,11 +142,56 @@ func (d *Device) Read(buf []InputEvent) (int, error) {
 	if len(buf) == 0 {
 		return 0, nil
 	}
+	if !eventIsWire {
+		b := make([]byte, len(buf)*sizeofInputEvent)
+		n, err := d.f.Read(b)
+		n /= sizeofInputEvent
+		for i := range n {
+			buf[i] = NativeLayout.Decode(b[i*sizeofInputEvent:])
+		}
+		return n, err
+	}
 	b := unsafe.Slice((*byte)(unsafe.Pointer(&buf[0])), len(buf)*sizeofInputEvent)
 	n, err := d.f.Read(b)
 	return n / sizeofInputEvent, err
 }
 
+// Write sends an event to the device, as the system does to set a keyboard's
+// LEDs (EV_LED) or ring its bell (EV_SND); follow a batch with a SYN_REPORT.
+// The Time field is ignored. It works on a grabbed device too. The node is
+// opened for writing on first use, which needs write access to it.
+func (d *Device) Write(ev InputEvent) error {
+	w, err := d.writer()
+	if err != nil {
+		return err
+	}
+	ev.Time = unix.Timeval{}
+	var rec [sizeofInputEvent]byte
+	NativeLayout.Put(rec[:], ev)
+	if _, err := w.Write(rec[:]); err != nil {
+		return fmt.Errorf("evdev: write %s: %w", d.path, err)
+	}
+	return nil
+}
+
+// writer returns the write handle, opening it if needed. It fails once the
+// Device is closed, so a Write racing Close cannot leak a handle.
+func (d *Device) writer() (*os.File, error) {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	if d.closed {
+		return nil, fmt.Errorf("evdev: write %s: %w", d.path, os.ErrClosed)
+	}
+	if d.w == nil {
+		w, err := os.OpenFile(d.path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, fmt.Errorf("evdev: open %s for writing: %w", d.path, err)
+		}
+		d.w = w
+	}
+	return d.w, nil
+}
+
 // Name returns the device name (EVIOCGNAME), e.g. "AT Translated Set 2 keyboard".
 func (d *Device) Name() (string, error) { return d.ioctlString("EVIOCGNAME", eviocgname) }
 
@@ -134,6 +225,25 @@ func (d *Device) DriverVersion() (int, error) {
 	return int(v), nil
 }
 
+// AbsInfo returns the state and range of absolute axis code (EVIOCGABS).
+func (d *Device) AbsInfo(code EvCode) (AbsInfo, error) {
+	var info AbsInfo
+	if err := d.control(func(fd uintptr) error { return ioctl(fd, eviocgabs(uintptr(code)), unsafe.Pointer(&info)) }); err != nil {
+		return AbsInfo{}, fmt.Errorf("evdev: EVIOCGABS(%s) %s: %w", CodeName(EV_ABS, code), d.path, err)
+	}
+	return info, nil
+}
+
+// FFEffects returns the number of force-feedback effects the device can play
+// at once (EVIOCGEFFECTS), or 0 for a device without force feedback.
+func (d *Device) FFEffects() (int, error) {
+	var n int32
+	if err := d.control(func(fd uintptr) error { return ioctl(fd, eviocgeffects(), unsafe.Pointer(&n)) }); err != nil {
+		return 0, fmt.Errorf("evdev: EVIOCGEFFECTS %s: %w", d.path, err)
+	}
+	return int(n), nil
+}
+
 // CapableTypes returns the event types the device can emit (EVIOCGBIT(0)).
 func (d *Device) CapableTypes() ([]EvType, error) {
 	bits, err := d.queryBits(0, (int(EV_MAX)+8)/8)
@@ -2,8 +2,12 @@ package evdev
 
 import (
 	"encoding/binary"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"golang.org/x/sys/unix"
 )
 
 // TestReadOneDecode verifies that ReadOne decodes a raw struct input_event off
@@ -40,11 +44,8 @@ func TestReadOneDecode(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if ev.Time.Sec != 0x1122334455667788 {
-		t.Errorf("Sec = %#x, want 0x1122334455667788", ev.Time.Sec)
-	}
-	if ev.Time.Usec != 0x99aabbcc {
-		t.Errorf("Usec = %#x, want 0x99aabbcc", ev.Time.Usec)
+	if sec, usec := timevalWords(ev.Time); sec != 0x1122334455667788 || usec != 0x99aabbcc {
+		t.Errorf("Time = %#x, %#x; want 0x1122334455667788, 0x99aabbcc", sec, usec)
 	}
 	if ev.Type != EV_KEY || ev.Code != KEY_A || ev.Value != 1 {
 		t.Errorf("got type=%s code=%s value=%d, want EV_KEY KEY_A 1", ev.Type, ev.CodeName(), ev.Value)
@@ -73,3 +74,46 @@ func TestListDevicePaths(t *testing.T) {
 		t.Fatalf("ListDevicePaths: %v", err)
 	}
 }
+
+// TestDeviceWrite checks that Write opens the node for writing on first use and
+// writes native records, with the timestamp cleared, and refuses once the
+// Device is closed. A regular file stands in for the device node.
+func TestDeviceWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event0")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs := []InputEvent{
+		{Time: timeval(1, 0), Type: EV_LED, Code: LED_CAPSL, Value: 1},
+		{Type: EV_SYN, Code: SYN_REPORT},
+	}
+	for _, ev := range evs {
+		if err := d.Write(ev); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := d.Write(evs[1]); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: err = %v, want os.ErrClosed", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := NewDecoder(f)
+	evs[0].Time = unix.Timeval{}
+	for i, want := range evs {
+		got, err := dec.ReadOne()
+		if err != nil || got != want {
+			t.Errorf("record %d = %v, %v; want %v", i, got, err, want)
+		}
+	}
+}
@@ -8,9 +8,12 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-// InputEvent mirrors the kernel's struct input_event. Its in-memory layout
-// matches the bytes read from a /dev/input/event* device, so it can be decoded
-// directly. The Time field uses unix.Timeval, which is architecture-correct.
+// InputEvent mirrors the kernel's struct input_event. The bytes on the wire
+// are described by an EventLayout (see NativeLayout); the in-memory layout also
+// matches the native record byte for byte, which Device exploits to read events
+// without a copy. The Time field uses unix.Timeval, whose fields are 32-bit on
+// 32-bit platforms; there the kernel's time words are unsigned, which When
+// accounts for.
 type InputEvent struct {
 	Time  unix.Timeval
 	Type  EvType
@@ -18,13 +21,47 @@ type InputEvent struct {
 	Value int32
 }
 
+// EventSource is a stream of input events read one at a time. *Device is the
+// canonical implementation, but anything that yields events — a recording, a
+// network stream, a test fake — can stand in for it wherever a source is
+// accepted (see NewRemapperFrom).
+type EventSource interface {
+	// ReadOne blocks until the next event is available and returns it. It
+	// returns io.EOF when the stream ends.
+	ReadOne() (InputEvent, error)
+}
+
+// EventSink consumes input events. *VirtualDevice is the canonical
+// implementation; a log, a socket, or a test fake can implement it too.
+type EventSink interface {
+	// Write delivers one event. Frames are delimited by EV_SYN/SYN_REPORT
+	// events written like any other.
+	Write(InputEvent) error
+}
+
+// BatchSink is an EventSink that can also deliver several events in one call —
+// one write syscall for a VirtualDevice or Encoder. The Remapper uses it, when
+// its sink provides it, to emit each frame at once.
+type BatchSink interface {
+	EventSink
+	// WriteEvents delivers evs in order, as Write would one at a time.
+	WriteEvents(evs []InputEvent) error
+}
+
 // sizeofInputEvent is the size of one struct input_event record on this
 // architecture (24 bytes on 64-bit, 16 on 32-bit).
-const sizeofInputEvent = int(unsafe.Sizeof(InputEvent{}))
+const sizeofInputEvent = 2*nativeWordSize + 8
+
+// eventIsWire reports whether InputEvent's memory layout is exactly the native
+// record, so a byte view of an InputEvent can be read or written directly. It
+// holds wherever unix.Timeval matches the kernel's time words; elsewhere events
+// go through NativeLayout instead.
+const eventIsWire = sizeofInputEvent == int(unsafe.Sizeof(InputEvent{}))
 
 // When returns the event timestamp as a time.Time.
 func (e InputEvent) When() time.Time {
-	return time.Unix(e.Time.Sec, e.Time.Usec*1000)
+	sec, usec := timevalWords(e.Time)
+	return time.Unix(sec, usec*1000)
 }
 
 // CodeName returns the symbolic name of this event's code within its type's
@@ -0,0 +1,22 @@
+//go:build 386 || arm || mips || mipsle
+
+package evdev
+
+import "golang.org/x/sys/unix"
+
+// nativeWordSize is the width of the kernel's unsigned long, and so of each
+// input_event time field: 4 bytes on 32-bit kernels (__sec/__usec), whatever
+// the width of userspace's time_t.
+const nativeWordSize = 4
+
+// timeval returns a Timeval holding the time words sec and usec, truncated to
+// this platform's 32-bit fields.
+func timeval(sec, usec int64) unix.Timeval {
+	return unix.Timeval{Sec: int32(sec), Usec: int32(usec)}
+}
+
+// timevalWords returns the time words of tv. The kernel's words are unsigned
+// here, so timestamps stay correct until 2106 despite the signed fields.
+func timevalWords(tv unix.Timeval) (sec, usec int64) {
+	return int64(uint32(tv.Sec)), int64(uint32(tv.Usec))
+}
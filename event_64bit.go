@@ -0,0 +1,19 @@
+//go:build amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x
+
+package evdev
+
+import "golang.org/x/sys/unix"
+
+// nativeWordSize is the width of the kernel's unsigned long, and so of each
+// input_event time field: 8 bytes on 64-bit kernels (struct timeval).
+const nativeWordSize = 8
+
+// timeval returns a Timeval holding the time words sec and usec.
+func timeval(sec, usec int64) unix.Timeval {
+	return unix.Timeval{Sec: sec, Usec: usec}
+}
+
+// timevalWords returns the time words of tv.
+func timevalWords(tv unix.Timeval) (sec, usec int64) {
+	return tv.Sec, tv.Usec
+}
@@ -0,0 +1,105 @@
+package evdev
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// EventLayout describes how struct input_event is encoded in bytes for one
+// kernel ABI: two time words, then type (u16), code (u16) and value (s32).
+//
+//	64-bit kernels: struct timeval { long tv_sec, tv_usec }   8-byte words, 24-byte record
+//	32-bit kernels: __kernel_ulong_t __sec, __usec            4-byte words, 16-byte record
+//
+// On 32-bit kernels the time words are the split, unsigned __sec/__usec fields
+// whatever userspace's time_t is. With the 64-bit time_t now standard on armv7
+// and i386, userspace's own struct timeval no longer matches the record, so it
+// must be decoded by word size rather than by overlaying a timeval; decoding the
+// seconds as unsigned keeps timestamps correct until 2106.
+//
+// NativeLayout is the layout the running kernel uses. The others let captures
+// from another architecture be decoded (see Decoder).
+type EventLayout struct {
+	// WordSize is the width in bytes of each time field: 8 or 4.
+	WordSize int
+	// ByteOrder is the endianness of every field.
+	ByteOrder binary.ByteOrder
+}
+
+// Predefined layouts for each word size and byte order.
+var (
+	Layout64LE = EventLayout{WordSize: 8, ByteOrder: binary.LittleEndian} // amd64, arm64, riscv64, ...
+	Layout64BE = EventLayout{WordSize: 8, ByteOrder: binary.BigEndian}    // s390x, ppc64, mips64
+	Layout32LE = EventLayout{WordSize: 4, ByteOrder: binary.LittleEndian} // i386, armv7, mipsle
+	Layout32BE = EventLayout{WordSize: 4, ByteOrder: binary.BigEndian}    // mips
+)
+
+// NativeLayout is the input_event layout of this platform: the encoding of
+// records read from /dev/input/event* and written to /dev/uinput.
+var NativeLayout = EventLayout{WordSize: nativeWordSize, ByteOrder: binary.NativeEndian}
+
+// Size returns the size in bytes of one record.
+func (l EventLayout) Size() int { return 2*l.WordSize + 8 }
+
+// String describes the layout, e.g. "64-bit LittleEndian".
+func (l EventLayout) String() string {
+	return fmt.Sprintf("%d-bit %s", l.WordSize*8, l.ByteOrder)
+}
+
+// Decode decodes one record from the start of b, which must hold at least
+// Size bytes. On 32-bit layouts the time words are unsigned; on 32-bit
+// platforms Time's fields hold them as is, and When reads them back unsigned.
+func (l EventLayout) Decode(b []byte) InputEvent {
+	w := l.WordSize
+	_ = b[l.Size()-1] // bounds check hint, and a clear panic for short input
+	return InputEvent{
+		Time:  timeval(l.word(b[0:w]), l.word(b[w:2*w])),
+		Type:  EvType(l.ByteOrder.Uint16(b[2*w:])),
+		Code:  EvCode(l.ByteOrder.Uint16(b[2*w+2:])),
+		Value: int32(l.ByteOrder.Uint32(b[2*w+4:])),
+	}
+}
+
+// Put encodes ev into the start of b, which must hold at least Size bytes. On
+// 32-bit layouts the time words are truncated to 32 bits.
+func (l EventLayout) Put(b []byte, ev InputEvent) {
+	w := l.WordSize
+	_ = b[l.Size()-1]
+	sec, usec := timevalWords(ev.Time)
+	l.putWord(b[0:w], sec)
+	l.putWord(b[w:2*w], usec)
+	l.ByteOrder.PutUint16(b[2*w:], uint16(ev.Type))
+	l.ByteOrder.PutUint16(b[2*w+2:], uint16(ev.Code))
+	l.ByteOrder.PutUint32(b[2*w+4:], uint32(ev.Value))
+}
+
+// Append appends the encoding of ev to b and returns the extended buffer.
+func (l EventLayout) Append(b []byte, ev InputEvent) []byte {
+	n := len(b)
+	b = append(b, make([]byte, l.Size())...)
+	l.Put(b[n:], ev)
+	return b
+}
+
+// word decodes one time field: signed long on 64-bit, __kernel_ulong_t (so
+// unsigned) on 32-bit.
+func (l EventLayout) word(b []byte) int64 {
+	switch l.WordSize {
+	case 8:
+		return int64(l.ByteOrder.Uint64(b))
+	case 4:
+		return int64(l.ByteOrder.Uint32(b))
+	}
+	panic(fmt.Sprintf("evdev: invalid EventLayout word size %d", l.WordSize))
+}
+
+func (l EventLayout) putWord(b []byte, v int64) {
+	switch l.WordSize {
+	case 8:
+		l.ByteOrder.PutUint64(b, uint64(v))
+	case 4:
+		l.ByteOrder.PutUint32(b, uint32(v))
+	default:
+		panic(fmt.Sprintf("evdev: invalid EventLayout word size %d", l.WordSize))
+	}
+}
@@ -0,0 +1,129 @@
+package evdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+	"unsafe"
+)
+
+// TestEventLayouts checks every predefined layout against a record built by
+// hand with explicit field offsets, in both directions, so each architecture's
+// encoding is verified whichever one the tests run on.
+func TestEventLayouts(t *testing.T) {
+	ev := InputEvent{
+		Time:  timeval(0x61626364, 0x000a0b0c),
+		Type:  EV_KEY,
+		Code:  KEY_A,
+		Value: -1,
+	}
+	tests := []struct {
+		name   string
+		layout EventLayout
+		size   int
+	}{
+		{"64LE", Layout64LE, 24},
+		{"64BE", Layout64BE, 24},
+		{"32LE", Layout32LE, 16},
+		{"32BE", Layout32BE, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.layout
+			if got := l.Size(); got != tt.size {
+				t.Fatalf("Size() = %d, want %d", got, tt.size)
+			}
+
+			w, bo := l.WordSize, l.ByteOrder
+			rec := make([]byte, tt.size)
+			if w == 8 {
+				bo.PutUint64(rec[0:], 0x61626364)
+				bo.PutUint64(rec[8:], 0x000a0b0c)
+			} else {
+				bo.PutUint32(rec[0:], 0x61626364)
+				bo.PutUint32(rec[4:], 0x000a0b0c)
+			}
+			bo.PutUint16(rec[2*w:], uint16(ev.Type))
+			bo.PutUint16(rec[2*w+2:], uint16(ev.Code))
+			bo.PutUint32(rec[2*w+4:], uint32(ev.Value))
+
+			if got := l.Decode(rec); got != ev {
+				t.Errorf("Decode = %+v, want %+v", got, ev)
+			}
+			if got := l.Append([]byte{0xff}, ev); !bytes.Equal(got[1:], rec) || got[0] != 0xff {
+				t.Errorf("Append = % x, want ff % x", got, rec)
+			}
+		})
+	}
+}
+
+// TestEventLayout32Unsigned verifies 32-bit time words decode as the kernel's
+// unsigned __sec, so timestamps past 2038 stay positive.
+func TestEventLayout32Unsigned(t *testing.T) {
+	rec := make([]byte, 16)
+	binary.LittleEndian.PutUint32(rec[0:], 0xf0000000) // year 2097
+	ev := Layout32LE.Decode(rec)
+	if got := ev.When().Unix(); got != 0xf0000000 {
+		t.Errorf("When().Unix() = %d, want %d", got, int64(0xf0000000))
+	}
+}
+
+// TestNativeLayout checks the native layout agrees with the record size the
+// device code reads, and that the zero-copy path is only taken where
+// InputEvent's field offsets really are the wire offsets.
+func TestNativeLayout(t *testing.T) {
+	if got := NativeLayout.Size(); got != sizeofInputEvent {
+		t.Fatalf("NativeLayout.Size() = %d, want %d", got, sizeofInputEvent)
+	}
+	if !eventIsWire {
+		return
+	}
+	var ev InputEvent
+	w := uintptr(NativeLayout.WordSize)
+	if unsafe.Offsetof(ev.Time.Usec) != w || unsafe.Offsetof(ev.Type) != 2*w ||
+		unsafe.Offsetof(ev.Code) != 2*w+2 || unsafe.Offsetof(ev.Value) != 2*w+4 {
+		t.Error("InputEvent field offsets differ from the native record")
+	}
+}
+
+// TestReadWriteNative round-trips events through a pipe with the native layout
+// on whatever architecture the test runs on, covering both the zero-copy and
+// the decoding read paths.
+func TestReadWriteNative(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	want := []InputEvent{
+		{Time: timeval(1700000000, 123456), Type: EV_KEY, Code: KEY_B, Value: 1},
+		{Time: timeval(1700000000, 123457), Type: EV_SYN, Code: SYN_REPORT},
+	}
+	var b []byte
+	for _, ev := range want {
+		b = NativeLayout.Append(b, ev)
+	}
+	go func() {
+		w.Write(b)
+		w.Close()
+	}()
+
+	d := &Device{f: r, path: "pipe"}
+	first, err := d.ReadOne()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != want[0] {
+		t.Errorf("ReadOne = %+v, want %+v", first, want[0])
+	}
+	buf := make([]InputEvent, 4)
+	n, err := d.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || buf[0] != want[1] {
+		t.Errorf("Read = %v, want %v", buf[:n], want[1:])
+	}
+}
@@ -3,17 +3,28 @@
 // through. The whole mapping is the swap function; the Remapper handles the
 // grab -> read -> transform -> inject loop and teardown.
 //
+// With -config, the mapping comes from a JSON file instead (see evdev.Config),
+// and the device may be left out to remap the first one the file's "match"
+// selects — through an evdev.ManagedRemapper, which follows the device across
+// unplugging and replugging.
+//
 // Run with privileges (input access + write to /dev/uinput):
 //
 //	sudo go run ./examples/remap /dev/input/eventX
+//	sudo go run ./examples/remap -config remap.json [/dev/input/eventX]
 //
 // WARNING: this grabs the device exclusively, so while it runs that keyboard's
 // keys reach ONLY this program. Point it at a keyboard you are not relying on to
 // stop the program, or run it over SSH. Ctrl-C stops it; on exit the grab is
-// released and the virtual device destroyed.
+// released and the virtual device destroyed. If the keyboard stops responding,
+// hold Backspace, Escape and Enter together — the panic chord this example
+// sets — to release the grab and exit.
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -23,37 +34,84 @@ import (
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "usage: %s /dev/input/eventX\n", os.Args[0])
+	configPath := flag.String("config", "", "JSON remap config to apply instead of the Caps Lock/Escape swap")
+	flag.Parse()
+	if flag.NArg() > 1 || (flag.NArg() == 0 && *configPath == "") {
+		fmt.Fprintf(os.Stderr, "usage: %s [-config file.json] /dev/input/eventX\n", os.Args[0])
 		os.Exit(2)
 	}
 
-	src, err := evdev.Open(os.Args[1])
+	fn, what := evdev.MapFunc(swapCapsEsc), "Caps Lock <-> Escape"
+	opts := []evdev.RemapOption{
+		evdev.WithLEDFeedback(),
+		evdev.WithPhysSuffix("/remap"),
+		evdev.WithPanicChord(evdev.DefaultPanicChord...),
+	}
+	var cfg *evdev.Config
+	if *configPath != "" {
+		var err error
+		if cfg, err = evdev.LoadConfig(*configPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fn, what = cfg.MapFunc(), *configPath
+		opts = append(opts, cfg.RemapOptions()...)
+	}
+
+	if flag.NArg() == 0 {
+		runManaged(cfg, fn, opts, what)
+		return
+	}
+
+	src, err := evdev.Open(flag.Arg(0))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "open:", err)
 		os.Exit(1)
 	}
 	defer src.Close()
 
-	rm, err := evdev.NewRemapper(src, swapCapsEsc)
+	rm, err := evdev.NewRemapper(src, fn, opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "remapper:", err)
+		os.Exit(1)
+	}
+	defer rm.Close()
+
+	// On Ctrl-C, RunContext returns, releasing any held keys; the deferred
+	// Close then ungrabs the source and destroys the virtual device.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	name, _ := src.Name()
+	fmt.Printf("remapping %q (%s) — Ctrl-C to stop\n", name, what)
+
+	err = rm.RunContext(ctx)
+	if errors.Is(err, evdev.ErrPanicChord) {
+		fmt.Fprintln(os.Stderr, "panic chord pressed; grab released")
+	} else if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "run:", err)
+		os.Exit(1)
+	}
+}
+
+// runManaged remaps whichever device cfg matches, waiting for it to be plugged
+// in.
+func runManaged(cfg *evdev.Config, fn evdev.MapFunc, opts []evdev.RemapOption, what string) {
+	rm, err := evdev.NewManagedRemapper(cfg.Matches, fn, opts...)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "remapper:", err)
 		os.Exit(1)
 	}
 	defer rm.Close()
 
-	// On Ctrl-C, tear down the remapper and close the source so Run unblocks.
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sig
 		rm.Close()
-		src.Close()
 	}()
 
-	name, _ := src.Name()
-	fmt.Printf("remapping %q (Caps Lock <-> Escape) — Ctrl-C to stop\n", name)
-
+	fmt.Printf("remapping the device %s matches — Ctrl-C to stop\n", what)
 	if err := rm.Run(); err != nil {
 		fmt.Fprintln(os.Stderr, "run:", err)
 		os.Exit(1)
@@ -2,31 +2,42 @@
 // removes it. Run with write access to /dev/uinput (root on most systems, or a
 // seat user where logind grants access):
 //
-//	sudo go run ./examples/vkbd
+//	sudo go run ./examples/vkbd [-layout us] [message]
 //
 // After it starts there is a short delay so the desktop can bind the new device;
-// focus a text field during that window to see "hello" typed into it. You can
-// also watch the raw events with, in another terminal:
+// focus a text field during that window to see the message typed into it. The
+// layout must match the one the desktop uses. You can also watch the raw events
+// with, in another terminal:
 //
 //	sudo go run ./examples/monitor
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	evdev "github.com/mikegio27/go-evdev"
+	"github.com/mikegio27/go-evdev/layout"
 )
 
-// message is the sequence of keys to type: h e l l o, then Enter.
-var message = []evdev.EvCode{
-	evdev.KEY_H, evdev.KEY_E, evdev.KEY_L, evdev.KEY_L, evdev.KEY_O, evdev.KEY_ENTER,
-}
-
 func main() {
+	layoutName := flag.String("layout", "us", "keyboard layout: us, gb, de, fr or dvorak")
+	flag.Parse()
+	message := "Hello, world!\n"
+	if flag.NArg() > 0 {
+		message = strings.Join(flag.Args(), " ") + "\n"
+	}
+	l, ok := layout.ByName(*layoutName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown layout:", *layoutName)
+		os.Exit(2)
+	}
+
 	id := evdev.InputID{BusType: evdev.BUS_USB, Vendor: 0x1234, Product: 0x5678, Version: 1}
-	v, err := evdev.CreateVirtualDevice("go-evdev virtual keyboard", id, evdev.Capabilities{Keys: message})
+	v, err := evdev.CreateVirtualDevice("go-evdev virtual keyboard", id, evdev.Capabilities{Keys: l.KeyCodes()})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "create:", err)
 		os.Exit(1)
@@ -38,26 +49,10 @@ func main() {
 	fmt.Println("virtual keyboard created — focus a text field; typing in 2s...")
 	time.Sleep(2 * time.Second)
 
-	for _, key := range message {
-		if err := typeKey(v, key); err != nil {
-			fmt.Fprintln(os.Stderr, "type:", err)
-			os.Exit(1)
-		}
-		time.Sleep(40 * time.Millisecond)
+	t := layout.NewTyper(v, l, layout.WithDelay(20*time.Millisecond), layout.WithFallback(layout.UnicodeInput))
+	if err := t.Type(message); err != nil {
+		fmt.Fprintln(os.Stderr, "type:", err)
+		os.Exit(1)
 	}
 	fmt.Println("done")
 }
-
-// typeKey presses and releases one key, flushing each with a SYN_REPORT.
-func typeKey(v *evdev.VirtualDevice, code evdev.EvCode) error {
-	if err := v.WriteEvent(evdev.EV_KEY, code, 1); err != nil {
-		return err
-	}
-	if err := v.Sync(); err != nil {
-		return err
-	}
-	if err := v.WriteEvent(evdev.EV_KEY, code, 0); err != nil {
-		return err
-	}
-	return v.Sync()
-}
@@ -4,7 +4,7 @@
 // It is run via `go generate` from the package root and reads:
 //
 //	/usr/include/linux/input-event-codes.h  (EV_*, KEY_*, BTN_*, REL_*, ABS_*, ...)
-//	/usr/include/linux/input.h              (BUS_*)
+//	/usr/include/linux/input.h              (BUS_*, FF_*)
 //
 // Consumers of the evdev package never need the kernel headers: codes.go is
 // checked in and is the source of truth.
@@ -46,6 +46,7 @@ var prefixType = map[string]string{
 	"LED_": "EV_LED",
 	"SND_": "EV_SND",
 	"REP_": "EV_REP",
+	"FF_":  "EV_FF",
 }
 
 var defineRe = regexp.MustCompile(`^#define\s+([A-Z][A-Z0-9_]*)\s+(.+?)\s*(?:/\*.*)?$`)
@@ -90,7 +91,9 @@ func run() error {
 		return err
 	}
 	if err := collect(inputHeader, func(name string) bool {
-		return strings.HasPrefix(name, "BUS_")
+		// FF_STATUS_* values belong to EV_FF_STATUS, not the EV_FF code space.
+		return strings.HasPrefix(name, "BUS_") ||
+			strings.HasPrefix(name, "FF_") && !strings.HasPrefix(name, "FF_STATUS_")
 	}); err != nil {
 		return err
 	}
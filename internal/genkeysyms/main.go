@@ -0,0 +1,86 @@
+// Command genkeysyms parses the X11 keysym header and emits layout/keysyms.go,
+// a committed table mapping each keysym name to the Unicode character it
+// types, used by the layout package's XKB keymap parser.
+//
+// It is run via `go generate` from the layout package and reads:
+//
+//	/usr/include/X11/keysymdef.h
+//
+// Only keysyms the header annotates with an exact Unicode equivalent
+// ("/* U+00E9 ... */") are kept; function keys, modifiers and dead keys have
+// none and are handled by the parser itself.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	keysymHeader = "/usr/include/X11/keysymdef.h"
+	outFile      = "keysyms.go"
+)
+
+var keysymRe = regexp.MustCompile(`^#define\s+XK_([A-Za-z0-9_]+)\s+0x[0-9a-fA-F]+\s*/\*\s*U\+([0-9A-Fa-f]{4,6})\s`)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "genkeysyms:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	f, err := os.Open(keysymHeader)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	runes := map[string]rune{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := keysymRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		r, err := strconv.ParseUint(m[2], 16, 32)
+		if err != nil {
+			return err
+		}
+		if _, dup := runes[m[1]]; !dup {
+			runes[m[1]] = rune(r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(runes))
+	for name := range runes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("// Code generated by internal/genkeysyms; DO NOT EDIT.\n\n")
+	b.WriteString("package layout\n\n")
+	b.WriteString("// keysymRunes maps X11 keysym names to the character they type.\n")
+	b.WriteString("var keysymRunes = map[string]rune{\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t%q: 0x%04x,\n", name, runes[name])
+	}
+	b.WriteString("}\n")
+
+	formatted, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return fmt.Errorf("gofmt generated source: %w", err)
+	}
+	return os.WriteFile(outFile, formatted, 0o644)
+}
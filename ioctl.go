@@ -60,6 +60,14 @@ func eviocgbit(ev, length uintptr) uintptr {
 	return ioc(iocRead, evdevType, 0x20+ev, length)
 }
 
+// eviocgabs builds EVIOCGABS, which reads the struct input_absinfo of one
+// absolute axis.
+func eviocgabs(abs uintptr) uintptr { return ior(evdevType, 0x40+abs, unsafe.Sizeof(AbsInfo{})) }
+
+// eviocgeffects builds EVIOCGEFFECTS, the number of force-feedback effects the
+// device can play at once.
+func eviocgeffects() uintptr { return ior(evdevType, 0x84, unsafe.Sizeof(int32(0))) }
+
 // eviocgrab builds the EVIOCGRAB request (reserved for a future Grab/Ungrab).
 func eviocgrab() uintptr { return iow(evdevType, 0x90, unsafe.Sizeof(int32(0))) }
 
@@ -12,6 +12,8 @@ func TestIoctlEncoding(t *testing.T) {
 	}{
 		{"EVIOCGVERSION", eviocgversion(), 0x80044501},
 		{"EVIOCGID", eviocgid(), 0x80084502},
+		{"EVIOCGEFFECTS", eviocgeffects(), 0x80044584},
+		{"EVIOCGABS(ABS_X)", eviocgabs(uintptr(ABS_X)), 0x80184540},
 		{"EVIOCGRAB", eviocgrab(), 0x40044590},
 		{"EVIOCGNAME(256)", eviocgname(256), 0x81004506},
 		{"EVIOCGPHYS(256)", eviocgphys(256), 0x81004507},
@@ -0,0 +1,371 @@
+package evdev
+
+import (
+	"slices"
+	"time"
+)
+
+// Layers is a keyboard engine in the style of QMK firmware, a Mapper for a
+// Remapper: dual-role keys that do one thing when tapped and another
+// when held (Caps Lock as Escape and Control), keys that switch to another
+// layer of bindings while held (Space for a navigation layer), and one-shot
+// modifiers that apply to the next key.
+//
+// Whether a dual-role key was tapped or held is decided by its release, the
+// tapping term running out, or another key — see WithPermissiveHold and
+// WithHoldOnOtherKeyPress. Keys pressed while that is undecided are held back
+// and replayed once it is. The tapping term is timed on the Remapper's clock.
+//
+//	l := evdev.NewLayers()
+//	l.DualRole(evdev.KEY_CAPSLOCK, evdev.KEY_ESC, evdev.KEY_LEFTCTRL)
+//	l.LayerTap(evdev.KEY_SPACE, evdev.KEY_SPACE, "nav")
+//	l.Bind("nav", evdev.KEY_H, evdev.KEY_LEFT)
+//	r, err := evdev.NewRemapper(src, nil, append(l.RemapOptions(), evdev.WithMapper(l))...)
+//
+// Configure a Layers before using it, and use it for one Remapper.
+type Layers struct {
+	term        time.Duration
+	permissive  bool
+	holdOnOther bool
+
+	roles    map[EvCode]keyRole
+	bindings map[string]map[EvCode]EvCode
+
+	active  []string            // layers held, most recent last
+	down    map[EvCode]*heldKey // physical keys down, by what they did
+	pending *pendingKey         // dual-role key not yet decided
+	queue   []InputEvent        // key events held back while pending
+	armed   []EvCode            // one-shot modifiers waiting for a key
+}
+
+// DefaultTappingTerm is how long a dual-role key may be held and still count
+// as a tap, unless WithTappingTerm says otherwise.
+const DefaultTappingTerm = 200 * time.Millisecond
+
+// LayersOption configures a Layers.
+type LayersOption func(*Layers)
+
+// WithTappingTerm sets how long a dual-role key may be held and still count
+// as a tap.
+func WithTappingTerm(d time.Duration) LayersOption {
+	return func(l *Layers) { l.term = d }
+}
+
+// WithPermissiveHold decides a dual-role key is held when another key is
+// pressed and released while it is down, even within the tapping term — so a
+// quick Caps+C is Ctrl+C rather than Escape, c.
+func WithPermissiveHold() LayersOption {
+	return func(l *Layers) { l.permissive = true }
+}
+
+// WithHoldOnOtherKeyPress decides a dual-role key is held as soon as another
+// key is pressed while it is down. It takes precedence over
+// WithPermissiveHold.
+func WithHoldOnOtherKeyPress() LayersOption {
+	return func(l *Layers) { l.holdOnOther = true }
+}
+
+type roleKind int
+
+const (
+	modTap roleKind = iota
+	layerTap
+	oneShot
+)
+
+// keyRole is a key's special behaviour. hold is the modifier of a modTap or
+// oneShot key.
+type keyRole struct {
+	kind  roleKind
+	tap   EvCode
+	hold  EvCode
+	layer string
+}
+
+// heldKey records what a physical key's press did, to undo it on release.
+type heldKey struct {
+	out     EvCode   // key emitted down, or 0 for none
+	layer   string   // layer switched on, or ""
+	release []EvCode // one-shot modifiers to release after out
+
+	oneShot  bool // a one-shot key; out is its modifier
+	wasArmed bool // the one-shot modifier was armed when it was pressed
+	used     bool // another key was pressed while this one-shot was down
+}
+
+// pendingKey is a dual-role key pressed but not yet decided.
+type pendingKey struct {
+	code   EvCode
+	role   keyRole
+	timer  Timer
+	during map[EvCode]bool // keys pressed since
+}
+
+// NewLayers returns a Layers with no special keys or bindings, which passes
+// every event through.
+func NewLayers(opts ...LayersOption) *Layers {
+	l := &Layers{
+		term:     DefaultTappingTerm,
+		roles:    map[EvCode]keyRole{},
+		bindings: map[string]map[EvCode]EvCode{},
+		down:     map[EvCode]*heldKey{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// DualRole makes key emit tap when tapped and act as hold — typically a
+// modifier — while held.
+func (l *Layers) DualRole(key, tap, hold EvCode) {
+	l.roles[key] = keyRole{kind: modTap, tap: tap, hold: hold}
+}
+
+// LayerTap makes key emit tap when tapped and switch to layer while held. A
+// tap of 0 (KEY_RESERVED) makes it a plain layer key. The layer must not be
+// "", which names the base bindings.
+func (l *Layers) LayerTap(key, tap EvCode, layer string) {
+	l.roles[key] = keyRole{kind: layerTap, tap: tap, layer: layer}
+}
+
+// OneShot makes key a one-shot modifier: tapped, it holds mod down until the
+// next key is released, so Shift tapped then A types "A"; held, it is an
+// ordinary modifier. Tapping it again before another key cancels it.
+func (l *Layers) OneShot(key, mod EvCode) {
+	l.roles[key] = keyRole{kind: oneShot, hold: mod}
+}
+
+// Bind makes from emit to while layer is active, or always if layer is "". A
+// key with no binding in the active layers falls through to the layers
+// switched on before them and then to the base bindings. A to of 0
+// (KEY_RESERVED) disables the key. Keys given a role by DualRole, LayerTap or
+// OneShot keep it in every layer.
+func (l *Layers) Bind(layer string, from, to EvCode) {
+	if l.bindings[layer] == nil {
+		l.bindings[layer] = map[EvCode]EvCode{}
+	}
+	l.bindings[layer][from] = to
+}
+
+// RemapOptions returns the NewRemapper options registering the keys the
+// Layers emits, which the source may lack.
+func (l *Layers) RemapOptions() []RemapOption {
+	var keys []EvCode
+	for _, r := range l.roles {
+		keys = append(keys, r.tap, r.hold)
+	}
+	for _, b := range l.bindings {
+		for _, to := range b {
+			keys = append(keys, to)
+		}
+	}
+	keys = slices.DeleteFunc(keys, func(c EvCode) bool { return c == KEY_RESERVED })
+	return []RemapOption{WithExtraKeys(keys...)}
+}
+
+// Map implements Mapper.
+func (l *Layers) Map(ctx MapContext, ev InputEvent) {
+	ctx.Emit(frames(l.process(ctx, ev))...)
+}
+
+// frames separates evs with SYN_REPORTs, so each key change is seen in turn.
+func frames(evs []InputEvent) []InputEvent {
+	if len(evs) < 2 {
+		return evs
+	}
+	out := make([]InputEvent, 0, 2*len(evs)-1)
+	for i, ev := range evs {
+		if i > 0 {
+			out = append(out, InputEvent{Type: EV_SYN, Code: SYN_REPORT})
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+// expire decides p is held once the tapping term runs out.
+func (l *Layers) expire(ctx MapContext, p *pendingKey) {
+	if l.pending != p {
+		return // decided meanwhile
+	}
+	ctx.Emit(frames(l.resolve(ctx, false))...)
+}
+
+func keyEv(code EvCode, value int32) InputEvent {
+	return InputEvent{Type: EV_KEY, Code: code, Value: value}
+}
+
+// process handles one event, returning the key changes to emit.
+func (l *Layers) process(ctx MapContext, ev InputEvent) []InputEvent {
+	if ev.Type != EV_KEY {
+		return []InputEvent{ev}
+	}
+	if p := l.pending; p != nil && ev.Code != p.code {
+		return l.interrupt(ctx, p, ev)
+	}
+	switch ev.Value {
+	case 1:
+		return l.press(ctx, ev.Code)
+	case 0:
+		return l.release(ctx, ev.Code)
+	}
+	return l.repeat(ev.Code)
+}
+
+// interrupt handles a key event arriving while p is undecided.
+func (l *Layers) interrupt(ctx MapContext, p *pendingKey, ev InputEvent) []InputEvent {
+	if _, ok := l.down[ev.Code]; ok && ev.Value != 1 {
+		// Down since before p: its release or repeat does not depend on p.
+		if ev.Value == 0 {
+			return l.release(ctx, ev.Code)
+		}
+		return l.repeat(ev.Code)
+	}
+	if ev.Value == 1 && l.holdOnOther {
+		return append(l.resolve(ctx, false), l.process(ctx, ev)...)
+	}
+	l.queue = append(l.queue, ev)
+	if ev.Value == 1 {
+		p.during[ev.Code] = true
+	}
+	if ev.Value == 0 && p.during[ev.Code] && l.permissive {
+		return l.resolve(ctx, false)
+	}
+	return nil
+}
+
+// resolve decides the pending key was tapped or held, then replays the key
+// events held back meanwhile.
+func (l *Layers) resolve(ctx MapContext, tap bool) []InputEvent {
+	p := l.pending
+	l.pending = nil
+	p.timer.Stop()
+
+	var out []InputEvent
+	switch {
+	case tap:
+		if p.role.tap != KEY_RESERVED {
+			out = append(out, keyEv(p.role.tap, 1), keyEv(p.role.tap, 0))
+			out = append(out, l.releaseArmed()...)
+		}
+	case p.role.kind == modTap:
+		out = append(out, keyEv(p.role.hold, 1))
+		l.down[p.code] = &heldKey{out: p.role.hold}
+	default:
+		l.active = append(l.active, p.role.layer)
+		l.down[p.code] = &heldKey{layer: p.role.layer}
+	}
+
+	queue := l.queue
+	l.queue = nil
+	for _, ev := range queue {
+		out = append(out, l.process(ctx, ev)...)
+	}
+	return out
+}
+
+// releaseArmed releases the armed one-shot modifiers.
+func (l *Layers) releaseArmed() []InputEvent {
+	var out []InputEvent
+	for _, m := range l.armed {
+		out = append(out, keyEv(m, 0))
+	}
+	l.armed = nil
+	return out
+}
+
+func (l *Layers) press(ctx MapContext, code EvCode) []InputEvent {
+	for _, h := range l.down {
+		if h.oneShot {
+			h.used = true
+		}
+	}
+	if r, ok := l.roles[code]; ok {
+		switch r.kind {
+		case modTap, layerTap:
+			p := &pendingKey{code: code, role: r, during: map[EvCode]bool{}}
+			p.timer = ctx.AfterFunc(l.term, func() { l.expire(ctx, p) })
+			l.pending = p
+			return nil
+		case oneShot:
+			h := &heldKey{out: r.hold, oneShot: true, wasArmed: slices.Contains(l.armed, r.hold)}
+			l.down[code] = h
+			if h.wasArmed {
+				return nil
+			}
+			return []InputEvent{keyEv(r.hold, 1)}
+		}
+	}
+	h := &heldKey{out: l.lookup(code), release: l.armed}
+	l.armed = nil
+	l.down[code] = h
+	if h.out == KEY_RESERVED {
+		return nil
+	}
+	return []InputEvent{keyEv(h.out, 1)}
+}
+
+func (l *Layers) release(ctx MapContext, code EvCode) []InputEvent {
+	if p := l.pending; p != nil && p.code == code {
+		return l.resolve(ctx, true)
+	}
+	h, ok := l.down[code]
+	if !ok {
+		// Pressed before the Layers saw it; let the release through so the
+		// key is not left stuck down.
+		return []InputEvent{keyEv(code, 0)}
+	}
+	delete(l.down, code)
+
+	var out []InputEvent
+	switch {
+	case h.layer != "":
+		if i := slices.Index(l.active, h.layer); i >= 0 {
+			l.active = slices.Delete(l.active, i, i+1)
+		}
+	case h.oneShot:
+		switch i := slices.Index(l.armed, h.out); {
+		case h.wasArmed && i >= 0:
+			// Tapped again: cancel.
+			l.armed = slices.Delete(l.armed, i, i+1)
+			out = append(out, keyEv(h.out, 0))
+		case h.wasArmed:
+			// Another key took the modifier and will release it.
+		case h.used:
+			out = append(out, keyEv(h.out, 0))
+		default:
+			l.armed = append(l.armed, h.out)
+		}
+	case h.out != KEY_RESERVED:
+		out = append(out, keyEv(h.out, 0))
+	}
+	for _, m := range h.release {
+		out = append(out, keyEv(m, 0))
+	}
+	return out
+}
+
+func (l *Layers) repeat(code EvCode) []InputEvent {
+	if p := l.pending; p != nil && p.code == code {
+		return nil
+	}
+	h, ok := l.down[code]
+	if !ok || h.oneShot || h.out == KEY_RESERVED {
+		return nil
+	}
+	return []InputEvent{keyEv(h.out, 2)}
+}
+
+// lookup returns what code emits in the active layers.
+func (l *Layers) lookup(code EvCode) EvCode {
+	for _, layer := range slices.Backward(l.active) {
+		if to, ok := l.bindings[layer][code]; ok {
+			return to
+		}
+	}
+	if to, ok := l.bindings[""][code]; ok {
+		return to
+	}
+	return code
+}
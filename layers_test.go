@@ -0,0 +1,223 @@
+package evdev
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeMapContext is a MapContext on a FakeClock whose timers run inside
+// Advance, collecting what is emitted.
+type fakeMapContext struct {
+	clock *FakeClock
+	evs   []InputEvent
+}
+
+func (c *fakeMapContext) Emit(evs ...InputEvent) { c.evs = append(c.evs, evs...) }
+func (c *fakeMapContext) Source() int            { return 0 }
+func (c *fakeMapContext) Now() time.Time         { return c.clock.Now() }
+func (c *fakeMapContext) AfterFunc(d time.Duration, f func()) Timer {
+	return c.clock.AfterFunc(d, f)
+}
+
+// mapperHarness drives a Mapper through a fakeMapContext, checking what it
+// emits — from Map and from its timers — as "KEY_X value" strings.
+type mapperHarness struct {
+	t   *testing.T
+	m   Mapper
+	ctx *fakeMapContext
+}
+
+func newMapperHarness(t *testing.T, m Mapper) *mapperHarness {
+	ctx := &fakeMapContext{clock: NewFakeClock(time.Unix(0, 0))}
+	return &mapperHarness{t: t, m: m, ctx: ctx}
+}
+
+func newLayersHarness(t *testing.T, opts ...LayersOption) (*mapperHarness, *Layers) {
+	l := NewLayers(opts...)
+	return newMapperHarness(t, l), l
+}
+
+func (h *mapperHarness) key(code EvCode, value int32) {
+	h.m.Map(h.ctx, keyEv(code, value))
+}
+
+func (h *mapperHarness) tap(code EvCode) {
+	h.key(code, 1)
+	h.key(code, 0)
+}
+
+func (h *mapperHarness) wait(ms int) { h.ctx.clock.Advance(time.Duration(ms) * time.Millisecond) }
+
+// expect checks and clears what was emitted.
+func (h *mapperHarness) expect(want ...string) {
+	h.t.Helper()
+	var got []string
+	for _, ev := range h.ctx.evs {
+		if ev.Type != EV_SYN {
+			got = append(got, fmt.Sprintf("%s %d", ev.CodeName(), ev.Value))
+		}
+	}
+	if !slices.Equal(got, want) {
+		h.t.Errorf("emitted %q, want %q", got, want)
+	}
+	h.ctx.evs = nil
+}
+
+func TestLayersDualRole(t *testing.T) {
+	h, l := newLayersHarness(t)
+	l.DualRole(KEY_CAPSLOCK, KEY_ESC, KEY_LEFTCTRL)
+
+	h.key(KEY_CAPSLOCK, 1)
+	h.wait(100)
+	h.key(KEY_CAPSLOCK, 2)
+	h.key(KEY_CAPSLOCK, 0)
+	h.expect("KEY_ESC 1", "KEY_ESC 0")
+
+	// Held past the tapping term: Control, from the timer, before any key.
+	h.key(KEY_CAPSLOCK, 1)
+	h.wait(199)
+	h.expect()
+	h.wait(1)
+	h.expect("KEY_LEFTCTRL 1")
+	h.key(KEY_CAPSLOCK, 2)
+	h.tap(KEY_C)
+	h.key(KEY_CAPSLOCK, 0)
+	h.expect("KEY_LEFTCTRL 2", "KEY_C 1", "KEY_C 0", "KEY_LEFTCTRL 0")
+
+	// By default a key pressed within the term waits for the decision; an
+	// early release makes it a tap.
+	h.key(KEY_CAPSLOCK, 1)
+	h.tap(KEY_C)
+	h.expect()
+	h.key(KEY_CAPSLOCK, 0)
+	h.expect("KEY_ESC 1", "KEY_ESC 0", "KEY_C 1", "KEY_C 0")
+
+	// ... and running out the term makes it a hold.
+	h.key(KEY_CAPSLOCK, 1)
+	h.key(KEY_C, 1)
+	h.wait(200)
+	h.expect("KEY_LEFTCTRL 1", "KEY_C 1")
+	h.key(KEY_CAPSLOCK, 0)
+	h.key(KEY_C, 0)
+	h.expect("KEY_LEFTCTRL 0", "KEY_C 0")
+}
+
+func TestLayersPermissiveHold(t *testing.T) {
+	h, l := newLayersHarness(t, WithPermissiveHold())
+	l.DualRole(KEY_CAPSLOCK, KEY_ESC, KEY_LEFTCTRL)
+
+	h.key(KEY_CAPSLOCK, 1)
+	h.key(KEY_C, 1)
+	h.expect()
+	h.key(KEY_C, 0)
+	h.expect("KEY_LEFTCTRL 1", "KEY_C 1", "KEY_C 0")
+	h.key(KEY_CAPSLOCK, 0)
+	h.expect("KEY_LEFTCTRL 0")
+
+	// Rolling off: the dual-role key released first is still a tap.
+	h.key(KEY_CAPSLOCK, 1)
+	h.key(KEY_C, 1)
+	h.key(KEY_CAPSLOCK, 0)
+	h.key(KEY_C, 0)
+	h.expect("KEY_ESC 1", "KEY_ESC 0", "KEY_C 1", "KEY_C 0")
+
+	// A key pressed before the dual-role one is released straight away.
+	h.key(KEY_A, 1)
+	h.key(KEY_CAPSLOCK, 1)
+	h.key(KEY_A, 0)
+	h.expect("KEY_A 1", "KEY_A 0")
+	h.wait(200)
+	h.expect("KEY_LEFTCTRL 1")
+	h.key(KEY_CAPSLOCK, 0)
+	h.expect("KEY_LEFTCTRL 0")
+}
+
+func TestLayersHoldOnOtherKeyPress(t *testing.T) {
+	h, l := newLayersHarness(t, WithHoldOnOtherKeyPress())
+	l.DualRole(KEY_CAPSLOCK, KEY_ESC, KEY_LEFTCTRL)
+
+	h.key(KEY_CAPSLOCK, 1)
+	h.key(KEY_C, 1)
+	h.expect("KEY_LEFTCTRL 1", "KEY_C 1")
+	h.key(KEY_CAPSLOCK, 0)
+	h.key(KEY_C, 0)
+	h.expect("KEY_LEFTCTRL 0", "KEY_C 0")
+	h.wait(500)
+	h.expect()
+}
+
+func TestLayersLayerTap(t *testing.T) {
+	h, l := newLayersHarness(t, WithHoldOnOtherKeyPress())
+	l.LayerTap(KEY_SPACE, KEY_SPACE, "nav")
+	l.Bind("nav", KEY_H, KEY_LEFT)
+	l.Bind("nav", KEY_J, KEY_RESERVED)
+	l.Bind("", KEY_CAPSLOCK, KEY_ESC)
+
+	h.tap(KEY_SPACE)
+	h.expect("KEY_SPACE 1", "KEY_SPACE 0")
+
+	h.key(KEY_SPACE, 1)
+	h.key(KEY_H, 1)
+	h.key(KEY_H, 2)
+	h.tap(KEY_J)
+	h.tap(KEY_CAPSLOCK)
+	h.key(KEY_SPACE, 0)
+	// A key keeps the binding it was pressed with.
+	h.key(KEY_H, 0)
+	h.tap(KEY_H)
+	h.expect("KEY_LEFT 1", "KEY_LEFT 2", "KEY_ESC 1", "KEY_ESC 0", "KEY_LEFT 0", "KEY_H 1", "KEY_H 0")
+}
+
+func TestLayersOneShot(t *testing.T) {
+	h, l := newLayersHarness(t)
+	l.OneShot(KEY_LEFTSHIFT, KEY_LEFTSHIFT)
+	l.OneShot(KEY_LEFTCTRL, KEY_LEFTCTRL)
+
+	h.tap(KEY_LEFTSHIFT)
+	h.expect("KEY_LEFTSHIFT 1")
+	h.tap(KEY_A)
+	h.tap(KEY_B)
+	h.expect("KEY_A 1", "KEY_A 0", "KEY_LEFTSHIFT 0", "KEY_B 1", "KEY_B 0")
+
+	// Stacked, then applied to one key.
+	h.tap(KEY_LEFTCTRL)
+	h.tap(KEY_LEFTSHIFT)
+	h.tap(KEY_T)
+	h.expect("KEY_LEFTCTRL 1", "KEY_LEFTSHIFT 1", "KEY_T 1", "KEY_T 0", "KEY_LEFTCTRL 0", "KEY_LEFTSHIFT 0")
+
+	// Tapped twice: cancelled.
+	h.tap(KEY_LEFTSHIFT)
+	h.tap(KEY_LEFTSHIFT)
+	h.tap(KEY_A)
+	h.expect("KEY_LEFTSHIFT 1", "KEY_LEFTSHIFT 0", "KEY_A 1", "KEY_A 0")
+
+	// Held while another key is typed: an ordinary modifier.
+	h.key(KEY_LEFTSHIFT, 1)
+	h.tap(KEY_A)
+	h.key(KEY_LEFTSHIFT, 0)
+	h.tap(KEY_B)
+	h.expect("KEY_LEFTSHIFT 1", "KEY_A 1", "KEY_A 0", "KEY_LEFTSHIFT 0", "KEY_B 1", "KEY_B 0")
+}
+
+// TestLayersFrames checks that each key change is its own frame, including
+// those the timer emits.
+func TestLayersFrames(t *testing.T) {
+	h, l := newLayersHarness(t)
+	l.DualRole(KEY_CAPSLOCK, KEY_ESC, KEY_LEFTCTRL)
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+
+	h.key(KEY_CAPSLOCK, 1)
+	h.key(KEY_CAPSLOCK, 0)
+	if want := []InputEvent{keyEv(KEY_ESC, 1), syn, keyEv(KEY_ESC, 0)}; !slices.Equal(h.ctx.evs, want) {
+		t.Errorf("tap emitted %v, want %v", h.ctx.evs, want)
+	}
+	h.ctx.evs = nil
+	h.key(KEY_CAPSLOCK, 1)
+	h.key(KEY_C, 1)
+	h.wait(200)
+	if want := []InputEvent{keyEv(KEY_LEFTCTRL, 1), syn, keyEv(KEY_C, 1)}; !slices.Equal(h.ctx.evs, want) {
+		t.Errorf("timer emitted %v, want %v", h.ctx.evs, want)
+	}
+}
@@ -0,0 +1,215 @@
+package layout
+
+import (
+	"slices"
+	"unicode"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// Built-in layouts, matching the XKB layouts of the same name on a pc105
+// keyboard. Their dead keys are active, as in XKB's default variants.
+var (
+	US     = build("us", nil, usRows...)
+	UK     = build("gb", ukAltGr, ukRows...)
+	DE     = build("de", deAltGr, deRows...)
+	FR     = build("fr", frAltGr, frRows...)
+	Dvorak = build("dvorak", nil, dvorakRows...)
+)
+
+// ByName returns a built-in layout by its XKB name: "us", "gb" (or "uk"),
+// "de", "fr", or "dvorak" (or "us(dvorak)").
+func ByName(name string) (*Layout, bool) {
+	switch name {
+	case "us":
+		return US, true
+	case "gb", "uk":
+		return UK, true
+	case "de":
+		return DE, true
+	case "fr":
+		return FR, true
+	case "dvorak", "us(dvorak)":
+		return Dvorak, true
+	}
+	return nil, false
+}
+
+// The alphanumeric block in ISO order. Each row's level strings give one rune
+// per key: a space means the level types nothing and a combining mark
+// (U+0300-U+036F) is the corresponding dead key. KEY_BACKSLASH ends the home
+// row (the key beside Enter on ISO boards) and KEY_102ND, the extra key beside
+// left Shift, starts the bottom row.
+var (
+	numberRow = []evdev.EvCode{
+		evdev.KEY_GRAVE, evdev.KEY_1, evdev.KEY_2, evdev.KEY_3, evdev.KEY_4, evdev.KEY_5, evdev.KEY_6,
+		evdev.KEY_7, evdev.KEY_8, evdev.KEY_9, evdev.KEY_0, evdev.KEY_MINUS, evdev.KEY_EQUAL,
+	}
+	topRow = []evdev.EvCode{
+		evdev.KEY_Q, evdev.KEY_W, evdev.KEY_E, evdev.KEY_R, evdev.KEY_T, evdev.KEY_Y,
+		evdev.KEY_U, evdev.KEY_I, evdev.KEY_O, evdev.KEY_P, evdev.KEY_LEFTBRACE, evdev.KEY_RIGHTBRACE,
+	}
+	homeRow = []evdev.EvCode{
+		evdev.KEY_A, evdev.KEY_S, evdev.KEY_D, evdev.KEY_F, evdev.KEY_G, evdev.KEY_H,
+		evdev.KEY_J, evdev.KEY_K, evdev.KEY_L, evdev.KEY_SEMICOLON, evdev.KEY_APOSTROPHE, evdev.KEY_BACKSLASH,
+	}
+	bottomRow = []evdev.EvCode{
+		evdev.KEY_102ND, evdev.KEY_Z, evdev.KEY_X, evdev.KEY_C, evdev.KEY_V, evdev.KEY_B,
+		evdev.KEY_N, evdev.KEY_M, evdev.KEY_COMMA, evdev.KEY_DOT, evdev.KEY_SLASH,
+	}
+)
+
+// row assigns level strings (plain, then Shift) to a run of keys.
+type row struct {
+	codes  []evdev.EvCode
+	levels []string
+}
+
+var usRows = []row{
+	{numberRow, []string{"`1234567890-=", "~!@#$%^&*()_+"}},
+	{topRow, []string{"qwertyuiop[]", "QWERTYUIOP{}"}},
+	{homeRow, []string{"asdfghjkl;'\\", "ASDFGHJKL:\"|"}},
+	{bottomRow, []string{"<zxcvbnm,./", ">ZXCVBNM<>?"}},
+}
+
+var ukRows = []row{
+	{numberRow, []string{"`1234567890-=", "¬!\"£$%^&*()_+"}},
+	{topRow, []string{"qwertyuiop[]", "QWERTYUIOP{}"}},
+	{homeRow, []string{"asdfghjkl;'#", "ASDFGHJKL:@~"}},
+	{bottomRow, []string{"\\zxcvbnm,./", "|ZXCVBNM<>?"}},
+}
+
+var ukAltGr = map[evdev.EvCode]string{
+	evdev.KEY_GRAVE: "|",
+	evdev.KEY_4:     "€",
+}
+
+var deRows = []row{
+	{numberRow, []string{"\u03021234567890ß\u0301", "°!\"§$%&/()=?\u0300"}},
+	{topRow, []string{"qwertzuiopü+", "QWERTZUIOPÜ*"}},
+	{homeRow, []string{"asdfghjklöä#", "ASDFGHJKLÖÄ'"}},
+	{bottomRow, []string{"<yxcvbnm,.-", ">YXCVBNM;:_"}},
+	{[]evdev.EvCode{evdev.KEY_KPDOT}, []string{" ", ","}},
+}
+
+var deAltGr = map[evdev.EvCode]string{
+	evdev.KEY_2:          "²",
+	evdev.KEY_3:          "³",
+	evdev.KEY_7:          "{",
+	evdev.KEY_8:          "[",
+	evdev.KEY_9:          "]",
+	evdev.KEY_0:          "}",
+	evdev.KEY_MINUS:      "\\",
+	evdev.KEY_Q:          "@",
+	evdev.KEY_E:          "€",
+	evdev.KEY_RIGHTBRACE: "~",
+	evdev.KEY_102ND:      "|",
+	evdev.KEY_M:          "µ",
+}
+
+var frRows = []row{
+	{numberRow, []string{"²&é\"'(-è_çà)=", " 1234567890°+"}},
+	{topRow, []string{"azertyuiop\u0302$", "AZERTYUIOP\u0308£"}},
+	{homeRow, []string{"qsdfghjklmù*", "QSDFGHJKLM%µ"}},
+	{bottomRow, []string{"<wxcvbn,;:!", ">WXCVBN?./§"}},
+}
+
+var frAltGr = map[evdev.EvCode]string{
+	evdev.KEY_2:          "~",
+	evdev.KEY_3:          "#",
+	evdev.KEY_4:          "{",
+	evdev.KEY_5:          "[",
+	evdev.KEY_6:          "|",
+	evdev.KEY_7:          "`",
+	evdev.KEY_8:          "\\",
+	evdev.KEY_9:          "^",
+	evdev.KEY_0:          "@",
+	evdev.KEY_MINUS:      "]",
+	evdev.KEY_EQUAL:      "}",
+	evdev.KEY_E:          "€",
+	evdev.KEY_RIGHTBRACE: "¤",
+}
+
+var dvorakRows = []row{
+	{numberRow, []string{"`1234567890[]", "~!@#$%^&*(){}"}},
+	{topRow, []string{"',.pyfgcrl/=", "\"<>PYFGCRL?+"}},
+	{homeRow, []string{"aoeuidhtns-\\", "AOEUIDHTNS_|"}},
+	{bottomRow, []string{"<;qjkxbmwvz", ">:QJKXBMWVZ"}},
+}
+
+// commonRows are the keys every built-in layout shares: control characters
+// and the keypad (digits on its Num Lock level). Space is added by build, as a
+// space in a row string means "nothing".
+var commonRows = []row{
+	{
+		[]evdev.EvCode{evdev.KEY_ENTER, evdev.KEY_KPENTER, evdev.KEY_TAB, evdev.KEY_BACKSPACE, evdev.KEY_ESC, evdev.KEY_DELETE},
+		[]string{"\n\n\t\b\x1b\x7f"},
+	},
+	{
+		[]evdev.EvCode{evdev.KEY_KPSLASH, evdev.KEY_KPASTERISK, evdev.KEY_KPMINUS, evdev.KEY_KPPLUS},
+		[]string{"/*-+"},
+	},
+	{
+		[]evdev.EvCode{
+			evdev.KEY_KP0, evdev.KEY_KP1, evdev.KEY_KP2, evdev.KEY_KP3, evdev.KEY_KP4, evdev.KEY_KP5,
+			evdev.KEY_KP6, evdev.KEY_KP7, evdev.KEY_KP8, evdev.KEY_KP9, evdev.KEY_KPDOT,
+		},
+		[]string{"           ", "0123456789."},
+	},
+}
+
+// keypadKeys are the keys whose digit level Num Lock selects.
+var keypadKeys = map[evdev.EvCode]bool{
+	evdev.KEY_KP0: true, evdev.KEY_KP1: true, evdev.KEY_KP2: true, evdev.KEY_KP3: true,
+	evdev.KEY_KP4: true, evdev.KEY_KP5: true, evdev.KEY_KP6: true, evdev.KEY_KP7: true,
+	evdev.KEY_KP8: true, evdev.KEY_KP9: true, evdev.KEY_KPDOT: true,
+}
+
+// build assembles a built-in layout from the common keys, its rows (which may
+// override common keys), and its AltGr symbols, given per key as a string of
+// the AltGr and Shift+AltGr levels. A nil altGr map means the layout has no
+// AltGr key.
+func build(name string, altGr map[evdev.EvCode]string, rows ...row) *Layout {
+	keys := map[evdev.EvCode]Key{
+		evdev.KEY_SPACE: {Levels: [4]Sym{{Rune: ' '}}},
+	}
+	for _, r := range slices.Concat(commonRows, rows) {
+		for lvl, s := range r.levels {
+			for i, ch := range []rune(s) {
+				k := keys[r.codes[i]]
+				k.Levels[lvl] = symOf(ch)
+				keys[r.codes[i]] = k
+			}
+		}
+	}
+	for code, s := range altGr {
+		k := keys[code]
+		for i, ch := range []rune(s) {
+			k.Levels[2+i] = symOf(ch)
+		}
+		keys[code] = k
+	}
+	for code, k := range keys {
+		base, shifted := k.Levels[0].Rune, k.Levels[1].Rune
+		k.Alphabetic = unicode.IsLower(base) && shifted == unicode.ToUpper(base)
+		k.Keypad = keypadKeys[code]
+		keys[code] = k
+	}
+
+	var altGrKey evdev.EvCode
+	if altGr != nil {
+		altGrKey = evdev.KEY_RIGHTALT
+	}
+	return &Layout{name: name, keys: keys, altGr: altGrKey}
+}
+
+// symOf interprets one rune of a row string.
+func symOf(ch rune) Sym {
+	switch {
+	case ch == ' ':
+		return Sym{}
+	case ch >= 0x300 && ch <= 0x36f:
+		return Sym{Rune: ch, Dead: true}
+	}
+	return Sym{Rune: ch}
+}
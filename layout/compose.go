@@ -0,0 +1,46 @@
+package layout
+
+// accent is one dead key's behaviour: the spacing character it types on its
+// own (dead key then Space, or pressed twice) and the precomposed characters
+// it forms with base letters.
+type accent struct {
+	spacing  rune
+	composed map[rune]rune
+}
+
+// accents is keyed by the dead key's combining mark (Sym.Rune).
+var accents = map[rune]accent{
+	'\u0300': newAccent('`', "aàeèiìoòuùAÀEÈIÌOÒUÙ"),                     // grave
+	'\u0301': newAccent('´', "aáeéiíoóuúyýcćnńsśzźAÁEÉIÍOÓUÚYÝCĆNŃSŚZŹ"), // acute
+	'\u0302': newAccent('^', "aâeêiîoôuûAÂEÊIÎOÔUÛ"),                     // circumflex
+	'\u0303': newAccent('~', "aãnñoõAÃNÑOÕ"),                             // tilde
+	'\u0308': newAccent('¨', "aäeëiïoöuüyÿAÄEËIÏOÖUÜ"),                   // diaeresis
+	'\u030a': newAccent('°', "aåuůAÅUŮ"),                                 // ring above
+	'\u030c': newAccent('ˇ', "cčsšzžeěrřnňCČSŠZŽEĚRŘNŇ"),                 // caron
+	'\u0327': newAccent('¸', "cçCÇ"),                                     // cedilla
+}
+
+// newAccent builds an accent from pairs of base and composed characters.
+func newAccent(spacing rune, pairs string) accent {
+	a := accent{spacing: spacing, composed: map[rune]rune{}}
+	rs := []rune(pairs)
+	for i := 0; i+1 < len(rs); i += 2 {
+		a.composed[rs[i]] = rs[i+1]
+	}
+	return a
+}
+
+// spacingOf returns the character a dead key types on its own.
+func spacingOf(mark rune) rune {
+	if a, ok := accents[mark]; ok {
+		return a.spacing
+	}
+	return mark
+}
+
+// compose applies a dead key's accent to base, reporting whether the pair has
+// a precomposed form.
+func compose(mark, base rune) (rune, bool) {
+	r, ok := accents[mark].composed[base]
+	return r, ok
+}
@@ -0,0 +1,1643 @@
+// Code generated by internal/genkeysyms; DO NOT EDIT.
+
+package layout
+
+// keysymRunes maps X11 keysym names to the character they type.
+var keysymRunes = map[string]rune{
+	"0":                           0x0030,
+	"1":                           0x0031,
+	"2":                           0x0032,
+	"3":                           0x0033,
+	"4":                           0x0034,
+	"5":                           0x0035,
+	"6":                           0x0036,
+	"7":                           0x0037,
+	"8":                           0x0038,
+	"9":                           0x0039,
+	"A":                           0x0041,
+	"AE":                          0x00c6,
+	"Aacute":                      0x00c1,
+	"Abelowdot":                   0x1ea0,
+	"Abreve":                      0x0102,
+	"Abreveacute":                 0x1eae,
+	"Abrevebelowdot":              0x1eb6,
+	"Abrevegrave":                 0x1eb0,
+	"Abrevehook":                  0x1eb2,
+	"Abrevetilde":                 0x1eb4,
+	"Acircumflex":                 0x00c2,
+	"Acircumflexacute":            0x1ea4,
+	"Acircumflexbelowdot":         0x1eac,
+	"Acircumflexgrave":            0x1ea6,
+	"Acircumflexhook":             0x1ea8,
+	"Acircumflextilde":            0x1eaa,
+	"Adiaeresis":                  0x00c4,
+	"Agrave":                      0x00c0,
+	"Ahook":                       0x1ea2,
+	"Amacron":                     0x0100,
+	"Aogonek":                     0x0104,
+	"Arabic_0":                    0x0660,
+	"Arabic_1":                    0x0661,
+	"Arabic_2":                    0x0662,
+	"Arabic_3":                    0x0663,
+	"Arabic_4":                    0x0664,
+	"Arabic_5":                    0x0665,
+	"Arabic_6":                    0x0666,
+	"Arabic_7":                    0x0667,
+	"Arabic_8":                    0x0668,
+	"Arabic_9":                    0x0669,
+	"Arabic_ain":                  0x0639,
+	"Arabic_alef":                 0x0627,
+	"Arabic_alefmaksura":          0x0649,
+	"Arabic_beh":                  0x0628,
+	"Arabic_comma":                0x060c,
+	"Arabic_dad":                  0x0636,
+	"Arabic_dal":                  0x062f,
+	"Arabic_damma":                0x064f,
+	"Arabic_dammatan":             0x064c,
+	"Arabic_ddal":                 0x0688,
+	"Arabic_farsi_yeh":            0x06cc,
+	"Arabic_fatha":                0x064e,
+	"Arabic_fathatan":             0x064b,
+	"Arabic_feh":                  0x0641,
+	"Arabic_fullstop":             0x06d4,
+	"Arabic_gaf":                  0x06af,
+	"Arabic_ghain":                0x063a,
+	"Arabic_ha":                   0x0647,
+	"Arabic_hah":                  0x062d,
+	"Arabic_hamza":                0x0621,
+	"Arabic_hamza_above":          0x0654,
+	"Arabic_hamza_below":          0x0655,
+	"Arabic_hamzaonalef":          0x0623,
+	"Arabic_hamzaonwaw":           0x0624,
+	"Arabic_hamzaonyeh":           0x0626,
+	"Arabic_hamzaunderalef":       0x0625,
+	"Arabic_heh_doachashmee":      0x06be,
+	"Arabic_heh_goal":             0x06c1,
+	"Arabic_jeem":                 0x062c,
+	"Arabic_jeh":                  0x0698,
+	"Arabic_kaf":                  0x0643,
+	"Arabic_kasra":                0x0650,
+	"Arabic_kasratan":             0x064d,
+	"Arabic_keheh":                0x06a9,
+	"Arabic_khah":                 0x062e,
+	"Arabic_lam":                  0x0644,
+	"Arabic_madda_above":          0x0653,
+	"Arabic_maddaonalef":          0x0622,
+	"Arabic_meem":                 0x0645,
+	"Arabic_noon":                 0x0646,
+	"Arabic_noon_ghunna":          0x06ba,
+	"Arabic_peh":                  0x067e,
+	"Arabic_percent":              0x066a,
+	"Arabic_qaf":                  0x0642,
+	"Arabic_question_mark":        0x061f,
+	"Arabic_ra":                   0x0631,
+	"Arabic_rreh":                 0x0691,
+	"Arabic_sad":                  0x0635,
+	"Arabic_seen":                 0x0633,
+	"Arabic_semicolon":            0x061b,
+	"Arabic_shadda":               0x0651,
+	"Arabic_sheen":                0x0634,
+	"Arabic_sukun":                0x0652,
+	"Arabic_superscript_alef":     0x0670,
+	"Arabic_tah":                  0x0637,
+	"Arabic_tatweel":              0x0640,
+	"Arabic_tcheh":                0x0686,
+	"Arabic_teh":                  0x062a,
+	"Arabic_tehmarbuta":           0x0629,
+	"Arabic_thal":                 0x0630,
+	"Arabic_theh":                 0x062b,
+	"Arabic_tteh":                 0x0679,
+	"Arabic_veh":                  0x06a4,
+	"Arabic_waw":                  0x0648,
+	"Arabic_yeh":                  0x064a,
+	"Arabic_yeh_baree":            0x06d2,
+	"Arabic_zah":                  0x0638,
+	"Arabic_zain":                 0x0632,
+	"Aring":                       0x00c5,
+	"Armenian_AT":                 0x0538,
+	"Armenian_AYB":                0x0531,
+	"Armenian_BEN":                0x0532,
+	"Armenian_CHA":                0x0549,
+	"Armenian_DA":                 0x0534,
+	"Armenian_DZA":                0x0541,
+	"Armenian_E":                  0x0537,
+	"Armenian_FE":                 0x0556,
+	"Armenian_GHAT":               0x0542,
+	"Armenian_GIM":                0x0533,
+	"Armenian_HI":                 0x0545,
+	"Armenian_HO":                 0x0540,
+	"Armenian_INI":                0x053b,
+	"Armenian_JE":                 0x054b,
+	"Armenian_KE":                 0x0554,
+	"Armenian_KEN":                0x053f,
+	"Armenian_KHE":                0x053d,
+	"Armenian_LYUN":               0x053c,
+	"Armenian_MEN":                0x0544,
+	"Armenian_NU":                 0x0546,
+	"Armenian_O":                  0x0555,
+	"Armenian_PE":                 0x054a,
+	"Armenian_PYUR":               0x0553,
+	"Armenian_RA":                 0x054c,
+	"Armenian_RE":                 0x0550,
+	"Armenian_SE":                 0x054d,
+	"Armenian_SHA":                0x0547,
+	"Armenian_TCHE":               0x0543,
+	"Armenian_TO":                 0x0539,
+	"Armenian_TSA":                0x053e,
+	"Armenian_TSO":                0x0551,
+	"Armenian_TYUN":               0x054f,
+	"Armenian_VEV":                0x054e,
+	"Armenian_VO":                 0x0548,
+	"Armenian_VYUN":               0x0552,
+	"Armenian_YECH":               0x0535,
+	"Armenian_ZA":                 0x0536,
+	"Armenian_ZHE":                0x053a,
+	"Armenian_accent":             0x055b,
+	"Armenian_amanak":             0x055c,
+	"Armenian_apostrophe":         0x055a,
+	"Armenian_at":                 0x0568,
+	"Armenian_ayb":                0x0561,
+	"Armenian_ben":                0x0562,
+	"Armenian_but":                0x055d,
+	"Armenian_cha":                0x0579,
+	"Armenian_da":                 0x0564,
+	"Armenian_dza":                0x0571,
+	"Armenian_e":                  0x0567,
+	"Armenian_exclam":             0x055c,
+	"Armenian_fe":                 0x0586,
+	"Armenian_full_stop":          0x0589,
+	"Armenian_ghat":               0x0572,
+	"Armenian_gim":                0x0563,
+	"Armenian_hi":                 0x0575,
+	"Armenian_ho":                 0x0570,
+	"Armenian_hyphen":             0x058a,
+	"Armenian_ini":                0x056b,
+	"Armenian_je":                 0x057b,
+	"Armenian_ke":                 0x0584,
+	"Armenian_ken":                0x056f,
+	"Armenian_khe":                0x056d,
+	"Armenian_ligature_ew":        0x0587,
+	"Armenian_lyun":               0x056c,
+	"Armenian_men":                0x0574,
+	"Armenian_nu":                 0x0576,
+	"Armenian_o":                  0x0585,
+	"Armenian_paruyk":             0x055e,
+	"Armenian_pe":                 0x057a,
+	"Armenian_pyur":               0x0583,
+	"Armenian_question":           0x055e,
+	"Armenian_ra":                 0x057c,
+	"Armenian_re":                 0x0580,
+	"Armenian_se":                 0x057d,
+	"Armenian_separation_mark":    0x055d,
+	"Armenian_sha":                0x0577,
+	"Armenian_shesht":             0x055b,
+	"Armenian_tche":               0x0573,
+	"Armenian_to":                 0x0569,
+	"Armenian_tsa":                0x056e,
+	"Armenian_tso":                0x0581,
+	"Armenian_tyun":               0x057f,
+	"Armenian_verjaket":           0x0589,
+	"Armenian_vev":                0x057e,
+	"Armenian_vo":                 0x0578,
+	"Armenian_vyun":               0x0582,
+	"Armenian_yech":               0x0565,
+	"Armenian_yentamna":           0x058a,
+	"Armenian_za":                 0x0566,
+	"Armenian_zhe":                0x056a,
+	"Atilde":                      0x00c3,
+	"B":                           0x0042,
+	"Babovedot":                   0x1e02,
+	"Byelorussian_SHORTU":         0x040e,
+	"Byelorussian_shortu":         0x045e,
+	"C":                           0x0043,
+	"Cabovedot":                   0x010a,
+	"Cacute":                      0x0106,
+	"Ccaron":                      0x010c,
+	"Ccedilla":                    0x00c7,
+	"Ccircumflex":                 0x0108,
+	"ColonSign":                   0x20a1,
+	"CruzeiroSign":                0x20a2,
+	"Cyrillic_A":                  0x0410,
+	"Cyrillic_BE":                 0x0411,
+	"Cyrillic_CHE":                0x0427,
+	"Cyrillic_CHE_descender":      0x04b6,
+	"Cyrillic_CHE_vertstroke":     0x04b8,
+	"Cyrillic_DE":                 0x0414,
+	"Cyrillic_DZHE":               0x040f,
+	"Cyrillic_E":                  0x042d,
+	"Cyrillic_EF":                 0x0424,
+	"Cyrillic_EL":                 0x041b,
+	"Cyrillic_EM":                 0x041c,
+	"Cyrillic_EN":                 0x041d,
+	"Cyrillic_EN_descender":       0x04a2,
+	"Cyrillic_ER":                 0x0420,
+	"Cyrillic_ES":                 0x0421,
+	"Cyrillic_GHE":                0x0413,
+	"Cyrillic_GHE_bar":            0x0492,
+	"Cyrillic_HA":                 0x0425,
+	"Cyrillic_HARDSIGN":           0x042a,
+	"Cyrillic_HA_descender":       0x04b2,
+	"Cyrillic_I":                  0x0418,
+	"Cyrillic_IE":                 0x0415,
+	"Cyrillic_IO":                 0x0401,
+	"Cyrillic_I_macron":           0x04e2,
+	"Cyrillic_JE":                 0x0408,
+	"Cyrillic_KA":                 0x041a,
+	"Cyrillic_KA_descender":       0x049a,
+	"Cyrillic_KA_vertstroke":      0x049c,
+	"Cyrillic_LJE":                0x0409,
+	"Cyrillic_NJE":                0x040a,
+	"Cyrillic_O":                  0x041e,
+	"Cyrillic_O_bar":              0x04e8,
+	"Cyrillic_PE":                 0x041f,
+	"Cyrillic_SCHWA":              0x04d8,
+	"Cyrillic_SHA":                0x0428,
+	"Cyrillic_SHCHA":              0x0429,
+	"Cyrillic_SHHA":               0x04ba,
+	"Cyrillic_SHORTI":             0x0419,
+	"Cyrillic_SOFTSIGN":           0x042c,
+	"Cyrillic_TE":                 0x0422,
+	"Cyrillic_TSE":                0x0426,
+	"Cyrillic_U":                  0x0423,
+	"Cyrillic_U_macron":           0x04ee,
+	"Cyrillic_U_straight":         0x04ae,
+	"Cyrillic_U_straight_bar":     0x04b0,
+	"Cyrillic_VE":                 0x0412,
+	"Cyrillic_YA":                 0x042f,
+	"Cyrillic_YERU":               0x042b,
+	"Cyrillic_YU":                 0x042e,
+	"Cyrillic_ZE":                 0x0417,
+	"Cyrillic_ZHE":                0x0416,
+	"Cyrillic_ZHE_descender":      0x0496,
+	"Cyrillic_a":                  0x0430,
+	"Cyrillic_be":                 0x0431,
+	"Cyrillic_che":                0x0447,
+	"Cyrillic_che_descender":      0x04b7,
+	"Cyrillic_che_vertstroke":     0x04b9,
+	"Cyrillic_de":                 0x0434,
+	"Cyrillic_dzhe":               0x045f,
+	"Cyrillic_e":                  0x044d,
+	"Cyrillic_ef":                 0x0444,
+	"Cyrillic_el":                 0x043b,
+	"Cyrillic_em":                 0x043c,
+	"Cyrillic_en":                 0x043d,
+	"Cyrillic_en_descender":       0x04a3,
+	"Cyrillic_er":                 0x0440,
+	"Cyrillic_es":                 0x0441,
+	"Cyrillic_ghe":                0x0433,
+	"Cyrillic_ghe_bar":            0x0493,
+	"Cyrillic_ha":                 0x0445,
+	"Cyrillic_ha_descender":       0x04b3,
+	"Cyrillic_hardsign":           0x044a,
+	"Cyrillic_i":                  0x0438,
+	"Cyrillic_i_macron":           0x04e3,
+	"Cyrillic_ie":                 0x0435,
+	"Cyrillic_io":                 0x0451,
+	"Cyrillic_je":                 0x0458,
+	"Cyrillic_ka":                 0x043a,
+	"Cyrillic_ka_descender":       0x049b,
+	"Cyrillic_ka_vertstroke":      0x049d,
+	"Cyrillic_lje":                0x0459,
+	"Cyrillic_nje":                0x045a,
+	"Cyrillic_o":                  0x043e,
+	"Cyrillic_o_bar":              0x04e9,
+	"Cyrillic_pe":                 0x043f,
+	"Cyrillic_schwa":              0x04d9,
+	"Cyrillic_sha":                0x0448,
+	"Cyrillic_shcha":              0x0449,
+	"Cyrillic_shha":               0x04bb,
+	"Cyrillic_shorti":             0x0439,
+	"Cyrillic_softsign":           0x044c,
+	"Cyrillic_te":                 0x0442,
+	"Cyrillic_tse":                0x0446,
+	"Cyrillic_u":                  0x0443,
+	"Cyrillic_u_macron":           0x04ef,
+	"Cyrillic_u_straight":         0x04af,
+	"Cyrillic_u_straight_bar":     0x04b1,
+	"Cyrillic_ve":                 0x0432,
+	"Cyrillic_ya":                 0x044f,
+	"Cyrillic_yeru":               0x044b,
+	"Cyrillic_yu":                 0x044e,
+	"Cyrillic_ze":                 0x0437,
+	"Cyrillic_zhe":                0x0436,
+	"Cyrillic_zhe_descender":      0x0497,
+	"D":                           0x0044,
+	"Dabovedot":                   0x1e0a,
+	"Dcaron":                      0x010e,
+	"DongSign":                    0x20ab,
+	"Dstroke":                     0x0110,
+	"E":                           0x0045,
+	"ENG":                         0x014a,
+	"ETH":                         0x00d0,
+	"EZH":                         0x01b7,
+	"Eabovedot":                   0x0116,
+	"Eacute":                      0x00c9,
+	"Ebelowdot":                   0x1eb8,
+	"Ecaron":                      0x011a,
+	"Ecircumflex":                 0x00ca,
+	"Ecircumflexacute":            0x1ebe,
+	"Ecircumflexbelowdot":         0x1ec6,
+	"Ecircumflexgrave":            0x1ec0,
+	"Ecircumflexhook":             0x1ec2,
+	"Ecircumflextilde":            0x1ec4,
+	"EcuSign":                     0x20a0,
+	"Ediaeresis":                  0x00cb,
+	"Egrave":                      0x00c8,
+	"Ehook":                       0x1eba,
+	"Emacron":                     0x0112,
+	"Eogonek":                     0x0118,
+	"Etilde":                      0x1ebc,
+	"EuroSign":                    0x20ac,
+	"F":                           0x0046,
+	"FFrancSign":                  0x20a3,
+	"Fabovedot":                   0x1e1e,
+	"Farsi_0":                     0x06f0,
+	"Farsi_1":                     0x06f1,
+	"Farsi_2":                     0x06f2,
+	"Farsi_3":                     0x06f3,
+	"Farsi_4":                     0x06f4,
+	"Farsi_5":                     0x06f5,
+	"Farsi_6":                     0x06f6,
+	"Farsi_7":                     0x06f7,
+	"Farsi_8":                     0x06f8,
+	"Farsi_9":                     0x06f9,
+	"Farsi_yeh":                   0x06cc,
+	"G":                           0x0047,
+	"Gabovedot":                   0x0120,
+	"Gbreve":                      0x011e,
+	"Gcaron":                      0x01e6,
+	"Gcedilla":                    0x0122,
+	"Gcircumflex":                 0x011c,
+	"Georgian_an":                 0x10d0,
+	"Georgian_ban":                0x10d1,
+	"Georgian_can":                0x10ea,
+	"Georgian_char":               0x10ed,
+	"Georgian_chin":               0x10e9,
+	"Georgian_cil":                0x10ec,
+	"Georgian_don":                0x10d3,
+	"Georgian_en":                 0x10d4,
+	"Georgian_fi":                 0x10f6,
+	"Georgian_gan":                0x10d2,
+	"Georgian_ghan":               0x10e6,
+	"Georgian_hae":                0x10f0,
+	"Georgian_har":                0x10f4,
+	"Georgian_he":                 0x10f1,
+	"Georgian_hie":                0x10f2,
+	"Georgian_hoe":                0x10f5,
+	"Georgian_in":                 0x10d8,
+	"Georgian_jhan":               0x10ef,
+	"Georgian_jil":                0x10eb,
+	"Georgian_kan":                0x10d9,
+	"Georgian_khar":               0x10e5,
+	"Georgian_las":                0x10da,
+	"Georgian_man":                0x10db,
+	"Georgian_nar":                0x10dc,
+	"Georgian_on":                 0x10dd,
+	"Georgian_par":                0x10de,
+	"Georgian_phar":               0x10e4,
+	"Georgian_qar":                0x10e7,
+	"Georgian_rae":                0x10e0,
+	"Georgian_san":                0x10e1,
+	"Georgian_shin":               0x10e8,
+	"Georgian_tan":                0x10d7,
+	"Georgian_tar":                0x10e2,
+	"Georgian_un":                 0x10e3,
+	"Georgian_vin":                0x10d5,
+	"Georgian_we":                 0x10f3,
+	"Georgian_xan":                0x10ee,
+	"Georgian_zen":                0x10d6,
+	"Georgian_zhar":               0x10df,
+	"Greek_ALPHA":                 0x0391,
+	"Greek_ALPHAaccent":           0x0386,
+	"Greek_BETA":                  0x0392,
+	"Greek_CHI":                   0x03a7,
+	"Greek_DELTA":                 0x0394,
+	"Greek_EPSILON":               0x0395,
+	"Greek_EPSILONaccent":         0x0388,
+	"Greek_ETA":                   0x0397,
+	"Greek_ETAaccent":             0x0389,
+	"Greek_GAMMA":                 0x0393,
+	"Greek_IOTA":                  0x0399,
+	"Greek_IOTAaccent":            0x038a,
+	"Greek_IOTAdieresis":          0x03aa,
+	"Greek_KAPPA":                 0x039a,
+	"Greek_LAMBDA":                0x039b,
+	"Greek_LAMDA":                 0x039b,
+	"Greek_MU":                    0x039c,
+	"Greek_NU":                    0x039d,
+	"Greek_OMEGA":                 0x03a9,
+	"Greek_OMEGAaccent":           0x038f,
+	"Greek_OMICRON":               0x039f,
+	"Greek_OMICRONaccent":         0x038c,
+	"Greek_PHI":                   0x03a6,
+	"Greek_PI":                    0x03a0,
+	"Greek_PSI":                   0x03a8,
+	"Greek_RHO":                   0x03a1,
+	"Greek_SIGMA":                 0x03a3,
+	"Greek_TAU":                   0x03a4,
+	"Greek_THETA":                 0x0398,
+	"Greek_UPSILON":               0x03a5,
+	"Greek_UPSILONaccent":         0x038e,
+	"Greek_UPSILONdieresis":       0x03ab,
+	"Greek_XI":                    0x039e,
+	"Greek_ZETA":                  0x0396,
+	"Greek_accentdieresis":        0x0385,
+	"Greek_alpha":                 0x03b1,
+	"Greek_alphaaccent":           0x03ac,
+	"Greek_beta":                  0x03b2,
+	"Greek_chi":                   0x03c7,
+	"Greek_delta":                 0x03b4,
+	"Greek_epsilon":               0x03b5,
+	"Greek_epsilonaccent":         0x03ad,
+	"Greek_eta":                   0x03b7,
+	"Greek_etaaccent":             0x03ae,
+	"Greek_finalsmallsigma":       0x03c2,
+	"Greek_gamma":                 0x03b3,
+	"Greek_horizbar":              0x2015,
+	"Greek_iota":                  0x03b9,
+	"Greek_iotaaccent":            0x03af,
+	"Greek_iotaaccentdieresis":    0x0390,
+	"Greek_iotadieresis":          0x03ca,
+	"Greek_kappa":                 0x03ba,
+	"Greek_lambda":                0x03bb,
+	"Greek_lamda":                 0x03bb,
+	"Greek_mu":                    0x03bc,
+	"Greek_nu":                    0x03bd,
+	"Greek_omega":                 0x03c9,
+	"Greek_omegaaccent":           0x03ce,
+	"Greek_omicron":               0x03bf,
+	"Greek_omicronaccent":         0x03cc,
+	"Greek_phi":                   0x03c6,
+	"Greek_pi":                    0x03c0,
+	"Greek_psi":                   0x03c8,
+	"Greek_rho":                   0x03c1,
+	"Greek_sigma":                 0x03c3,
+	"Greek_tau":                   0x03c4,
+	"Greek_theta":                 0x03b8,
+	"Greek_upsilon":               0x03c5,
+	"Greek_upsilonaccent":         0x03cd,
+	"Greek_upsilonaccentdieresis": 0x03b0,
+	"Greek_upsilondieresis":       0x03cb,
+	"Greek_xi":                    0x03be,
+	"Greek_zeta":                  0x03b6,
+	"H":                           0x0048,
+	"Hangul_A":                    0x314f,
+	"Hangul_AE":                   0x3150,
+	"Hangul_AraeA":                0x318d,
+	"Hangul_AraeAE":               0x318e,
+	"Hangul_Cieuc":                0x314a,
+	"Hangul_Dikeud":               0x3137,
+	"Hangul_E":                    0x3154,
+	"Hangul_EO":                   0x3153,
+	"Hangul_EU":                   0x3161,
+	"Hangul_Hieuh":                0x314e,
+	"Hangul_I":                    0x3163,
+	"Hangul_Ieung":                0x3147,
+	"Hangul_J_Cieuc":              0x11be,
+	"Hangul_J_Dikeud":             0x11ae,
+	"Hangul_J_Hieuh":              0x11c2,
+	"Hangul_J_Ieung":              0x11bc,
+	"Hangul_J_Jieuj":              0x11bd,
+	"Hangul_J_Khieuq":             0x11bf,
+	"Hangul_J_Kiyeog":             0x11a8,
+	"Hangul_J_KiyeogSios":         0x11aa,
+	"Hangul_J_KkogjiDalrinIeung":  0x11f0,
+	"Hangul_J_Mieum":              0x11b7,
+	"Hangul_J_Nieun":              0x11ab,
+	"Hangul_J_NieunHieuh":         0x11ad,
+	"Hangul_J_NieunJieuj":         0x11ac,
+	"Hangul_J_PanSios":            0x11eb,
+	"Hangul_J_Phieuf":             0x11c1,
+	"Hangul_J_Pieub":              0x11b8,
+	"Hangul_J_PieubSios":          0x11b9,
+	"Hangul_J_Rieul":              0x11af,
+	"Hangul_J_RieulHieuh":         0x11b6,
+	"Hangul_J_RieulKiyeog":        0x11b0,
+	"Hangul_J_RieulMieum":         0x11b1,
+	"Hangul_J_RieulPhieuf":        0x11b5,
+	"Hangul_J_RieulPieub":         0x11b2,
+	"Hangul_J_RieulSios":          0x11b3,
+	"Hangul_J_RieulTieut":         0x11b4,
+	"Hangul_J_Sios":               0x11ba,
+	"Hangul_J_SsangKiyeog":        0x11a9,
+	"Hangul_J_SsangSios":          0x11bb,
+	"Hangul_J_Tieut":              0x11c0,
+	"Hangul_J_YeorinHieuh":        0x11f9,
+	"Hangul_Jieuj":                0x3148,
+	"Hangul_Khieuq":               0x314b,
+	"Hangul_Kiyeog":               0x3131,
+	"Hangul_KiyeogSios":           0x3133,
+	"Hangul_KkogjiDalrinIeung":    0x3181,
+	"Hangul_Mieum":                0x3141,
+	"Hangul_Nieun":                0x3134,
+	"Hangul_NieunHieuh":           0x3136,
+	"Hangul_NieunJieuj":           0x3135,
+	"Hangul_O":                    0x3157,
+	"Hangul_OE":                   0x315a,
+	"Hangul_PanSios":              0x317f,
+	"Hangul_Phieuf":               0x314d,
+	"Hangul_Pieub":                0x3142,
+	"Hangul_PieubSios":            0x3144,
+	"Hangul_Rieul":                0x3139,
+	"Hangul_RieulHieuh":           0x3140,
+	"Hangul_RieulKiyeog":          0x313a,
+	"Hangul_RieulMieum":           0x313b,
+	"Hangul_RieulPhieuf":          0x313f,
+	"Hangul_RieulPieub":           0x313c,
+	"Hangul_RieulSios":            0x313d,
+	"Hangul_RieulTieut":           0x313e,
+	"Hangul_RieulYeorinHieuh":     0x316d,
+	"Hangul_Sios":                 0x3145,
+	"Hangul_SsangDikeud":          0x3138,
+	"Hangul_SsangJieuj":           0x3149,
+	"Hangul_SsangKiyeog":          0x3132,
+	"Hangul_SsangPieub":           0x3143,
+	"Hangul_SsangSios":            0x3146,
+	"Hangul_SunkyeongeumMieum":    0x3171,
+	"Hangul_SunkyeongeumPhieuf":   0x3184,
+	"Hangul_SunkyeongeumPieub":    0x3178,
+	"Hangul_Tieut":                0x314c,
+	"Hangul_U":                    0x315c,
+	"Hangul_WA":                   0x3158,
+	"Hangul_WAE":                  0x3159,
+	"Hangul_WE":                   0x315e,
+	"Hangul_WEO":                  0x315d,
+	"Hangul_WI":                   0x315f,
+	"Hangul_YA":                   0x3151,
+	"Hangul_YAE":                  0x3152,
+	"Hangul_YE":                   0x3156,
+	"Hangul_YEO":                  0x3155,
+	"Hangul_YI":                   0x3162,
+	"Hangul_YO":                   0x315b,
+	"Hangul_YU":                   0x3160,
+	"Hangul_YeorinHieuh":          0x3186,
+	"Hcircumflex":                 0x0124,
+	"Hstroke":                     0x0126,
+	"I":                           0x0049,
+	"Iabovedot":                   0x0130,
+	"Iacute":                      0x00cd,
+	"Ibelowdot":                   0x1eca,
+	"Ibreve":                      0x012c,
+	"Icircumflex":                 0x00ce,
+	"Idiaeresis":                  0x00cf,
+	"Igrave":                      0x00cc,
+	"Ihook":                       0x1ec8,
+	"Imacron":                     0x012a,
+	"Iogonek":                     0x012e,
+	"Itilde":                      0x0128,
+	"J":                           0x004a,
+	"Jcircumflex":                 0x0134,
+	"K":                           0x004b,
+	"Kcedilla":                    0x0136,
+	"L":                           0x004c,
+	"Lacute":                      0x0139,
+	"Lbelowdot":                   0x1e36,
+	"Lcaron":                      0x013d,
+	"Lcedilla":                    0x013b,
+	"LiraSign":                    0x20a4,
+	"Lstroke":                     0x0141,
+	"M":                           0x004d,
+	"Mabovedot":                   0x1e40,
+	"Macedonia_DSE":               0x0405,
+	"Macedonia_GJE":               0x0403,
+	"Macedonia_KJE":               0x040c,
+	"Macedonia_dse":               0x0455,
+	"Macedonia_gje":               0x0453,
+	"Macedonia_kje":               0x045c,
+	"MillSign":                    0x20a5,
+	"N":                           0x004e,
+	"Nacute":                      0x0143,
+	"NairaSign":                   0x20a6,
+	"Ncaron":                      0x0147,
+	"Ncedilla":                    0x0145,
+	"NewSheqelSign":               0x20aa,
+	"Ntilde":                      0x00d1,
+	"O":                           0x004f,
+	"OE":                          0x0152,
+	"Oacute":                      0x00d3,
+	"Obarred":                     0x019f,
+	"Obelowdot":                   0x1ecc,
+	"Ocaron":                      0x01d1,
+	"Ocircumflex":                 0x00d4,
+	"Ocircumflexacute":            0x1ed0,
+	"Ocircumflexbelowdot":         0x1ed8,
+	"Ocircumflexgrave":            0x1ed2,
+	"Ocircumflexhook":             0x1ed4,
+	"Ocircumflextilde":            0x1ed6,
+	"Odiaeresis":                  0x00d6,
+	"Odoubleacute":                0x0150,
+	"Ograve":                      0x00d2,
+	"Ohook":                       0x1ece,
+	"Ohorn":                       0x01a0,
+	"Ohornacute":                  0x1eda,
+	"Ohornbelowdot":               0x1ee2,
+	"Ohorngrave":                  0x1edc,
+	"Ohornhook":                   0x1ede,
+	"Ohorntilde":                  0x1ee0,
+	"Omacron":                     0x014c,
+	"Ooblique":                    0x00d8,
+	"Oslash":                      0x00d8,
+	"Otilde":                      0x00d5,
+	"P":                           0x0050,
+	"Pabovedot":                   0x1e56,
+	"PesetaSign":                  0x20a7,
+	"Q":                           0x0051,
+	"R":                           0x0052,
+	"Racute":                      0x0154,
+	"Rcaron":                      0x0158,
+	"Rcedilla":                    0x0156,
+	"RupeeSign":                   0x20a8,
+	"S":                           0x0053,
+	"SCHWA":                       0x018f,
+	"Sabovedot":                   0x1e60,
+	"Sacute":                      0x015a,
+	"Scaron":                      0x0160,
+	"Scedilla":                    0x015e,
+	"Scircumflex":                 0x015c,
+	"Serbian_DJE":                 0x0402,
+	"Serbian_TSHE":                0x040b,
+	"Serbian_dje":                 0x0452,
+	"Serbian_tshe":                0x045b,
+	"Sinh_a":                      0x0d85,
+	"Sinh_aa":                     0x0d86,
+	"Sinh_aa2":                    0x0dcf,
+	"Sinh_ae":                     0x0d87,
+	"Sinh_ae2":                    0x0dd0,
+	"Sinh_aee":                    0x0d88,
+	"Sinh_aee2":                   0x0dd1,
+	"Sinh_ai":                     0x0d93,
+	"Sinh_ai2":                    0x0ddb,
+	"Sinh_al":                     0x0dca,
+	"Sinh_au":                     0x0d96,
+	"Sinh_au2":                    0x0dde,
+	"Sinh_ba":                     0x0db6,
+	"Sinh_bha":                    0x0db7,
+	"Sinh_ca":                     0x0da0,
+	"Sinh_cha":                    0x0da1,
+	"Sinh_dda":                    0x0da9,
+	"Sinh_ddha":                   0x0daa,
+	"Sinh_dha":                    0x0daf,
+	"Sinh_dhha":                   0x0db0,
+	"Sinh_e":                      0x0d91,
+	"Sinh_e2":                     0x0dd9,
+	"Sinh_ee":                     0x0d92,
+	"Sinh_ee2":                    0x0dda,
+	"Sinh_fa":                     0x0dc6,
+	"Sinh_ga":                     0x0d9c,
+	"Sinh_gha":                    0x0d9d,
+	"Sinh_h2":                     0x0d83,
+	"Sinh_ha":                     0x0dc4,
+	"Sinh_i":                      0x0d89,
+	"Sinh_i2":                     0x0dd2,
+	"Sinh_ii":                     0x0d8a,
+	"Sinh_ii2":                    0x0dd3,
+	"Sinh_ja":                     0x0da2,
+	"Sinh_jha":                    0x0da3,
+	"Sinh_jnya":                   0x0da5,
+	"Sinh_ka":                     0x0d9a,
+	"Sinh_kha":                    0x0d9b,
+	"Sinh_kunddaliya":             0x0df4,
+	"Sinh_la":                     0x0dbd,
+	"Sinh_lla":                    0x0dc5,
+	"Sinh_lu":                     0x0d8f,
+	"Sinh_lu2":                    0x0ddf,
+	"Sinh_luu":                    0x0d90,
+	"Sinh_luu2":                   0x0df3,
+	"Sinh_ma":                     0x0db8,
+	"Sinh_mba":                    0x0db9,
+	"Sinh_na":                     0x0db1,
+	"Sinh_ndda":                   0x0dac,
+	"Sinh_ndha":                   0x0db3,
+	"Sinh_ng":                     0x0d82,
+	"Sinh_ng2":                    0x0d9e,
+	"Sinh_nga":                    0x0d9f,
+	"Sinh_nja":                    0x0da6,
+	"Sinh_nna":                    0x0dab,
+	"Sinh_nya":                    0x0da4,
+	"Sinh_o":                      0x0d94,
+	"Sinh_o2":                     0x0ddc,
+	"Sinh_oo":                     0x0d95,
+	"Sinh_oo2":                    0x0ddd,
+	"Sinh_pa":                     0x0db4,
+	"Sinh_pha":                    0x0db5,
+	"Sinh_ra":                     0x0dbb,
+	"Sinh_ri":                     0x0d8d,
+	"Sinh_rii":                    0x0d8e,
+	"Sinh_ru2":                    0x0dd8,
+	"Sinh_ruu2":                   0x0df2,
+	"Sinh_sa":                     0x0dc3,
+	"Sinh_sha":                    0x0dc1,
+	"Sinh_ssha":                   0x0dc2,
+	"Sinh_tha":                    0x0dad,
+	"Sinh_thha":                   0x0dae,
+	"Sinh_tta":                    0x0da7,
+	"Sinh_ttha":                   0x0da8,
+	"Sinh_u":                      0x0d8b,
+	"Sinh_u2":                     0x0dd4,
+	"Sinh_uu":                     0x0d8c,
+	"Sinh_uu2":                    0x0dd6,
+	"Sinh_va":                     0x0dc0,
+	"Sinh_ya":                     0x0dba,
+	"T":                           0x0054,
+	"THORN":                       0x00de,
+	"Tabovedot":                   0x1e6a,
+	"Tcaron":                      0x0164,
+	"Tcedilla":                    0x0162,
+	"Thai_baht":                   0x0e3f,
+	"Thai_bobaimai":               0x0e1a,
+	"Thai_chochan":                0x0e08,
+	"Thai_chochang":               0x0e0a,
+	"Thai_choching":               0x0e09,
+	"Thai_chochoe":                0x0e0c,
+	"Thai_dochada":                0x0e0e,
+	"Thai_dodek":                  0x0e14,
+	"Thai_fofa":                   0x0e1d,
+	"Thai_fofan":                  0x0e1f,
+	"Thai_hohip":                  0x0e2b,
+	"Thai_honokhuk":               0x0e2e,
+	"Thai_khokhai":                0x0e02,
+	"Thai_khokhon":                0x0e05,
+	"Thai_khokhuat":               0x0e03,
+	"Thai_khokhwai":               0x0e04,
+	"Thai_khorakhang":             0x0e06,
+	"Thai_kokai":                  0x0e01,
+	"Thai_lakkhangyao":            0x0e45,
+	"Thai_lekchet":                0x0e57,
+	"Thai_lekha":                  0x0e55,
+	"Thai_lekhok":                 0x0e56,
+	"Thai_lekkao":                 0x0e59,
+	"Thai_leknung":                0x0e51,
+	"Thai_lekpaet":                0x0e58,
+	"Thai_leksam":                 0x0e53,
+	"Thai_leksi":                  0x0e54,
+	"Thai_leksong":                0x0e52,
+	"Thai_leksun":                 0x0e50,
+	"Thai_lochula":                0x0e2c,
+	"Thai_loling":                 0x0e25,
+	"Thai_lu":                     0x0e26,
+	"Thai_maichattawa":            0x0e4b,
+	"Thai_maiek":                  0x0e48,
+	"Thai_maihanakat":             0x0e31,
+	"Thai_maitaikhu":              0x0e47,
+	"Thai_maitho":                 0x0e49,
+	"Thai_maitri":                 0x0e4a,
+	"Thai_maiyamok":               0x0e46,
+	"Thai_moma":                   0x0e21,
+	"Thai_ngongu":                 0x0e07,
+	"Thai_nikhahit":               0x0e4d,
+	"Thai_nonen":                  0x0e13,
+	"Thai_nonu":                   0x0e19,
+	"Thai_oang":                   0x0e2d,
+	"Thai_paiyannoi":              0x0e2f,
+	"Thai_phinthu":                0x0e3a,
+	"Thai_phophan":                0x0e1e,
+	"Thai_phophung":               0x0e1c,
+	"Thai_phosamphao":             0x0e20,
+	"Thai_popla":                  0x0e1b,
+	"Thai_rorua":                  0x0e23,
+	"Thai_ru":                     0x0e24,
+	"Thai_saraa":                  0x0e30,
+	"Thai_saraaa":                 0x0e32,
+	"Thai_saraae":                 0x0e41,
+	"Thai_saraaimaimalai":         0x0e44,
+	"Thai_saraaimaimuan":          0x0e43,
+	"Thai_saraam":                 0x0e33,
+	"Thai_sarae":                  0x0e40,
+	"Thai_sarai":                  0x0e34,
+	"Thai_saraii":                 0x0e35,
+	"Thai_sarao":                  0x0e42,
+	"Thai_sarau":                  0x0e38,
+	"Thai_saraue":                 0x0e36,
+	"Thai_sarauee":                0x0e37,
+	"Thai_sarauu":                 0x0e39,
+	"Thai_sorusi":                 0x0e29,
+	"Thai_sosala":                 0x0e28,
+	"Thai_soso":                   0x0e0b,
+	"Thai_sosua":                  0x0e2a,
+	"Thai_thanthakhat":            0x0e4c,
+	"Thai_thonangmontho":          0x0e11,
+	"Thai_thophuthao":             0x0e12,
+	"Thai_thothahan":              0x0e17,
+	"Thai_thothan":                0x0e10,
+	"Thai_thothong":               0x0e18,
+	"Thai_thothung":               0x0e16,
+	"Thai_topatak":                0x0e0f,
+	"Thai_totao":                  0x0e15,
+	"Thai_wowaen":                 0x0e27,
+	"Thai_yoyak":                  0x0e22,
+	"Thai_yoying":                 0x0e0d,
+	"Tslash":                      0x0166,
+	"U":                           0x0055,
+	"Uacute":                      0x00da,
+	"Ubelowdot":                   0x1ee4,
+	"Ubreve":                      0x016c,
+	"Ucircumflex":                 0x00db,
+	"Udiaeresis":                  0x00dc,
+	"Udoubleacute":                0x0170,
+	"Ugrave":                      0x00d9,
+	"Uhook":                       0x1ee6,
+	"Uhorn":                       0x01af,
+	"Uhornacute":                  0x1ee8,
+	"Uhornbelowdot":               0x1ef0,
+	"Uhorngrave":                  0x1eea,
+	"Uhornhook":                   0x1eec,
+	"Uhorntilde":                  0x1eee,
+	"Ukrainian_GHE_WITH_UPTURN":   0x0490,
+	"Ukrainian_I":                 0x0406,
+	"Ukrainian_IE":                0x0404,
+	"Ukrainian_YI":                0x0407,
+	"Ukrainian_ghe_with_upturn":   0x0491,
+	"Ukrainian_i":                 0x0456,
+	"Ukrainian_ie":                0x0454,
+	"Ukrainian_yi":                0x0457,
+	"Umacron":                     0x016a,
+	"Uogonek":                     0x0172,
+	"Uring":                       0x016e,
+	"Utilde":                      0x0168,
+	"V":                           0x0056,
+	"W":                           0x0057,
+	"Wacute":                      0x1e82,
+	"Wcircumflex":                 0x0174,
+	"Wdiaeresis":                  0x1e84,
+	"Wgrave":                      0x1e80,
+	"WonSign":                     0x20a9,
+	"X":                           0x0058,
+	"Xabovedot":                   0x1e8a,
+	"Y":                           0x0059,
+	"Yacute":                      0x00dd,
+	"Ybelowdot":                   0x1ef4,
+	"Ycircumflex":                 0x0176,
+	"Ydiaeresis":                  0x0178,
+	"Ygrave":                      0x1ef2,
+	"Yhook":                       0x1ef6,
+	"Ytilde":                      0x1ef8,
+	"Z":                           0x005a,
+	"Zabovedot":                   0x017b,
+	"Zacute":                      0x0179,
+	"Zcaron":                      0x017d,
+	"Zstroke":                     0x01b5,
+	"a":                           0x0061,
+	"aacute":                      0x00e1,
+	"abelowdot":                   0x1ea1,
+	"abovedot":                    0x02d9,
+	"abreve":                      0x0103,
+	"abreveacute":                 0x1eaf,
+	"abrevebelowdot":              0x1eb7,
+	"abrevegrave":                 0x1eb1,
+	"abrevehook":                  0x1eb3,
+	"abrevetilde":                 0x1eb5,
+	"acircumflex":                 0x00e2,
+	"acircumflexacute":            0x1ea5,
+	"acircumflexbelowdot":         0x1ead,
+	"acircumflexgrave":            0x1ea7,
+	"acircumflexhook":             0x1ea9,
+	"acircumflextilde":            0x1eab,
+	"acute":                       0x00b4,
+	"adiaeresis":                  0x00e4,
+	"ae":                          0x00e6,
+	"agrave":                      0x00e0,
+	"ahook":                       0x1ea3,
+	"amacron":                     0x0101,
+	"ampersand":                   0x0026,
+	"aogonek":                     0x0105,
+	"apostrophe":                  0x0027,
+	"approximate":                 0x223c,
+	"aring":                       0x00e5,
+	"asciicircum":                 0x005e,
+	"asciitilde":                  0x007e,
+	"asterisk":                    0x002a,
+	"at":                          0x0040,
+	"atilde":                      0x00e3,
+	"b":                           0x0062,
+	"babovedot":                   0x1e03,
+	"backslash":                   0x005c,
+	"ballotcross":                 0x2717,
+	"bar":                         0x007c,
+	"because":                     0x2235,
+	"botintegral":                 0x2321,
+	"botleftparens":               0x239d,
+	"botleftsqbracket":            0x23a3,
+	"botrightparens":              0x23a0,
+	"botrightsqbracket":           0x23a6,
+	"bott":                        0x2534,
+	"braceleft":                   0x007b,
+	"braceright":                  0x007d,
+	"bracketleft":                 0x005b,
+	"bracketright":                0x005d,
+	"braille_blank":               0x2800,
+	"braille_dots_1":              0x2801,
+	"braille_dots_12":             0x2803,
+	"braille_dots_123":            0x2807,
+	"braille_dots_1234":           0x280f,
+	"braille_dots_12345":          0x281f,
+	"braille_dots_123456":         0x283f,
+	"braille_dots_1234567":        0x287f,
+	"braille_dots_12345678":       0x28ff,
+	"braille_dots_1234568":        0x28bf,
+	"braille_dots_123457":         0x285f,
+	"braille_dots_1234578":        0x28df,
+	"braille_dots_123458":         0x289f,
+	"braille_dots_12346":          0x282f,
+	"braille_dots_123467":         0x286f,
+	"braille_dots_1234678":        0x28ef,
+	"braille_dots_123468":         0x28af,
+	"braille_dots_12347":          0x284f,
+	"braille_dots_123478":         0x28cf,
+	"braille_dots_12348":          0x288f,
+	"braille_dots_1235":           0x2817,
+	"braille_dots_12356":          0x2837,
+	"braille_dots_123567":         0x2877,
+	"braille_dots_1235678":        0x28f7,
+	"braille_dots_123568":         0x28b7,
+	"braille_dots_12357":          0x2857,
+	"braille_dots_123578":         0x28d7,
+	"braille_dots_12358":          0x2897,
+	"braille_dots_1236":           0x2827,
+	"braille_dots_12367":          0x2867,
+	"braille_dots_123678":         0x28e7,
+	"braille_dots_12368":          0x28a7,
+	"braille_dots_1237":           0x2847,
+	"braille_dots_12378":          0x28c7,
+	"braille_dots_1238":           0x2887,
+	"braille_dots_124":            0x280b,
+	"braille_dots_1245":           0x281b,
+	"braille_dots_12456":          0x283b,
+	"braille_dots_124567":         0x287b,
+	"braille_dots_1245678":        0x28fb,
+	"braille_dots_124568":         0x28bb,
+	"braille_dots_12457":          0x285b,
+	"braille_dots_124578":         0x28db,
+	"braille_dots_12458":          0x289b,
+	"braille_dots_1246":           0x282b,
+	"braille_dots_12467":          0x286b,
+	"braille_dots_124678":         0x28eb,
+	"braille_dots_12468":          0x28ab,
+	"braille_dots_1247":           0x284b,
+	"braille_dots_12478":          0x28cb,
+	"braille_dots_1248":           0x288b,
+	"braille_dots_125":            0x2813,
+	"braille_dots_1256":           0x2833,
+	"braille_dots_12567":          0x2873,
+	"braille_dots_125678":         0x28f3,
+	"braille_dots_12568":          0x28b3,
+	"braille_dots_1257":           0x2853,
+	"braille_dots_12578":          0x28d3,
+	"braille_dots_1258":           0x2893,
+	"braille_dots_126":            0x2823,
+	"braille_dots_1267":           0x2863,
+	"braille_dots_12678":          0x28e3,
+	"braille_dots_1268":           0x28a3,
+	"braille_dots_127":            0x2843,
+	"braille_dots_1278":           0x28c3,
+	"braille_dots_128":            0x2883,
+	"braille_dots_13":             0x2805,
+	"braille_dots_134":            0x280d,
+	"braille_dots_1345":           0x281d,
+	"braille_dots_13456":          0x283d,
+	"braille_dots_134567":         0x287d,
+	"braille_dots_1345678":        0x28fd,
+	"braille_dots_134568":         0x28bd,
+	"braille_dots_13457":          0x285d,
+	"braille_dots_134578":         0x28dd,
+	"braille_dots_13458":          0x289d,
+	"braille_dots_1346":           0x282d,
+	"braille_dots_13467":          0x286d,
+	"braille_dots_134678":         0x28ed,
+	"braille_dots_13468":          0x28ad,
+	"braille_dots_1347":           0x284d,
+	"braille_dots_13478":          0x28cd,
+	"braille_dots_1348":           0x288d,
+	"braille_dots_135":            0x2815,
+	"braille_dots_1356":           0x2835,
+	"braille_dots_13567":          0x2875,
+	"braille_dots_135678":         0x28f5,
+	"braille_dots_13568":          0x28b5,
+	"braille_dots_1357":           0x2855,
+	"braille_dots_13578":          0x28d5,
+	"braille_dots_1358":           0x2895,
+	"braille_dots_136":            0x2825,
+	"braille_dots_1367":           0x2865,
+	"braille_dots_13678":          0x28e5,
+	"braille_dots_1368":           0x28a5,
+	"braille_dots_137":            0x2845,
+	"braille_dots_1378":           0x28c5,
+	"braille_dots_138":            0x2885,
+	"braille_dots_14":             0x2809,
+	"braille_dots_145":            0x2819,
+	"braille_dots_1456":           0x2839,
+	"braille_dots_14567":          0x2879,
+	"braille_dots_145678":         0x28f9,
+	"braille_dots_14568":          0x28b9,
+	"braille_dots_1457":           0x2859,
+	"braille_dots_14578":          0x28d9,
+	"braille_dots_1458":           0x2899,
+	"braille_dots_146":            0x2829,
+	"braille_dots_1467":           0x2869,
+	"braille_dots_14678":          0x28e9,
+	"braille_dots_1468":           0x28a9,
+	"braille_dots_147":            0x2849,
+	"braille_dots_1478":           0x28c9,
+	"braille_dots_148":            0x2889,
+	"braille_dots_15":             0x2811,
+	"braille_dots_156":            0x2831,
+	"braille_dots_1567":           0x2871,
+	"braille_dots_15678":          0x28f1,
+	"braille_dots_1568":           0x28b1,
+	"braille_dots_157":            0x2851,
+	"braille_dots_1578":           0x28d1,
+	"braille_dots_158":            0x2891,
+	"braille_dots_16":             0x2821,
+	"braille_dots_167":            0x2861,
+	"braille_dots_1678":           0x28e1,
+	"braille_dots_168":            0x28a1,
+	"braille_dots_17":             0x2841,
+	"braille_dots_178":            0x28c1,
+	"braille_dots_18":             0x2881,
+	"braille_dots_2":              0x2802,
+	"braille_dots_23":             0x2806,
+	"braille_dots_234":            0x280e,
+	"braille_dots_2345":           0x281e,
+	"braille_dots_23456":          0x283e,
+	"braille_dots_234567":         0x287e,
+	"braille_dots_2345678":        0x28fe,
+	"braille_dots_234568":         0x28be,
+	"braille_dots_23457":          0x285e,
+	"braille_dots_234578":         0x28de,
+	"braille_dots_23458":          0x289e,
+	"braille_dots_2346":           0x282e,
+	"braille_dots_23467":          0x286e,
+	"braille_dots_234678":         0x28ee,
+	"braille_dots_23468":          0x28ae,
+	"braille_dots_2347":           0x284e,
+	"braille_dots_23478":          0x28ce,
+	"braille_dots_2348":           0x288e,
+	"braille_dots_235":            0x2816,
+	"braille_dots_2356":           0x2836,
+	"braille_dots_23567":          0x2876,
+	"braille_dots_235678":         0x28f6,
+	"braille_dots_23568":          0x28b6,
+	"braille_dots_2357":           0x2856,
+	"braille_dots_23578":          0x28d6,
+	"braille_dots_2358":           0x2896,
+	"braille_dots_236":            0x2826,
+	"braille_dots_2367":           0x2866,
+	"braille_dots_23678":          0x28e6,
+	"braille_dots_2368":           0x28a6,
+	"braille_dots_237":            0x2846,
+	"braille_dots_2378":           0x28c6,
+	"braille_dots_238":            0x2886,
+	"braille_dots_24":             0x280a,
+	"braille_dots_245":            0x281a,
+	"braille_dots_2456":           0x283a,
+	"braille_dots_24567":          0x287a,
+	"braille_dots_245678":         0x28fa,
+	"braille_dots_24568":          0x28ba,
+	"braille_dots_2457":           0x285a,
+	"braille_dots_24578":          0x28da,
+	"braille_dots_2458":           0x289a,
+	"braille_dots_246":            0x282a,
+	"braille_dots_2467":           0x286a,
+	"braille_dots_24678":          0x28ea,
+	"braille_dots_2468":           0x28aa,
+	"braille_dots_247":            0x284a,
+	"braille_dots_2478":           0x28ca,
+	"braille_dots_248":            0x288a,
+	"braille_dots_25":             0x2812,
+	"braille_dots_256":            0x2832,
+	"braille_dots_2567":           0x2872,
+	"braille_dots_25678":          0x28f2,
+	"braille_dots_2568":           0x28b2,
+	"braille_dots_257":            0x2852,
+	"braille_dots_2578":           0x28d2,
+	"braille_dots_258":            0x2892,
+	"braille_dots_26":             0x2822,
+	"braille_dots_267":            0x2862,
+	"braille_dots_2678":           0x28e2,
+	"braille_dots_268":            0x28a2,
+	"braille_dots_27":             0x2842,
+	"braille_dots_278":            0x28c2,
+	"braille_dots_28":             0x2882,
+	"braille_dots_3":              0x2804,
+	"braille_dots_34":             0x280c,
+	"braille_dots_345":            0x281c,
+	"braille_dots_3456":           0x283c,
+	"braille_dots_34567":          0x287c,
+	"braille_dots_345678":         0x28fc,
+	"braille_dots_34568":          0x28bc,
+	"braille_dots_3457":           0x285c,
+	"braille_dots_34578":          0x28dc,
+	"braille_dots_3458":           0x289c,
+	"braille_dots_346":            0x282c,
+	"braille_dots_3467":           0x286c,
+	"braille_dots_34678":          0x28ec,
+	"braille_dots_3468":           0x28ac,
+	"braille_dots_347":            0x284c,
+	"braille_dots_3478":           0x28cc,
+	"braille_dots_348":            0x288c,
+	"braille_dots_35":             0x2814,
+	"braille_dots_356":            0x2834,
+	"braille_dots_3567":           0x2874,
+	"braille_dots_35678":          0x28f4,
+	"braille_dots_3568":           0x28b4,
+	"braille_dots_357":            0x2854,
+	"braille_dots_3578":           0x28d4,
+	"braille_dots_358":            0x2894,
+	"braille_dots_36":             0x2824,
+	"braille_dots_367":            0x2864,
+	"braille_dots_3678":           0x28e4,
+	"braille_dots_368":            0x28a4,
+	"braille_dots_37":             0x2844,
+	"braille_dots_378":            0x28c4,
+	"braille_dots_38":             0x2884,
+	"braille_dots_4":              0x2808,
+	"braille_dots_45":             0x2818,
+	"braille_dots_456":            0x2838,
+	"braille_dots_4567":           0x2878,
+	"braille_dots_45678":          0x28f8,
+	"braille_dots_4568":           0x28b8,
+	"braille_dots_457":            0x2858,
+	"braille_dots_4578":           0x28d8,
+	"braille_dots_458":            0x2898,
+	"braille_dots_46":             0x2828,
+	"braille_dots_467":            0x2868,
+	"braille_dots_4678":           0x28e8,
+	"braille_dots_468":            0x28a8,
+	"braille_dots_47":             0x2848,
+	"braille_dots_478":            0x28c8,
+	"braille_dots_48":             0x2888,
+	"braille_dots_5":              0x2810,
+	"braille_dots_56":             0x2830,
+	"braille_dots_567":            0x2870,
+	"braille_dots_5678":           0x28f0,
+	"braille_dots_568":            0x28b0,
+	"braille_dots_57":             0x2850,
+	"braille_dots_578":            0x28d0,
+	"braille_dots_58":             0x2890,
+	"braille_dots_6":              0x2820,
+	"braille_dots_67":             0x2860,
+	"braille_dots_678":            0x28e0,
+	"braille_dots_68":             0x28a0,
+	"braille_dots_7":              0x2840,
+	"braille_dots_78":             0x28c0,
+	"braille_dots_8":              0x2880,
+	"breve":                       0x02d8,
+	"brokenbar":                   0x00a6,
+	"c":                           0x0063,
+	"cabovedot":                   0x010b,
+	"cacute":                      0x0107,
+	"careof":                      0x2105,
+	"caret":                       0x2038,
+	"caron":                       0x02c7,
+	"ccaron":                      0x010d,
+	"ccedilla":                    0x00e7,
+	"ccircumflex":                 0x0109,
+	"cedilla":                     0x00b8,
+	"cent":                        0x00a2,
+	"checkerboard":                0x2592,
+	"checkmark":                   0x2713,
+	"circle":                      0x25cb,
+	"club":                        0x2663,
+	"colon":                       0x003a,
+	"combining_acute":             0x0301,
+	"combining_belowdot":          0x0323,
+	"combining_grave":             0x0300,
+	"combining_hook":              0x0309,
+	"combining_tilde":             0x0303,
+	"comma":                       0x002c,
+	"containsas":                  0x220b,
+	"copyright":                   0x00a9,
+	"cr":                          0x240d,
+	"crossinglines":               0x253c,
+	"cuberoot":                    0x221b,
+	"currency":                    0x00a4,
+	"d":                           0x0064,
+	"dabovedot":                   0x1e0b,
+	"dagger":                      0x2020,
+	"dcaron":                      0x010f,
+	"degree":                      0x00b0,
+	"diaeresis":                   0x00a8,
+	"diamond":                     0x2666,
+	"digitspace":                  0x2007,
+	"dintegral":                   0x222c,
+	"division":                    0x00f7,
+	"dollar":                      0x0024,
+	"doubbaselinedot":             0x2025,
+	"doubleacute":                 0x02dd,
+	"doubledagger":                0x2021,
+	"doublelowquotemark":          0x201e,
+	"downarrow":                   0x2193,
+	"downstile":                   0x230a,
+	"downtack":                    0x22a4,
+	"dstroke":                     0x0111,
+	"e":                           0x0065,
+	"eabovedot":                   0x0117,
+	"eacute":                      0x00e9,
+	"ebelowdot":                   0x1eb9,
+	"ecaron":                      0x011b,
+	"ecircumflex":                 0x00ea,
+	"ecircumflexacute":            0x1ebf,
+	"ecircumflexbelowdot":         0x1ec7,
+	"ecircumflexgrave":            0x1ec1,
+	"ecircumflexhook":             0x1ec3,
+	"ecircumflextilde":            0x1ec5,
+	"ediaeresis":                  0x00eb,
+	"egrave":                      0x00e8,
+	"ehook":                       0x1ebb,
+	"eightsubscript":              0x2088,
+	"eightsuperior":               0x2078,
+	"elementof":                   0x2208,
+	"ellipsis":                    0x2026,
+	"em3space":                    0x2004,
+	"em4space":                    0x2005,
+	"emacron":                     0x0113,
+	"emdash":                      0x2014,
+	"emptyset":                    0x2205,
+	"emspace":                     0x2003,
+	"endash":                      0x2013,
+	"eng":                         0x014b,
+	"enspace":                     0x2002,
+	"eogonek":                     0x0119,
+	"equal":                       0x003d,
+	"eth":                         0x00f0,
+	"etilde":                      0x1ebd,
+	"exclam":                      0x0021,
+	"exclamdown":                  0x00a1,
+	"ezh":                         0x0292,
+	"f":                           0x0066,
+	"fabovedot":                   0x1e1f,
+	"femalesymbol":                0x2640,
+	"ff":                          0x240c,
+	"figdash":                     0x2012,
+	"fiveeighths":                 0x215d,
+	"fivesixths":                  0x215a,
+	"fivesubscript":               0x2085,
+	"fivesuperior":                0x2075,
+	"fourfifths":                  0x2158,
+	"foursubscript":               0x2084,
+	"foursuperior":                0x2074,
+	"fourthroot":                  0x221c,
+	"function":                    0x0192,
+	"g":                           0x0067,
+	"gabovedot":                   0x0121,
+	"gbreve":                      0x011f,
+	"gcaron":                      0x01e7,
+	"gcedilla":                    0x0123,
+	"gcircumflex":                 0x011d,
+	"grave":                       0x0060,
+	"greater":                     0x003e,
+	"greaterthanequal":            0x2265,
+	"guillemotleft":               0x00ab,
+	"guillemotright":              0x00bb,
+	"h":                           0x0068,
+	"hairspace":                   0x200a,
+	"hcircumflex":                 0x0125,
+	"heart":                       0x2665,
+	"hebrew_aleph":                0x05d0,
+	"hebrew_ayin":                 0x05e2,
+	"hebrew_bet":                  0x05d1,
+	"hebrew_chet":                 0x05d7,
+	"hebrew_dalet":                0x05d3,
+	"hebrew_doublelowline":        0x2017,
+	"hebrew_finalkaph":            0x05da,
+	"hebrew_finalmem":             0x05dd,
+	"hebrew_finalnun":             0x05df,
+	"hebrew_finalpe":              0x05e3,
+	"hebrew_finalzade":            0x05e5,
+	"hebrew_gimel":                0x05d2,
+	"hebrew_he":                   0x05d4,
+	"hebrew_kaph":                 0x05db,
+	"hebrew_lamed":                0x05dc,
+	"hebrew_mem":                  0x05de,
+	"hebrew_nun":                  0x05e0,
+	"hebrew_pe":                   0x05e4,
+	"hebrew_qoph":                 0x05e7,
+	"hebrew_resh":                 0x05e8,
+	"hebrew_samech":               0x05e1,
+	"hebrew_shin":                 0x05e9,
+	"hebrew_taw":                  0x05ea,
+	"hebrew_tet":                  0x05d8,
+	"hebrew_waw":                  0x05d5,
+	"hebrew_yod":                  0x05d9,
+	"hebrew_zade":                 0x05e6,
+	"hebrew_zain":                 0x05d6,
+	"horizlinescan1":              0x23ba,
+	"horizlinescan3":              0x23bb,
+	"horizlinescan5":              0x2500,
+	"horizlinescan7":              0x23bc,
+	"horizlinescan9":              0x23bd,
+	"hstroke":                     0x0127,
+	"ht":                          0x2409,
+	"hyphen":                      0x00ad,
+	"i":                           0x0069,
+	"iacute":                      0x00ed,
+	"ibelowdot":                   0x1ecb,
+	"ibreve":                      0x012d,
+	"icircumflex":                 0x00ee,
+	"identical":                   0x2261,
+	"idiaeresis":                  0x00ef,
+	"idotless":                    0x0131,
+	"ifonlyif":                    0x21d4,
+	"igrave":                      0x00ec,
+	"ihook":                       0x1ec9,
+	"imacron":                     0x012b,
+	"implies":                     0x21d2,
+	"includedin":                  0x2282,
+	"includes":                    0x2283,
+	"infinity":                    0x221e,
+	"integral":                    0x222b,
+	"intersection":                0x2229,
+	"iogonek":                     0x012f,
+	"itilde":                      0x0129,
+	"j":                           0x006a,
+	"jcircumflex":                 0x0135,
+	"jot":                         0x2218,
+	"k":                           0x006b,
+	"kana_A":                      0x30a2,
+	"kana_CHI":                    0x30c1,
+	"kana_E":                      0x30a8,
+	"kana_FU":                     0x30d5,
+	"kana_HA":                     0x30cf,
+	"kana_HE":                     0x30d8,
+	"kana_HI":                     0x30d2,
+	"kana_HO":                     0x30db,
+	"kana_I":                      0x30a4,
+	"kana_KA":                     0x30ab,
+	"kana_KE":                     0x30b1,
+	"kana_KI":                     0x30ad,
+	"kana_KO":                     0x30b3,
+	"kana_KU":                     0x30af,
+	"kana_MA":                     0x30de,
+	"kana_ME":                     0x30e1,
+	"kana_MI":                     0x30df,
+	"kana_MO":                     0x30e2,
+	"kana_MU":                     0x30e0,
+	"kana_N":                      0x30f3,
+	"kana_NA":                     0x30ca,
+	"kana_NE":                     0x30cd,
+	"kana_NI":                     0x30cb,
+	"kana_NO":                     0x30ce,
+	"kana_NU":                     0x30cc,
+	"kana_O":                      0x30aa,
+	"kana_RA":                     0x30e9,
+	"kana_RE":                     0x30ec,
+	"kana_RI":                     0x30ea,
+	"kana_RO":                     0x30ed,
+	"kana_RU":                     0x30eb,
+	"kana_SA":                     0x30b5,
+	"kana_SE":                     0x30bb,
+	"kana_SHI":                    0x30b7,
+	"kana_SO":                     0x30bd,
+	"kana_SU":                     0x30b9,
+	"kana_TA":                     0x30bf,
+	"kana_TE":                     0x30c6,
+	"kana_TO":                     0x30c8,
+	"kana_TSU":                    0x30c4,
+	"kana_U":                      0x30a6,
+	"kana_WA":                     0x30ef,
+	"kana_WO":                     0x30f2,
+	"kana_YA":                     0x30e4,
+	"kana_YO":                     0x30e8,
+	"kana_YU":                     0x30e6,
+	"kana_a":                      0x30a1,
+	"kana_closingbracket":         0x300d,
+	"kana_comma":                  0x3001,
+	"kana_conjunctive":            0x30fb,
+	"kana_e":                      0x30a7,
+	"kana_fullstop":               0x3002,
+	"kana_i":                      0x30a3,
+	"kana_o":                      0x30a9,
+	"kana_openingbracket":         0x300c,
+	"kana_tsu":                    0x30c3,
+	"kana_u":                      0x30a5,
+	"kana_ya":                     0x30e3,
+	"kana_yo":                     0x30e7,
+	"kana_yu":                     0x30e5,
+	"kcedilla":                    0x0137,
+	"kra":                         0x0138,
+	"l":                           0x006c,
+	"lacute":                      0x013a,
+	"latincross":                  0x271d,
+	"lbelowdot":                   0x1e37,
+	"lcaron":                      0x013e,
+	"lcedilla":                    0x013c,
+	"leftarrow":                   0x2190,
+	"leftdoublequotemark":         0x201c,
+	"leftmiddlecurlybrace":        0x23a8,
+	"leftradical":                 0x23b7,
+	"leftsinglequotemark":         0x2018,
+	"leftt":                       0x251c,
+	"lefttack":                    0x22a3,
+	"less":                        0x003c,
+	"lessthanequal":               0x2264,
+	"lf":                          0x240a,
+	"logicaland":                  0x2227,
+	"logicalor":                   0x2228,
+	"lowleftcorner":               0x2514,
+	"lowrightcorner":              0x2518,
+	"lstroke":                     0x0142,
+	"m":                           0x006d,
+	"mabovedot":                   0x1e41,
+	"macron":                      0x00af,
+	"malesymbol":                  0x2642,
+	"maltesecross":                0x2720,
+	"masculine":                   0x00ba,
+	"minus":                       0x002d,
+	"minutes":                     0x2032,
+	"mu":                          0x00b5,
+	"multiply":                    0x00d7,
+	"musicalflat":                 0x266d,
+	"musicalsharp":                0x266f,
+	"n":                           0x006e,
+	"nabla":                       0x2207,
+	"nacute":                      0x0144,
+	"ncaron":                      0x0148,
+	"ncedilla":                    0x0146,
+	"ninesubscript":               0x2089,
+	"ninesuperior":                0x2079,
+	"nl":                          0x2424,
+	"nobreakspace":                0x00a0,
+	"notelementof":                0x2209,
+	"notequal":                    0x2260,
+	"notidentical":                0x2262,
+	"notsign":                     0x00ac,
+	"ntilde":                      0x00f1,
+	"numbersign":                  0x0023,
+	"numerosign":                  0x2116,
+	"o":                           0x006f,
+	"oacute":                      0x00f3,
+	"obarred":                     0x0275,
+	"obelowdot":                   0x1ecd,
+	"ocaron":                      0x01d2,
+	"ocircumflex":                 0x00f4,
+	"ocircumflexacute":            0x1ed1,
+	"ocircumflexbelowdot":         0x1ed9,
+	"ocircumflexgrave":            0x1ed3,
+	"ocircumflexhook":             0x1ed5,
+	"ocircumflextilde":            0x1ed7,
+	"odiaeresis":                  0x00f6,
+	"odoubleacute":                0x0151,
+	"oe":                          0x0153,
+	"ogonek":                      0x02db,
+	"ograve":                      0x00f2,
+	"ohook":                       0x1ecf,
+	"ohorn":                       0x01a1,
+	"ohornacute":                  0x1edb,
+	"ohornbelowdot":               0x1ee3,
+	"ohorngrave":                  0x1edd,
+	"ohornhook":                   0x1edf,
+	"ohorntilde":                  0x1ee1,
+	"omacron":                     0x014d,
+	"oneeighth":                   0x215b,
+	"onefifth":                    0x2155,
+	"onehalf":                     0x00bd,
+	"onequarter":                  0x00bc,
+	"onesixth":                    0x2159,
+	"onesubscript":                0x2081,
+	"onesuperior":                 0x00b9,
+	"onethird":                    0x2153,
+	"ooblique":                    0x00f8,
+	"ordfeminine":                 0x00aa,
+	"oslash":                      0x00f8,
+	"otilde":                      0x00f5,
+	"overline":                    0x203e,
+	"p":                           0x0070,
+	"pabovedot":                   0x1e57,
+	"paragraph":                   0x00b6,
+	"parenleft":                   0x0028,
+	"parenright":                  0x0029,
+	"partdifferential":            0x2202,
+	"partialderivative":           0x2202,
+	"percent":                     0x0025,
+	"period":                      0x002e,
+	"periodcentered":              0x00b7,
+	"permille":                    0x2030,
+	"phonographcopyright":         0x2117,
+	"plus":                        0x002b,
+	"plusminus":                   0x00b1,
+	"prescription":                0x211e,
+	"prolongedsound":              0x30fc,
+	"punctspace":                  0x2008,
+	"q":                           0x0071,
+	"quad":                        0x2395,
+	"question":                    0x003f,
+	"questiondown":                0x00bf,
+	"quotedbl":                    0x0022,
+	"r":                           0x0072,
+	"racute":                      0x0155,
+	"radical":                     0x221a,
+	"rcaron":                      0x0159,
+	"rcedilla":                    0x0157,
+	"registered":                  0x00ae,
+	"rightarrow":                  0x2192,
+	"rightdoublequotemark":        0x201d,
+	"rightmiddlecurlybrace":       0x23ac,
+	"rightsinglequotemark":        0x2019,
+	"rightt":                      0x2524,
+	"righttack":                   0x22a2,
+	"s":                           0x0073,
+	"sabovedot":                   0x1e61,
+	"sacute":                      0x015b,
+	"scaron":                      0x0161,
+	"scedilla":                    0x015f,
+	"schwa":                       0x0259,
+	"scircumflex":                 0x015d,
+	"seconds":                     0x2033,
+	"section":                     0x00a7,
+	"semicolon":                   0x003b,
+	"semivoicedsound":             0x309c,
+	"seveneighths":                0x215e,
+	"sevensubscript":              0x2087,
+	"sevensuperior":               0x2077,
+	"similarequal":                0x2243,
+	"singlelowquotemark":          0x201a,
+	"sixsubscript":                0x2086,
+	"sixsuperior":                 0x2076,
+	"slash":                       0x002f,
+	"soliddiamond":                0x25c6,
+	"space":                       0x0020,
+	"squareroot":                  0x221a,
+	"ssharp":                      0x00df,
+	"sterling":                    0x00a3,
+	"stricteq":                    0x2263,
+	"t":                           0x0074,
+	"tabovedot":                   0x1e6b,
+	"tcaron":                      0x0165,
+	"tcedilla":                    0x0163,
+	"telephone":                   0x260e,
+	"telephonerecorder":           0x2315,
+	"therefore":                   0x2234,
+	"thinspace":                   0x2009,
+	"thorn":                       0x00fe,
+	"threeeighths":                0x215c,
+	"threefifths":                 0x2157,
+	"threequarters":               0x00be,
+	"threesubscript":              0x2083,
+	"threesuperior":               0x00b3,
+	"tintegral":                   0x222d,
+	"topintegral":                 0x2320,
+	"topleftparens":               0x239b,
+	"topleftsqbracket":            0x23a1,
+	"toprightparens":              0x239e,
+	"toprightsqbracket":           0x23a4,
+	"topt":                        0x252c,
+	"trademark":                   0x2122,
+	"tslash":                      0x0167,
+	"twofifths":                   0x2156,
+	"twosubscript":                0x2082,
+	"twosuperior":                 0x00b2,
+	"twothirds":                   0x2154,
+	"u":                           0x0075,
+	"uacute":                      0x00fa,
+	"ubelowdot":                   0x1ee5,
+	"ubreve":                      0x016d,
+	"ucircumflex":                 0x00fb,
+	"udiaeresis":                  0x00fc,
+	"udoubleacute":                0x0171,
+	"ugrave":                      0x00f9,
+	"uhook":                       0x1ee7,
+	"uhorn":                       0x01b0,
+	"uhornacute":                  0x1ee9,
+	"uhornbelowdot":               0x1ef1,
+	"uhorngrave":                  0x1eeb,
+	"uhornhook":                   0x1eed,
+	"uhorntilde":                  0x1eef,
+	"umacron":                     0x016b,
+	"underscore":                  0x005f,
+	"union":                       0x222a,
+	"uogonek":                     0x0173,
+	"uparrow":                     0x2191,
+	"upleftcorner":                0x250c,
+	"uprightcorner":               0x2510,
+	"upstile":                     0x2308,
+	"uptack":                      0x22a5,
+	"uring":                       0x016f,
+	"utilde":                      0x0169,
+	"v":                           0x0076,
+	"variation":                   0x221d,
+	"vertbar":                     0x2502,
+	"voicedsound":                 0x309b,
+	"vt":                          0x240b,
+	"w":                           0x0077,
+	"wacute":                      0x1e83,
+	"wcircumflex":                 0x0175,
+	"wdiaeresis":                  0x1e85,
+	"wgrave":                      0x1e81,
+	"x":                           0x0078,
+	"xabovedot":                   0x1e8b,
+	"y":                           0x0079,
+	"yacute":                      0x00fd,
+	"ybelowdot":                   0x1ef5,
+	"ycircumflex":                 0x0177,
+	"ydiaeresis":                  0x00ff,
+	"yen":                         0x00a5,
+	"ygrave":                      0x1ef3,
+	"yhook":                       0x1ef7,
+	"ytilde":                      0x1ef9,
+	"z":                           0x007a,
+	"zabovedot":                   0x017c,
+	"zacute":                      0x017a,
+	"zcaron":                      0x017e,
+	"zerosubscript":               0x2080,
+	"zerosuperior":                0x2070,
+	"zstroke":                     0x01b6,
+}
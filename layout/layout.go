@@ -0,0 +1,228 @@
+// Package layout translates evdev key events into the text they type, without
+// X or Wayland: a Translator tracks modifier and lock state, picks each key's
+// Shift/AltGr level from a Layout, and composes dead keys, so a keyboard or a
+// barcode scanner can be read as text straight off /dev/input.
+//
+// The reverse direction is a Typer, which types arbitrary text by pressing
+// keys on a virtual device.
+//
+// Built-in layouts cover US, UK, German, French and Dvorak keyboards (see
+// ByName); ParseXKB reads any other from an XKB keymap, and New builds custom
+// ones.
+package layout
+
+//go:generate go run ../internal/genkeysyms
+
+import (
+	"maps"
+	"math/bits"
+	"slices"
+	"sync"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// Sym is what a key produces at one shift level: a character, or a dead key
+// that accents the next character typed. The zero Sym produces nothing.
+type Sym struct {
+	// Rune is the character typed. For a dead key it is the accent's combining
+	// mark, e.g. U+0301 COMBINING ACUTE ACCENT for dead_acute.
+	Rune rune
+	// Dead marks a dead key.
+	Dead bool
+}
+
+// Key describes one key's symbols.
+type Key struct {
+	// Levels holds the symbol at each shift level: plain, Shift, AltGr and
+	// Shift+AltGr. A key that defines only its first level ignores Shift, and
+	// one without AltGr levels ignores AltGr, as XKB's key types do.
+	Levels [4]Sym
+	// Alphabetic keys have Caps Lock act as Shift.
+	Alphabetic bool
+	// Keypad keys have Num Lock act as Shift, selecting the digit level.
+	Keypad bool
+}
+
+// Layout maps evdev key codes to their symbols. A Layout is immutable and safe
+// for concurrent use; per-keyboard state lives in a Translator.
+type Layout struct {
+	name  string
+	keys  map[evdev.EvCode]Key
+	altGr evdev.EvCode
+
+	// strokes is the reverse table Strokes uses, built on first use.
+	strokesOnce sync.Once
+	strokes     map[rune][]Stroke
+}
+
+// New returns a layout with the given key table. altGr is the key that selects
+// the AltGr levels (usually evdev.KEY_RIGHTALT), or 0 for a layout without
+// them, on which that key acts as a plain Alt.
+func New(name string, keys map[evdev.EvCode]Key, altGr evdev.EvCode) *Layout {
+	return &Layout{name: name, keys: maps.Clone(keys), altGr: altGr}
+}
+
+// Name returns the layout's name, e.g. "us" or "de".
+func (l *Layout) Name() string { return l.name }
+
+// Key returns the symbols of the key with the given code.
+func (l *Layout) Key(code evdev.EvCode) (Key, bool) {
+	k, ok := l.keys[code]
+	return k, ok
+}
+
+// AltGr returns the key that selects the AltGr levels, or 0 if the layout has
+// none.
+func (l *Layout) AltGr() evdev.EvCode { return l.altGr }
+
+// KeyCodes returns, sorted, every key in the layout plus the modifiers a Typer
+// presses (Shift, Ctrl and the AltGr key) — the EV_KEY capabilities a virtual
+// keyboard needs to type on this layout.
+func (l *Layout) KeyCodes() []evdev.EvCode {
+	codes := slices.Collect(maps.Keys(l.keys))
+	codes = append(codes, evdev.KEY_LEFTSHIFT, evdev.KEY_LEFTCTRL)
+	if l.altGr != 0 {
+		codes = append(codes, l.altGr)
+	}
+	slices.Sort(codes)
+	return slices.Compact(codes)
+}
+
+// Stroke is one key press with the modifiers held around it.
+type Stroke struct {
+	Code evdev.EvCode
+	Mods Mod
+}
+
+// Strokes returns the key presses that type r on l: usually one, or a dead key
+// followed by the base character (or Space) for a character the layout only
+// composes. Among keys typing the same character it prefers the one needing
+// the fewest modifiers, and keypad keys last, as their level depends on Num
+// Lock. Strokes assume Caps Lock is off. It reports false if l cannot type r.
+func (l *Layout) Strokes(r rune) ([]Stroke, bool) {
+	l.strokesOnce.Do(func() { l.strokes = l.strokeTable() })
+	s, ok := l.strokes[r]
+	return slices.Clone(s), ok
+}
+
+// strokeTable inverts the key table: every character a key types directly,
+// then those composed from a dead key and another key.
+func (l *Layout) strokeTable() map[rune][]Stroke {
+	direct := map[rune]Stroke{}
+	dead := map[rune]Stroke{}
+	for _, code := range slices.Sorted(maps.Keys(l.keys)) {
+		k := l.keys[code]
+		for lvl, sym := range k.Levels {
+			mods, ok := l.modsFor(k, lvl)
+			if sym == (Sym{}) || !ok {
+				continue
+			}
+			s, m := Stroke{Code: code, Mods: mods}, direct
+			if sym.Dead {
+				m = dead
+			}
+			if old, ok := m[sym.Rune]; !ok || l.better(s, old) {
+				m[sym.Rune] = s
+			}
+		}
+	}
+
+	table := map[rune][]Stroke{}
+	for r, s := range direct {
+		table[r] = []Stroke{s}
+	}
+	for mark, d := range dead {
+		if space, ok := direct[' ']; ok {
+			if _, ok := table[spacingOf(mark)]; !ok {
+				table[spacingOf(mark)] = []Stroke{d, space}
+			}
+		}
+		for base, composed := range accents[mark].composed {
+			b, ok := direct[base]
+			if _, typed := table[composed]; ok && !typed {
+				table[composed] = []Stroke{d, b}
+			}
+		}
+	}
+	return table
+}
+
+// modsFor returns the modifiers that select level lvl of k, reporting false if
+// none do (an AltGr level on a layout without AltGr).
+func (l *Layout) modsFor(k Key, lvl int) (Mod, bool) {
+	var mods Mod
+	if lvl&1 != 0 {
+		mods |= ModShift
+	}
+	if lvl&2 != 0 {
+		if l.altGr == 0 {
+			return 0, false
+		}
+		mods |= ModAltGr
+	}
+	return mods, k.level(mods, false, false) == lvl
+}
+
+// better reports whether stroke a is preferable to b for the same character.
+func (l *Layout) better(a, b Stroke) bool {
+	if na, nb := bits.OnesCount8(uint8(a.Mods)), bits.OnesCount8(uint8(b.Mods)); na != nb {
+		return na < nb
+	}
+	return !l.keys[a.Code].Keypad && l.keys[b.Code].Keypad
+}
+
+// Mod is a set of modifiers held down.
+type Mod uint8
+
+const (
+	ModShift Mod = 1 << iota
+	ModAltGr
+	ModCtrl
+	ModAlt
+	ModMeta
+)
+
+// modifierOf reports which modifier a key is on layout l, if any.
+func (l *Layout) modifierOf(code evdev.EvCode) (Mod, bool) {
+	if code == l.altGr && code != 0 {
+		return ModAltGr, true
+	}
+	switch code {
+	case evdev.KEY_LEFTSHIFT, evdev.KEY_RIGHTSHIFT:
+		return ModShift, true
+	case evdev.KEY_LEFTCTRL, evdev.KEY_RIGHTCTRL:
+		return ModCtrl, true
+	case evdev.KEY_LEFTALT, evdev.KEY_RIGHTALT:
+		return ModAlt, true
+	case evdev.KEY_LEFTMETA, evdev.KEY_RIGHTMETA:
+		return ModMeta, true
+	}
+	return 0, false
+}
+
+// level resolves which of k's levels the given state selects, falling back
+// to the levels a key actually defines.
+func (k Key) level(mods Mod, caps, num bool) int {
+	shift := mods&ModShift != 0
+	if k.Alphabetic && caps {
+		shift = !shift
+	}
+	if k.Keypad && num {
+		shift = !shift
+	}
+	lvl := 0
+	if shift {
+		lvl = 1
+	}
+	if mods&ModAltGr != 0 {
+		lvl += 2
+	}
+	if lvl >= 2 && k.Levels[2] == (Sym{}) && k.Levels[3] == (Sym{}) {
+		lvl -= 2
+	}
+	if lvl == 1 && k.Levels[1] == (Sym{}) && !k.Keypad {
+		lvl = 0
+	}
+	return lvl
+}
@@ -0,0 +1,74 @@
+package layout
+
+import (
+	"testing"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// TestBuiltinLayouts spot-checks each built-in layout's table, one key per
+// interesting level.
+func TestBuiltinLayouts(t *testing.T) {
+	tests := []struct {
+		layout *Layout
+		code   evdev.EvCode
+		level  int
+		want   Sym
+	}{
+		{US, evdev.KEY_APOSTROPHE, 1, Sym{Rune: '"'}},
+		{US, evdev.KEY_BACKSLASH, 0, Sym{Rune: '\\'}},
+		{UK, evdev.KEY_3, 1, Sym{Rune: '£'}},
+		{UK, evdev.KEY_BACKSLASH, 1, Sym{Rune: '~'}},
+		{UK, evdev.KEY_4, 2, Sym{Rune: '€'}},
+		{DE, evdev.KEY_Y, 0, Sym{Rune: 'z'}},
+		{DE, evdev.KEY_GRAVE, 0, Sym{Rune: '\u0302', Dead: true}},
+		{DE, evdev.KEY_EQUAL, 1, Sym{Rune: '\u0300', Dead: true}},
+		{DE, evdev.KEY_KPDOT, 1, Sym{Rune: ','}},
+		{FR, evdev.KEY_Q, 0, Sym{Rune: 'a'}},
+		{FR, evdev.KEY_1, 0, Sym{Rune: '&'}},
+		{FR, evdev.KEY_1, 1, Sym{Rune: '1'}},
+		{FR, evdev.KEY_M, 0, Sym{Rune: ','}},
+		{FR, evdev.KEY_0, 2, Sym{Rune: '@'}},
+		{FR, evdev.KEY_LEFTBRACE, 1, Sym{Rune: '\u0308', Dead: true}},
+		{Dvorak, evdev.KEY_S, 0, Sym{Rune: 'o'}},
+		{Dvorak, evdev.KEY_Q, 1, Sym{Rune: '"'}},
+	}
+	for _, tt := range tests {
+		k, ok := tt.layout.Key(tt.code)
+		if !ok {
+			t.Errorf("%s: no key %s", tt.layout.Name(), evdev.CodeName(evdev.EV_KEY, tt.code))
+			continue
+		}
+		if got := k.Levels[tt.level]; got != tt.want {
+			t.Errorf("%s %s level %d = %+v, want %+v", tt.layout.Name(), evdev.CodeName(evdev.EV_KEY, tt.code), tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestAlphabetic(t *testing.T) {
+	for _, tt := range []struct {
+		layout *Layout
+		code   evdev.EvCode
+		want   bool
+	}{
+		{US, evdev.KEY_A, true},
+		{US, evdev.KEY_1, false},
+		{DE, evdev.KEY_SEMICOLON, true}, // ö/Ö
+		{FR, evdev.KEY_2, false},        // é/2: Caps Lock leaves it alone
+	} {
+		if k, _ := tt.layout.Key(tt.code); k.Alphabetic != tt.want {
+			t.Errorf("%s %s Alphabetic = %v, want %v", tt.layout.Name(), evdev.CodeName(evdev.EV_KEY, tt.code), k.Alphabetic, tt.want)
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	for name, want := range map[string]*Layout{"us": US, "uk": UK, "gb": UK, "de": DE, "fr": FR, "us(dvorak)": Dvorak} {
+		if got, ok := ByName(name); !ok || got != want {
+			t.Errorf("ByName(%q) = %v, %v; want %s", name, got, ok, want.Name())
+		}
+	}
+	if _, ok := ByName("xx"); ok {
+		t.Error("ByName(xx) succeeded")
+	}
+}
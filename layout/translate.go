@@ -0,0 +1,175 @@
+package layout
+
+import (
+	"unicode/utf8"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// Translator converts a keyboard's EV_KEY events into the characters they
+// type on a Layout. It tracks which modifiers are held, the Caps Lock and Num
+// Lock state, and a pending dead key, so it must see every key event of one
+// keyboard in order. It is not safe for concurrent use.
+//
+// Chords with Ctrl, Alt or Meta are shortcuts rather than text and type
+// nothing. Lock state starts off; if the keyboard's locks may already be on,
+// set them with SetLocks.
+type Translator struct {
+	layout *Layout
+	held   map[evdev.EvCode]Mod
+	caps   bool
+	num    bool
+	dead   rune // pending dead key's combining mark, or 0
+}
+
+// NewTranslator returns a Translator for layout l with no keys held and locks
+// off.
+func NewTranslator(l *Layout) *Translator {
+	return &Translator{layout: l, held: map[evdev.EvCode]Mod{}}
+}
+
+// SetLocks sets the Caps Lock and Num Lock state.
+func (t *Translator) SetLocks(caps, num bool) { t.caps, t.num = caps, num }
+
+// Locks reports the Caps Lock and Num Lock state.
+func (t *Translator) Locks() (caps, num bool) { return t.caps, t.num }
+
+// Mods returns the modifiers currently held.
+func (t *Translator) Mods() Mod {
+	var m Mod
+	for _, mod := range t.held {
+		m |= mod
+	}
+	return m
+}
+
+// Feed processes one event and returns the characters it types, usually none
+// or one. A dead key types nothing until the next key: then it returns the
+// composed character, or the accent followed by that key's character when the
+// pair has no precomposed form. Key repeats (value 2) type again. Events other
+// than EV_KEY are ignored.
+func (t *Translator) Feed(ev evdev.InputEvent) []rune {
+	if ev.Type != evdev.EV_KEY {
+		return nil
+	}
+	if mod, ok := t.layout.modifierOf(ev.Code); ok {
+		if ev.Value == 0 {
+			delete(t.held, ev.Code)
+		} else {
+			t.held[ev.Code] = mod
+		}
+		return nil
+	}
+	if ev.Value == 0 {
+		return nil
+	}
+	switch ev.Code {
+	case evdev.KEY_CAPSLOCK:
+		if ev.Value == 1 {
+			t.caps = !t.caps
+		}
+		return nil
+	case evdev.KEY_NUMLOCK:
+		if ev.Value == 1 {
+			t.num = !t.num
+		}
+		return nil
+	}
+
+	mods := t.Mods()
+	if mods&(ModCtrl|ModAlt|ModMeta) != 0 {
+		return nil
+	}
+	k, ok := t.layout.keys[ev.Code]
+	if !ok {
+		return nil
+	}
+	sym := k.Levels[k.level(mods, t.caps, t.num)]
+	if sym == (Sym{}) {
+		return nil
+	}
+	return t.compose(sym)
+}
+
+// compose combines sym with any pending dead key.
+func (t *Translator) compose(sym Sym) []rune {
+	pending := t.dead
+	t.dead = 0
+	switch {
+	case sym.Dead && pending == 0:
+		t.dead = sym.Rune
+		return nil
+	case sym.Dead && pending == sym.Rune:
+		return []rune{spacingOf(pending)}
+	case sym.Dead:
+		t.dead = sym.Rune
+		return []rune{spacingOf(pending)}
+	case pending == 0:
+		return []rune{sym.Rune}
+	}
+	if r, ok := compose(pending, sym.Rune); ok {
+		return []rune{r}
+	}
+	if sym.Rune == ' ' {
+		return []rune{spacingOf(pending)}
+	}
+	return []rune{spacingOf(pending), sym.Rune}
+}
+
+// Reader reads the text typed on an EventSource — typically a grabbed keyboard
+// or barcode scanner Device — as UTF-8, so it can be wrapped in a bufio.Scanner
+// to read a scanner's codes line by line. It translates with its own
+// Translator.
+type Reader struct {
+	src evdev.EventSource
+	tr  *Translator
+	buf []byte // translated text not yet returned
+}
+
+// NewReader returns a Reader translating src's key events with layout l.
+func NewReader(src evdev.EventSource, l *Layout) *Reader {
+	return &Reader{src: src, tr: NewTranslator(l)}
+}
+
+// Translator returns the Reader's Translator, e.g. to set the initial lock
+// state.
+func (r *Reader) Translator() *Translator { return r.tr }
+
+// Read reads typed text into p, blocking until at least one character has been
+// typed. It returns the source's error (io.EOF when it ends) once all text
+// typed before it has been returned.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := r.fill(); err != nil {
+		return 0, err
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// ReadRune reads the next typed character, implementing io.RuneReader.
+func (r *Reader) ReadRune() (rune, int, error) {
+	if err := r.fill(); err != nil {
+		return 0, 0, err
+	}
+	ch, size := utf8.DecodeRune(r.buf)
+	r.buf = r.buf[size:]
+	return ch, size, nil
+}
+
+// fill reads events until some text is buffered.
+func (r *Reader) fill() error {
+	for len(r.buf) == 0 {
+		ev, err := r.src.ReadOne()
+		if err != nil {
+			return err
+		}
+		for _, ch := range r.tr.Feed(ev) {
+			r.buf = utf8.AppendRune(r.buf, ch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package layout
+
+import (
+	"bufio"
+	"io"
+	"testing"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// key builds an EV_KEY event.
+func key(code evdev.EvCode, value int32) evdev.InputEvent {
+	return evdev.InputEvent{Type: evdev.EV_KEY, Code: code, Value: value}
+}
+
+// tap is a press and release of code.
+func tap(code evdev.EvCode) []evdev.InputEvent {
+	return []evdev.InputEvent{key(code, 1), key(code, 0)}
+}
+
+// shifted is code tapped with a modifier held.
+func shifted(mod, code evdev.EvCode) []evdev.InputEvent {
+	return []evdev.InputEvent{key(mod, 1), key(code, 1), key(code, 0), key(mod, 0)}
+}
+
+// feed runs events through tr and returns the text typed.
+func feed(tr *Translator, seqs ...[]evdev.InputEvent) string {
+	var out []rune
+	for _, seq := range seqs {
+		for _, ev := range seq {
+			out = append(out, tr.Feed(ev)...)
+		}
+	}
+	return string(out)
+}
+
+func TestTranslatorModifiers(t *testing.T) {
+	tr := NewTranslator(US)
+	got := feed(tr,
+		shifted(evdev.KEY_LEFTSHIFT, evdev.KEY_H), tap(evdev.KEY_I),
+		shifted(evdev.KEY_RIGHTSHIFT, evdev.KEY_1),
+		shifted(evdev.KEY_LEFTCTRL, evdev.KEY_C), // a shortcut, not text
+		[]evdev.InputEvent{key(evdev.KEY_A, 1), key(evdev.KEY_A, 2), key(evdev.KEY_A, 0)},
+		tap(evdev.KEY_ENTER),
+	)
+	if want := "Hi!aa\n"; got != want {
+		t.Errorf("typed %q, want %q", got, want)
+	}
+}
+
+func TestTranslatorLocks(t *testing.T) {
+	tr := NewTranslator(US)
+	got := feed(tr,
+		tap(evdev.KEY_CAPSLOCK), tap(evdev.KEY_A), tap(evdev.KEY_1),
+		shifted(evdev.KEY_LEFTSHIFT, evdev.KEY_B),   // Shift undoes Caps Lock on letters
+		tap(evdev.KEY_CAPSLOCK), tap(evdev.KEY_KP7), // Num Lock off: no digit
+		tap(evdev.KEY_NUMLOCK), tap(evdev.KEY_KP7), tap(evdev.KEY_KPPLUS),
+	)
+	if want := "A1b7+"; got != want {
+		t.Errorf("typed %q, want %q", got, want)
+	}
+	if caps, num := tr.Locks(); caps || !num {
+		t.Errorf("Locks() = %v, %v; want false, true", caps, num)
+	}
+}
+
+func TestTranslatorDeadKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		seqs [][]evdev.InputEvent
+		want string
+	}{
+		{"compose", [][]evdev.InputEvent{tap(evdev.KEY_GRAVE), tap(evdev.KEY_E)}, "ê"},
+		{"compose upper", [][]evdev.InputEvent{tap(evdev.KEY_EQUAL), shifted(evdev.KEY_LEFTSHIFT, evdev.KEY_A)}, "Á"},
+		{"space", [][]evdev.InputEvent{tap(evdev.KEY_GRAVE), tap(evdev.KEY_SPACE)}, "^"},
+		{"twice", [][]evdev.InputEvent{tap(evdev.KEY_GRAVE), tap(evdev.KEY_GRAVE)}, "^"},
+		{"no composition", [][]evdev.InputEvent{tap(evdev.KEY_GRAVE), tap(evdev.KEY_X)}, "^x"},
+		{"another dead key", [][]evdev.InputEvent{tap(evdev.KEY_GRAVE), tap(evdev.KEY_EQUAL), tap(evdev.KEY_O)}, "^ó"},
+	}
+	for _, tt := range tests {
+		if got := feed(NewTranslator(DE), tt.seqs...); got != tt.want {
+			t.Errorf("%s: typed %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTranslatorAltGr(t *testing.T) {
+	got := feed(NewTranslator(DE),
+		shifted(evdev.KEY_RIGHTALT, evdev.KEY_Q),
+		shifted(evdev.KEY_RIGHTALT, evdev.KEY_E),
+		shifted(evdev.KEY_RIGHTALT, evdev.KEY_A), // no AltGr level: plain
+	)
+	if want := "@€a"; got != want {
+		t.Errorf("DE typed %q, want %q", got, want)
+	}
+	// US has no AltGr, so right Alt is a plain Alt and types nothing.
+	if got := feed(NewTranslator(US), shifted(evdev.KEY_RIGHTALT, evdev.KEY_Q)); got != "" {
+		t.Errorf("US typed %q with right Alt, want nothing", got)
+	}
+}
+
+// eventSource replays events, then io.EOF.
+type eventSource struct{ evs []evdev.InputEvent }
+
+func (s *eventSource) ReadOne() (evdev.InputEvent, error) {
+	if len(s.evs) == 0 {
+		return evdev.InputEvent{}, io.EOF
+	}
+	ev := s.evs[0]
+	s.evs = s.evs[1:]
+	return ev, nil
+}
+
+// TestReader reads a barcode scanner's lines through a bufio.Scanner, with
+// multi-byte characters and non-key events mixed in.
+func TestReader(t *testing.T) {
+	src := &eventSource{}
+	for _, seq := range [][]evdev.InputEvent{
+		tap(evdev.KEY_4), tap(evdev.KEY_2), tap(evdev.KEY_ENTER),
+		{{Type: evdev.EV_MSC, Code: evdev.MSC_SCAN, Value: 0x70004}},
+		tap(evdev.KEY_SEMICOLON), shifted(evdev.KEY_RIGHTALT, evdev.KEY_E), tap(evdev.KEY_ENTER),
+	} {
+		src.evs = append(src.evs, seq...)
+	}
+
+	sc := bufio.NewScanner(NewReader(src, DE))
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0] != "42" || lines[1] != "ö€" {
+		t.Errorf("lines = %q, want [42 ö€]", lines)
+	}
+}
@@ -0,0 +1,168 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// ErrUntypable is returned by Typer.Type for a character its layout cannot
+// produce, unless a Fallback handles it.
+var ErrUntypable = errors.New("character not on layout")
+
+// Fallback types a character the Typer's layout has no keys for. It may tap
+// keys on t, return nil to skip the character, or return an error to stop.
+type Fallback func(t *Typer, r rune) error
+
+// SkipUntypable is a Fallback that silently drops the character.
+func SkipUntypable(*Typer, rune) error { return nil }
+
+// UnicodeInput is a Fallback that enters the character by its code point with
+// the Ctrl+Shift+U, hex digits, Space sequence understood by GTK applications
+// and IBus. The layout must be able to type U and the hex digits.
+func UnicodeInput(t *Typer, r rune) error {
+	u, ok := t.layout.Strokes('u')
+	if !ok || len(u) != 1 {
+		return fmt.Errorf("layout: type %q on %s: %w", 'u', t.layout.name, ErrUntypable)
+	}
+	if err := t.Tap(Stroke{Code: u[0].Code, Mods: u[0].Mods | ModCtrl | ModShift}); err != nil {
+		return err
+	}
+	for _, digit := range strconv.FormatInt(int64(r), 16) + " " {
+		if err := t.typeRune(digit, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Typer types text by pressing keys on an EventSink, typically a VirtualDevice
+// registered with the layout's KeyCodes. It assumes the receiving system uses
+// the same layout, with Caps Lock off. It is not safe for concurrent use.
+type Typer struct {
+	out      evdev.EventSink
+	layout   *Layout
+	delay    time.Duration
+	fallback Fallback
+	last     time.Time // when the previous key was released
+}
+
+// TyperOption configures a Typer at construction.
+type TyperOption func(*Typer)
+
+// WithDelay sets the pause between successive key presses (default 10ms). Many
+// desktops drop or reorder keys injected faster than they are processed.
+func WithDelay(d time.Duration) TyperOption {
+	return func(t *Typer) { t.delay = d }
+}
+
+// WithFallback sets how characters the layout cannot type are handled. By
+// default Type fails with ErrUntypable.
+func WithFallback(f Fallback) TyperOption {
+	return func(t *Typer) { t.fallback = f }
+}
+
+// NewTyper returns a Typer pressing keys on out for layout l.
+func NewTyper(out evdev.EventSink, l *Layout, opts ...TyperOption) *Typer {
+	t := &Typer{out: out, layout: l, delay: 10 * time.Millisecond}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Type types s one character at a time, handing characters the layout cannot
+// produce to the fallback.
+func (t *Typer) Type(s string) error {
+	for _, r := range s {
+		if err := t.typeRune(r, t.fallback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeRune types r, or passes it to fallback if the layout cannot.
+func (t *Typer) typeRune(r rune, fallback Fallback) error {
+	strokes, ok := t.layout.Strokes(r)
+	if !ok {
+		if fallback == nil {
+			return fmt.Errorf("layout: type %q on %s: %w", r, t.layout.name, ErrUntypable)
+		}
+		return fallback(t, r)
+	}
+	for _, s := range strokes {
+		if err := t.Tap(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tap presses and releases one key with its modifiers held around it, each
+// change sent as its own synced frame. It first waits out the remainder of the
+// delay since the previous key.
+func (t *Typer) Tap(s Stroke) error {
+	if !t.last.IsZero() {
+		time.Sleep(t.delay - time.Since(t.last))
+	}
+	defer func() { t.last = time.Now() }()
+
+	mods := t.modifierKeys(s.Mods)
+	if err := t.frame(mods, 1); err != nil {
+		return err
+	}
+	if err := t.frame([]evdev.EvCode{s.Code}, 1); err != nil {
+		return err
+	}
+	if err := t.frame([]evdev.EvCode{s.Code}, 0); err != nil {
+		return err
+	}
+	return t.frame(mods, 0)
+}
+
+// modifierKeys returns the keys to hold for mods.
+func (t *Typer) modifierKeys(mods Mod) []evdev.EvCode {
+	var keys []evdev.EvCode
+	for _, m := range []struct {
+		mod  Mod
+		code evdev.EvCode
+	}{
+		{ModCtrl, evdev.KEY_LEFTCTRL},
+		{ModAlt, evdev.KEY_LEFTALT},
+		{ModMeta, evdev.KEY_LEFTMETA},
+		{ModShift, evdev.KEY_LEFTSHIFT},
+		{ModAltGr, t.layout.altGr},
+	} {
+		if mods&m.mod != 0 {
+			keys = append(keys, m.code)
+		}
+	}
+	return keys
+}
+
+// frame writes a key event with the given value for each code, then a
+// SYN_REPORT, in one write when the sink is an evdev.BatchSink. It writes
+// nothing for no codes.
+func (t *Typer) frame(codes []evdev.EvCode, value int32) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	evs := make([]evdev.InputEvent, 0, len(codes)+1)
+	for _, c := range codes {
+		evs = append(evs, evdev.InputEvent{Type: evdev.EV_KEY, Code: c, Value: value})
+	}
+	evs = append(evs, evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT})
+	if b, ok := t.out.(evdev.BatchSink); ok {
+		return b.WriteEvents(evs)
+	}
+	for _, ev := range evs {
+		if err := t.out.Write(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
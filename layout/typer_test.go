@@ -0,0 +1,147 @@
+package layout
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// recorder is an EventSink keeping every event written.
+type recorder struct{ evs []evdev.InputEvent }
+
+func (r *recorder) Write(ev evdev.InputEvent) error {
+	r.evs = append(r.evs, ev)
+	return nil
+}
+
+func TestStrokes(t *testing.T) {
+	tests := []struct {
+		layout *Layout
+		r      rune
+		want   []Stroke
+	}{
+		{US, 'a', []Stroke{{evdev.KEY_A, 0}}},
+		{US, 'A', []Stroke{{evdev.KEY_A, ModShift}}},
+		{US, '7', []Stroke{{evdev.KEY_7, 0}}}, // not the keypad
+		{DE, '@', []Stroke{{evdev.KEY_Q, ModAltGr}}},
+		{DE, 'ê', []Stroke{{evdev.KEY_GRAVE, 0}, {evdev.KEY_E, 0}}},
+		{DE, 'È', []Stroke{{evdev.KEY_EQUAL, ModShift}, {evdev.KEY_E, ModShift}}},
+		{DE, '´', []Stroke{{evdev.KEY_EQUAL, 0}, {evdev.KEY_SPACE, 0}}},
+		{FR, '1', []Stroke{{evdev.KEY_1, ModShift}}},
+	}
+	for _, tt := range tests {
+		got, ok := tt.layout.Strokes(tt.r)
+		if !ok || !slices.Equal(got, tt.want) {
+			t.Errorf("%s Strokes(%q) = %v, %v; want %v", tt.layout.Name(), tt.r, got, ok, tt.want)
+		}
+	}
+	if got, ok := US.Strokes('€'); ok {
+		t.Errorf("us Strokes('€') = %v, want none", got)
+	}
+}
+
+// TestTyperRoundTrip types text on each built-in layout and reads it back with
+// a Translator for the same layout.
+func TestTyperRoundTrip(t *testing.T) {
+	tests := []struct {
+		layout *Layout
+		text   string
+	}{
+		{US, "Hello, World! {x: [1, 2]} ~`\n"},
+		{UK, "£5 @ \"home\" #1 ~ €\t"},
+		{DE, "Grüße: ê, à, Ó {[@]} 50€?"},
+		{FR, "Voilà : l'été, 10€ @ 5$ ^ ô ë"},
+		{Dvorak, "The quick brown fox; \"jumps\" <over> _it_."},
+	}
+	for _, tt := range tests {
+		rec := &recorder{}
+		if err := NewTyper(rec, tt.layout, WithDelay(0)).Type(tt.text); err != nil {
+			t.Errorf("%s: Type: %v", tt.layout.Name(), err)
+			continue
+		}
+		tr := NewTranslator(tt.layout)
+		var got []rune
+		for _, ev := range rec.evs {
+			got = append(got, tr.Feed(ev)...)
+		}
+		if string(got) != tt.text {
+			t.Errorf("%s: typed %q, want %q", tt.layout.Name(), string(got), tt.text)
+		}
+	}
+}
+
+func TestTyperFrames(t *testing.T) {
+	rec := &recorder{}
+	if err := NewTyper(rec, DE, WithDelay(0)).Type("@"); err != nil {
+		t.Fatal(err)
+	}
+	key := func(c evdev.EvCode, v int32) evdev.InputEvent {
+		return evdev.InputEvent{Type: evdev.EV_KEY, Code: c, Value: v}
+	}
+	syn := evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT}
+	want := []evdev.InputEvent{
+		key(evdev.KEY_RIGHTALT, 1), syn,
+		key(evdev.KEY_Q, 1), syn,
+		key(evdev.KEY_Q, 0), syn,
+		key(evdev.KEY_RIGHTALT, 0), syn,
+	}
+	if !slices.Equal(rec.evs, want) {
+		t.Errorf("events = %v, want %v", rec.evs, want)
+	}
+}
+
+func TestTyperFallback(t *testing.T) {
+	err := NewTyper(&recorder{}, US, WithDelay(0)).Type("5€")
+	if !errors.Is(err, ErrUntypable) {
+		t.Errorf("default fallback: err = %v, want ErrUntypable", err)
+	}
+
+	rec := &recorder{}
+	if err := NewTyper(rec, US, WithDelay(0), WithFallback(SkipUntypable)).Type("5€"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.evs) != 4 {
+		t.Errorf("SkipUntypable wrote %d events, want 4 for the 5 alone", len(rec.evs))
+	}
+
+	rec = &recorder{}
+	if err := NewTyper(rec, US, WithDelay(0), WithFallback(UnicodeInput)).Type("€"); err != nil {
+		t.Fatal(err)
+	}
+	// Ctrl+Shift+U is typed with Ctrl held, which the Translator treats as a
+	// shortcut: only the code point and the terminating space come out as text.
+	var got []rune
+	tr := NewTranslator(US)
+	for _, ev := range rec.evs {
+		got = append(got, tr.Feed(ev)...)
+	}
+	if string(got) != "20ac " {
+		t.Errorf("UnicodeInput typed %q, want \"20ac \"", string(got))
+	}
+
+	// On Dvorak, U is where QWERTY has F.
+	rec = &recorder{}
+	if err := NewTyper(rec, Dvorak, WithDelay(0), WithFallback(UnicodeInput)).Type("€"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.evs) < 4 || rec.evs[3].Code != evdev.KEY_F {
+		t.Errorf("UnicodeInput on Dvorak pressed %v, want Ctrl+Shift+KEY_F first", rec.evs)
+	}
+}
+
+func TestKeyCodes(t *testing.T) {
+	codes := DE.KeyCodes()
+	if !slices.IsSorted(codes) {
+		t.Error("KeyCodes not sorted")
+	}
+	for _, c := range []evdev.EvCode{evdev.KEY_A, evdev.KEY_102ND, evdev.KEY_LEFTSHIFT, evdev.KEY_LEFTCTRL, evdev.KEY_RIGHTALT} {
+		if _, ok := slices.BinarySearch(codes, c); !ok {
+			t.Errorf("DE KeyCodes lacks %s", evdev.CodeName(evdev.EV_KEY, c))
+		}
+	}
+	if _, ok := slices.BinarySearch(US.KeyCodes(), evdev.KEY_RIGHTALT); ok {
+		t.Error("US KeyCodes includes an AltGr key")
+	}
+}
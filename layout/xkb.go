@@ -0,0 +1,597 @@
+package layout
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// ParseXKB reads a layout from a complete XKB keymap in the text format
+// produced by xkbcomp (`xkbcomp -xkb :0 -`) or xkbcli (`xkbcli
+// compile-keymap`). The xkb_keycodes section maps key names to XKB keycodes,
+// which are evdev codes plus 8; the xkb_symbols section gives each key's
+// symbols, of which the first group and its first four levels are used. The
+// key carrying ISO_Level3_Shift becomes the layout's AltGr key. Other sections
+// are skipped, so key types are recognised by name only: types containing
+// ALPHABETIC make Caps Lock act as Shift and those containing KEYPAD make Num
+// Lock select the digit level.
+//
+// Keysyms without a character, such as function keys and modifiers, type
+// nothing. The layout is named after its first group (name[Group1]) or, if
+// that is absent, the xkb_symbols section. Syntax errors report their line.
+func ParseXKB(r io.Reader) (*Layout, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("layout: read xkb keymap: %w", err)
+	}
+	toks, err := lexXKB(string(src))
+	if err != nil {
+		return nil, err
+	}
+	p := &xkbParser{toks: toks, keycodes: map[string]int{}, aliases: map[string]string{}}
+	if err := p.sections(false); err != nil {
+		return nil, err
+	}
+	if p.keys == nil {
+		return nil, fmt.Errorf("layout: xkb keymap has no xkb_symbols section")
+	}
+	return &Layout{name: p.name, keys: p.keys, altGr: p.altGr}, nil
+}
+
+// xkbToken is one lexical token of a keymap. Its kind is 'a' for an
+// identifier or number, '<' for a key name, '"' for a string, 0 at the end of
+// input, and otherwise the punctuation character itself.
+type xkbToken struct {
+	kind byte
+	text string
+	line int
+}
+
+func (t xkbToken) String() string {
+	switch t.kind {
+	case 0:
+		return "end of keymap"
+	case '<':
+		return "<" + t.text + ">"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lexXKB splits a keymap into tokens, dropping // and # comments.
+func lexXKB(src string) ([]xkbToken, error) {
+	var toks []xkbToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '<' || c == '"':
+			end := byte('>')
+			if c == '"' {
+				end = '"'
+			}
+			j := strings.IndexByte(src[i+1:], end)
+			if j < 0 || strings.Contains(src[i+1:i+1+j], "\n") {
+				return nil, fmt.Errorf("layout: xkb line %d: unterminated %c", line, c)
+			}
+			toks = append(toks, xkbToken{kind: c, text: src[i+1 : i+1+j], line: line})
+			i += j + 2
+		case isXKBWordByte(c):
+			j := i
+			for j < len(src) && isXKBWordByte(src[j]) {
+				j++
+			}
+			toks = append(toks, xkbToken{kind: 'a', text: src[i:j], line: line})
+			i = j
+		default:
+			toks = append(toks, xkbToken{kind: c, text: string(c), line: line})
+			i++
+		}
+	}
+	return append(toks, xkbToken{line: line}), nil
+}
+
+func isXKBWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// xkbParser walks the tokens of a keymap, collecting the key names from
+// xkb_keycodes and building the key table from xkb_symbols.
+type xkbParser struct {
+	toks []xkbToken
+	pos  int
+
+	keycodes map[string]int    // key name -> XKB keycode
+	aliases  map[string]string // alias -> key name
+
+	name   string
+	keys   map[evdev.EvCode]Key
+	altGr  evdev.EvCode
+	level3 []evdev.EvCode // keys carrying ISO_Level3_Shift
+}
+
+func (p *xkbParser) next() xkbToken {
+	t := p.toks[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *xkbParser) peek() xkbToken { return p.toks[p.pos] }
+
+func (p *xkbParser) errorf(t xkbToken, format string, args ...any) error {
+	return fmt.Errorf("layout: xkb line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+// expect consumes a token of the given kind.
+func (p *xkbParser) expect(kind byte) (xkbToken, error) {
+	t := p.next()
+	if t.kind != kind {
+		want := string(kind)
+		switch kind {
+		case 'a':
+			want = "name"
+		case '<':
+			want = "key name"
+		case '"':
+			want = "string"
+		}
+		return t, p.errorf(t, "expected %s, found %s", want, t)
+	}
+	return t, nil
+}
+
+// skip consumes a token of the given kind if it is next.
+func (p *xkbParser) skip(kind byte) bool {
+	if p.peek().kind == kind {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// skipUntil consumes tokens up to one of the stop kinds at the current nesting
+// depth, leaving it unconsumed.
+func (p *xkbParser) skipUntil(stops string) error {
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == 0:
+			return p.errorf(t, "unexpected end of keymap")
+		case depth == 0 && strings.IndexByte(stops, t.kind) >= 0:
+			return nil
+		case t.kind == '{' || t.kind == '[' || t.kind == '(':
+			depth++
+		case t.kind == '}' || t.kind == ']' || t.kind == ')':
+			if depth == 0 {
+				return p.errorf(t, "unexpected %s", t)
+			}
+			depth--
+		}
+		p.pos++
+	}
+}
+
+// skipStatement consumes a statement through its terminating semicolon.
+func (p *xkbParser) skipStatement() error {
+	if err := p.skipUntil(";"); err != nil {
+		return err
+	}
+	p.pos++
+	return nil
+}
+
+// sections parses a sequence of sections, up to the closing brace of an
+// enclosing xkb_keymap block if nested.
+func (p *xkbParser) sections(nested bool) error {
+	for {
+		t := p.next()
+		switch {
+		case t.kind == 0 && !nested:
+			return nil
+		case t.kind == '}' && nested:
+			return nil
+		case t.kind == ';':
+			continue
+		case t.kind != 'a':
+			return p.errorf(t, "expected section, found %s", t)
+		}
+		var name string
+		if p.peek().kind == '"' {
+			name = p.next().text
+		}
+		if _, err := p.expect('{'); err != nil {
+			return err
+		}
+		var err error
+		switch t.text {
+		case "xkb_keymap":
+			err = p.sections(true)
+		case "xkb_keycodes":
+			err = p.keycodesSection()
+		case "xkb_symbols":
+			err = p.symbolsSection(name)
+		default:
+			if err = p.skipUntil("}"); err == nil {
+				p.pos++
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// keycodesSection parses `<NAME> = 38;` and `alias <A> = <B>;` statements.
+func (p *xkbParser) keycodesSection() error {
+	for {
+		t := p.next()
+		switch {
+		case t.kind == 0:
+			return p.errorf(t, "unexpected end of keymap")
+		case t.kind == '}':
+			return nil
+		case t.kind == '<':
+			if _, err := p.expect('='); err != nil {
+				return err
+			}
+			n, err := p.expect('a')
+			if err != nil {
+				return err
+			}
+			code, err := strconv.Atoi(n.text)
+			if err != nil {
+				return p.errorf(n, "invalid keycode %q", n.text)
+			}
+			p.keycodes[t.text] = code
+		case t.kind == 'a' && t.text == "alias":
+			alias, err := p.expect('<')
+			if err != nil {
+				return err
+			}
+			if _, err := p.expect('='); err != nil {
+				return err
+			}
+			target, err := p.expect('<')
+			if err != nil {
+				return err
+			}
+			p.aliases[alias.text] = target.text
+		default:
+			p.pos--
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := p.expect(';'); err != nil {
+			return err
+		}
+	}
+}
+
+// symbolsSection parses key statements and the first group's name.
+func (p *xkbParser) symbolsSection(name string) error {
+	if p.keys == nil {
+		p.keys = map[evdev.EvCode]Key{}
+	}
+	for {
+		t := p.next()
+		switch {
+		case t.kind == 0:
+			return p.errorf(t, "unexpected end of keymap")
+		case t.kind == '}':
+			return p.finishSymbols(name)
+		case t.kind == 'a' && t.text == "key":
+			if err := p.key(); err != nil {
+				return err
+			}
+		case t.kind == 'a' && t.text == "name":
+			group, err := p.groupIndex()
+			if err != nil {
+				return err
+			}
+			if _, err := p.expect('='); err != nil {
+				return err
+			}
+			s, err := p.expect('"')
+			if err != nil {
+				return err
+			}
+			if group == 1 && p.name == "" {
+				p.name = s.text
+			}
+			if _, err := p.expect(';'); err != nil {
+				return err
+			}
+		default:
+			p.pos--
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// finishSymbols picks the AltGr key and the layout name once a symbols
+// section is complete.
+func (p *xkbParser) finishSymbols(section string) error {
+	for _, code := range p.level3 {
+		if p.altGr == 0 || code == evdev.KEY_RIGHTALT || p.altGr != evdev.KEY_RIGHTALT && code < p.altGr {
+			p.altGr = code
+		}
+	}
+	if p.name == "" {
+		p.name = section
+	}
+	if p.name == "" {
+		p.name = "xkb"
+	}
+	return nil
+}
+
+// groupIndex parses an optional "[GroupN]" subscript, returning 0 if absent.
+func (p *xkbParser) groupIndex() (int, error) {
+	if !p.skip('[') {
+		return 0, nil
+	}
+	g, err := p.expect('a')
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(g.text), "group"))
+	if err != nil || n < 1 {
+		return 0, p.errorf(g, "invalid group %q", g.text)
+	}
+	if _, err := p.expect(']'); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// key parses `<NAME> { ... };`, whose body is a comma-separated list of
+// symbol lists (one per group), `symbols[GroupN]= [...]`, `type= "..."` and
+// other settings, which are skipped.
+func (p *xkbParser) key() error {
+	nameTok, err := p.expect('<')
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect('{'); err != nil {
+		return err
+	}
+
+	var syms []string
+	var typ string
+	group := 0
+	for p.peek().kind != '}' {
+		t := p.peek()
+		switch {
+		case t.kind == '[':
+			p.pos++
+			group++
+			list, err := p.symList()
+			if err != nil {
+				return err
+			}
+			if group == 1 {
+				syms = list
+			}
+		case t.kind == 'a' && (t.text == "symbols" || t.text == "type"):
+			p.pos++
+			g, err := p.groupIndex()
+			if err != nil {
+				return err
+			}
+			if _, err := p.expect('='); err != nil {
+				return err
+			}
+			if t.text == "type" {
+				s, err := p.expect('"')
+				if err != nil {
+					return err
+				}
+				if g <= 1 {
+					typ = s.text
+				}
+				break
+			}
+			if _, err := p.expect('['); err != nil {
+				return err
+			}
+			list, err := p.symList()
+			if err != nil {
+				return err
+			}
+			if g <= 1 {
+				syms = list
+			}
+		default:
+			if err := p.skipUntil(",}"); err != nil {
+				return err
+			}
+		}
+		if !p.skip(',') && p.peek().kind != '}' {
+			t := p.peek()
+			return p.errorf(t, "expected , or } in key %s, found %s", nameTok, t)
+		}
+	}
+	p.pos++
+	if _, err := p.expect(';'); err != nil {
+		return err
+	}
+
+	code, err := p.evdevCode(nameTok)
+	if err != nil || code == 0 {
+		return err
+	}
+	if len(syms) > 0 && syms[0] == "ISO_Level3_Shift" {
+		p.level3 = append(p.level3, code)
+	}
+	if k, ok := xkbKey(syms, typ); ok {
+		p.keys[code] = k
+	}
+	return nil
+}
+
+// symList parses the keysyms of a bracketed list up to its closing bracket.
+// A level holding several keysyms ({ a, b }) is treated as empty.
+func (p *xkbParser) symList() ([]string, error) {
+	var list []string
+	for {
+		t := p.next()
+		switch t.kind {
+		case ']':
+			return list, nil
+		case 'a':
+			list = append(list, t.text)
+		case '{':
+			p.pos--
+			if err := p.skipUntil(",]"); err != nil {
+				return nil, err
+			}
+			list = append(list, "NoSymbol")
+		default:
+			return nil, p.errorf(t, "expected keysym, found %s", t)
+		}
+		if !p.skip(',') && p.peek().kind != ']' {
+			t := p.peek()
+			return nil, p.errorf(t, "expected , or ], found %s", t)
+		}
+	}
+}
+
+// evdevCode resolves a key name through the aliases and keycodes to an evdev
+// code. It returns 0 without error for XKB keycodes below 8, which have no
+// evdev equivalent.
+func (p *xkbParser) evdevCode(name xkbToken) (evdev.EvCode, error) {
+	key := name.text
+	for range 8 { // bound alias chains
+		target, ok := p.aliases[key]
+		if !ok {
+			break
+		}
+		key = target
+	}
+	code, ok := p.keycodes[key]
+	if !ok {
+		return 0, p.errorf(name, "undefined key %s", name)
+	}
+	if code < 8 {
+		return 0, nil
+	}
+	return evdev.EvCode(code - 8), nil
+}
+
+// xkbKey builds a Key from its first group's keysyms and type name, reporting
+// false if none of the keysyms types anything.
+func xkbKey(syms []string, typ string) (Key, bool) {
+	var k Key
+	typed := false
+	for i := 0; i < len(syms) && i < len(k.Levels); i++ {
+		k.Levels[i] = keysymSym(syms[i])
+		typed = typed || k.Levels[i] != Sym{}
+	}
+	if !typed {
+		return Key{}, false
+	}
+	if typ != "" {
+		k.Alphabetic = strings.Contains(typ, "ALPHABETIC")
+		k.Keypad = strings.Contains(typ, "KEYPAD")
+		return k, true
+	}
+	// Without an explicit type XKB infers one from the symbols.
+	base, shifted := k.Levels[0].Rune, k.Levels[1].Rune
+	k.Alphabetic = unicode.IsLower(base) && shifted == unicode.ToUpper(base)
+	k.Keypad = len(syms) >= 2 && strings.HasPrefix(syms[0], "KP_") && strings.HasPrefix(syms[1], "KP_")
+	return k, true
+}
+
+// keysymSym returns the symbol a keysym types: its character, a dead key's
+// combining mark, or the zero Sym for keysyms without a character. Besides
+// names it accepts the Unicode forms U20AC and 0x10020ac, and numeric Latin-1
+// keysyms.
+func keysymSym(name string) Sym {
+	if r, ok := keysymRunes[name]; ok {
+		return Sym{Rune: r}
+	}
+	if r, ok := functionKeysyms[name]; ok {
+		return Sym{Rune: r}
+	}
+	if mark, ok := deadKeysyms[name]; ok {
+		return Sym{Rune: mark, Dead: true}
+	}
+	if hex, ok := strings.CutPrefix(name, "U"); ok && len(hex) >= 4 && len(hex) <= 6 {
+		if r, err := strconv.ParseUint(hex, 16, 32); err == nil && r <= unicode.MaxRune {
+			return Sym{Rune: rune(r)}
+		}
+	}
+	if hex, ok := strings.CutPrefix(name, "0x"); ok {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		switch {
+		case err != nil:
+		case v >= 0x1000000 && v-0x1000000 <= unicode.MaxRune:
+			return Sym{Rune: rune(v - 0x1000000)}
+		case v >= 0x20 && v <= 0x7e, v >= 0xa0 && v <= 0xff:
+			return Sym{Rune: rune(v)}
+		}
+	}
+	return Sym{}
+}
+
+// functionKeysyms are the keysyms without a Unicode equivalent in the keysym
+// table that still type a character, matching the built-in layouts.
+var functionKeysyms = map[string]rune{
+	"BackSpace": '\b', "Tab": '\t', "Return": '\n', "Escape": '\x1b', "Delete": '\x7f',
+	"KP_Enter": '\n', "KP_Space": ' ', "KP_Tab": '\t', "KP_Equal": '=',
+	"KP_Multiply": '*', "KP_Add": '+', "KP_Separator": ',', "KP_Subtract": '-',
+	"KP_Decimal": '.', "KP_Divide": '/',
+	"KP_0": '0', "KP_1": '1', "KP_2": '2', "KP_3": '3', "KP_4": '4',
+	"KP_5": '5', "KP_6": '6', "KP_7": '7', "KP_8": '8', "KP_9": '9',
+}
+
+// deadKeysyms maps dead keysyms to the combining mark Sym.Rune holds for them.
+var deadKeysyms = map[string]rune{
+	"dead_grave":              '\u0300',
+	"dead_acute":              '\u0301',
+	"dead_circumflex":         '\u0302',
+	"dead_tilde":              '\u0303',
+	"dead_perispomeni":        '\u0303',
+	"dead_macron":             '\u0304',
+	"dead_breve":              '\u0306',
+	"dead_abovedot":           '\u0307',
+	"dead_diaeresis":          '\u0308',
+	"dead_hook":               '\u0309',
+	"dead_abovering":          '\u030a',
+	"dead_doubleacute":        '\u030b',
+	"dead_caron":              '\u030c',
+	"dead_doublegrave":        '\u030f',
+	"dead_abovecomma":         '\u0313',
+	"dead_psili":              '\u0313',
+	"dead_abovereversedcomma": '\u0314',
+	"dead_dasia":              '\u0314',
+	"dead_horn":               '\u031b',
+	"dead_belowdot":           '\u0323',
+	"dead_belowring":          '\u0325',
+	"dead_cedilla":            '\u0327',
+	"dead_ogonek":             '\u0328',
+	"dead_belowcircumflex":    '\u032d',
+	"dead_belowtilde":         '\u0330',
+	"dead_belowmacron":        '\u0331',
+	"dead_stroke":             '\u0335',
+	"dead_iota":               '\u0345',
+}
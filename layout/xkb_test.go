@@ -0,0 +1,201 @@
+package layout
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	evdev "github.com/mikegio27/go-evdev"
+)
+
+// testKeymap is an excerpt of `xkbcomp -xkb` output for a German layout with a
+// Russian second group, trimmed to the keys the tests use.
+const testKeymap = `xkb_keymap {
+xkb_keycodes "evdev+aliases(qwertz)" {
+	minimum = 8;
+	maximum = 255;
+	<ESC>                = 9;
+	<AE01>               = 10;
+	<AE02>               = 11;
+	<TLDE>               = 49;
+	<AE12>               = 21;
+	<AD01>               = 24;
+	<AD03>               = 26;
+	<AB01>               = 52;
+	<AC01>               = 38;
+	<AC10>               = 47;
+	<BKSL>               = 51;
+	<RTRN>               = 36;
+	<SPCE>               = 65;
+	<LFSH>               = 50;
+	<RALT>               = 108;
+	<LVL3>               = 92;
+	<KP7>                = 79;
+	<KPAD>               = 86;
+	<I120>               = 120;
+	indicator 1 = "Caps Lock";
+	alias <AC12>         = <BKSL>;
+};
+
+xkb_types "complete" {
+	virtual_modifiers NumLock,Alt,LevelThree;
+	type "FOUR_LEVEL_SEMIALPHABETIC" {
+		modifiers= Shift+Lock+LevelThree;
+		map[Shift]= Level2;
+		level_name[Level1]= "Base";
+	};
+};
+
+xkb_compatibility "complete" {
+	interpret ISO_Level3_Shift+AnyOf(all) {
+		useModMapMods=level1;
+		action= SetMods(modifiers=LevelThree,clearLocks);
+	};
+	indicator "Caps Lock" {
+		whichModState= locked;
+		modifiers= Lock;
+	};
+};
+
+xkb_symbols "pc+de+ru:2+inet(evdev)" {
+	name[Group1]="German";
+	name[Group2]="Russian";
+
+	key <ESC>                {	[          Escape ] };
+	key <AE01>               {
+		type= "FOUR_LEVEL",
+		symbols[Group1]= [               1,          exclam,     onesuperior,      exclamdown ],
+		symbols[Group2]= [               1,          exclam ]
+	};
+	key <AE02>               {	[               2,        quotedbl ], [ 2, quotedbl ] };
+	key <TLDE>               {	[ dead_circumflex,          degree ] };
+	key <AE12>               {	[      dead_acute,      dead_grave ] };
+	key <AD01>               {
+		type[Group1]= "FOUR_LEVEL_SEMIALPHABETIC",
+		symbols[Group1]= [               q,               Q,              at,     Greek_OMEGA ],
+		symbols[Group2]= [ Cyrillic_shorti, Cyrillic_SHORTI ]
+	};
+	key <AD03>               {
+		type= "FOUR_LEVEL_SEMIALPHABETIC",
+		symbols[Group1]= [               e,               E,        EuroSign,        EuroSign ]
+	};
+	key <AB01>               {	[               y,               Y, guillemotright,   U203A ] };
+	key <AC01>               {	[               a,               A,              ae,              AE ] };
+	key <AC10>               {	[      odiaeresis,      Odiaeresis,  dead_doubleacute ] };
+	key <AC12>               {	[      numbersign,      apostrophe,       0x100263a ] };
+	key <RTRN>               {	[          Return ] };
+	key <SPCE>               {	[           space ] };
+	key <LFSH>               {	[         Shift_L ] };
+	key <RALT>               {
+		type= "ONE_LEVEL",
+		symbols[Group1]= [ ISO_Level3_Shift ]
+	};
+	key <LVL3>               {	[ ISO_Level3_Shift ] };
+	key <KP7>                {	[         KP_Home,            KP_7 ] };
+	key <KPAD>               {
+		type= "KEYPAD",
+		symbols[Group1]= [          KP_Add,          KP_Add ]
+	};
+	key <I120>               {	[     XF86Launch1 ] };
+	modifier_map Shift { <LFSH> };
+	modifier_map Mod5 { <LVL3> };
+};
+
+xkb_geometry "pc(pc105)" {
+	width= 470;
+	shape "NORM" { { [ 18, 18 ] }, { [ 2, 1 ], [ 16, 16 ] } };
+};
+};
+`
+
+func TestParseXKB(t *testing.T) {
+	l, err := ParseXKB(strings.NewReader(testKeymap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Name() != "German" {
+		t.Errorf("Name() = %q, want German", l.Name())
+	}
+	if l.AltGr() != evdev.KEY_RIGHTALT {
+		t.Errorf("AltGr() = %s, want KEY_RIGHTALT", evdev.CodeName(evdev.EV_KEY, l.AltGr()))
+	}
+
+	tests := []struct {
+		code evdev.EvCode
+		want Key
+	}{
+		{evdev.KEY_1, Key{Levels: [4]Sym{{Rune: '1'}, {Rune: '!'}, {Rune: '¹'}, {Rune: '¡'}}}},
+		{evdev.KEY_Q, Key{Levels: [4]Sym{{Rune: 'q'}, {Rune: 'Q'}, {Rune: '@'}, {Rune: 'Ω'}}, Alphabetic: true}},
+		{evdev.KEY_E, Key{Levels: [4]Sym{{Rune: 'e'}, {Rune: 'E'}, {Rune: '€'}, {Rune: '€'}}, Alphabetic: true}},
+		{evdev.KEY_Z, Key{Levels: [4]Sym{{Rune: 'y'}, {Rune: 'Y'}, {Rune: '»'}, {Rune: '›'}}, Alphabetic: true}},
+		{evdev.KEY_SEMICOLON, Key{Levels: [4]Sym{{Rune: 'ö'}, {Rune: 'Ö'}, {Rune: '\u030b', Dead: true}}, Alphabetic: true}},
+		{evdev.KEY_BACKSLASH, Key{Levels: [4]Sym{{Rune: '#'}, {Rune: '\''}, {Rune: '☺'}}}},
+		{evdev.KEY_GRAVE, Key{Levels: [4]Sym{{Rune: '\u0302', Dead: true}, {Rune: '°'}}}},
+		{evdev.KEY_ENTER, Key{Levels: [4]Sym{{Rune: '\n'}}}},
+		{evdev.KEY_KP7, Key{Levels: [4]Sym{{}, {Rune: '7'}}, Keypad: true}},
+		{evdev.KEY_KPPLUS, Key{Levels: [4]Sym{{Rune: '+'}, {Rune: '+'}}, Keypad: true}},
+	}
+	for _, tt := range tests {
+		if got, ok := l.Key(tt.code); !ok || got != tt.want {
+			t.Errorf("Key(%s) = %+v, %v; want %+v", evdev.CodeName(evdev.EV_KEY, tt.code), got, ok, tt.want)
+		}
+	}
+	for _, code := range []evdev.EvCode{evdev.KEY_LEFTSHIFT, evdev.KEY_RIGHTALT, evdev.KEY_PROG1} {
+		if _, ok := l.Key(code); ok {
+			t.Errorf("Key(%s) present, want absent: it types nothing", evdev.CodeName(evdev.EV_KEY, code))
+		}
+	}
+}
+
+// TestParseXKBTranslate drives both directions with a parsed keymap.
+func TestParseXKBTranslate(t *testing.T) {
+	l, err := ParseXKB(strings.NewReader(testKeymap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := feed(NewTranslator(l),
+		tap(evdev.KEY_GRAVE), tap(evdev.KEY_E),
+		shifted(evdev.KEY_RIGHTALT, evdev.KEY_Q),
+		shifted(evdev.KEY_LEFTSHIFT, evdev.KEY_Z),
+	)
+	if want := "ê@Y"; got != want {
+		t.Errorf("typed %q, want %q", got, want)
+	}
+
+	if s, ok := l.Strokes('€'); !ok || !slices.Equal(s, []Stroke{{evdev.KEY_E, ModAltGr}}) {
+		t.Errorf("Strokes('€') = %v, %v", s, ok)
+	}
+	const text = "Qé ê! è# 1€ »☺\n"
+	rec := &recorder{}
+	if err := NewTyper(rec, l, WithDelay(0)).Type(text); err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTranslator(l)
+	var typed []rune
+	for _, ev := range rec.evs {
+		typed = append(typed, tr.Feed(ev)...)
+	}
+	if string(typed) != text {
+		t.Errorf("round trip typed %q, want %q", string(typed), text)
+	}
+}
+
+func TestParseXKBErrors(t *testing.T) {
+	tests := []struct {
+		keymap string
+		want   string
+	}{
+		{"xkb_keycodes { <AE01> = 10; };", "no xkb_symbols section"},
+		{"xkb_keycodes {\n<AE01> = ten;\n};", "line 2: invalid keycode"},
+		{"xkb_keycodes { <AE01> = 10; };\nxkb_symbols {\n\tkey <AE02> { [ 2 ] };\n};", "line 3: undefined key <AE02>"},
+		{"xkb_symbols {\n\tkey <AE01> { [ 1, exclam };\n};", "line 2: expected , or ], found \"}\""},
+		{"xkb_symbols {\n\tkey <AE01 { [ 1 ] };\n};", "line 2: unterminated <"},
+		{"xkb_keycodes { <AE01> = 10; };\nxkb_symbols {\n\tkey <AE01> { [ 1 ] };\n", "line 4: unexpected end of keymap"},
+	}
+	for _, tt := range tests {
+		_, err := ParseXKB(strings.NewReader(tt.keymap))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseXKB(%q) error = %v, want %q", tt.keymap, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package evdev
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DeviceMatcher reports whether d is a device to use. Config.Matches is one.
+type DeviceMatcher func(d *Device) (bool, error)
+
+// ManagedRemapper is a Remapper that survives its source being unplugged:
+// when the device goes away it waits for a device match selects to appear,
+// grabs it and carries on, keeping the same virtual device throughout so
+// applications never see the output vanish.
+//
+// The virtual device is created when a matching device is first attached,
+// mirroring it. A different device matching later is remapped into it as is,
+// so give match a pattern that selects one kind of device, or register the
+// difference with WithExtraCapabilities.
+type ManagedRemapper struct {
+	match   DeviceMatcher
+	o       remapOptions
+	watcher *Watcher
+	quit    chan struct{} // closed by Close
+
+	mu     sync.Mutex
+	mapper Mapper
+	out    *VirtualDevice   // nil until the first attach
+	own    string           // out's event node, never attached as a source
+	src    *Device          // attached source, or nil
+	r      *Remapper        // remapping src, or nil
+	leds   map[EvCode]int32 // LED state set on out, restored on reattach
+
+	// feedbackDone is closed when the LED forwarding goroutine exits; nil
+	// until it starts.
+	feedbackDone chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewManagedRemapper returns a ManagedRemapper for the first device match
+// selects, mapped by fn; it takes the same options as NewRemapper. It starts
+// watching for devices at once, so one plugged in before Run is not missed.
+// Call Run to remap and Close to stop.
+func NewManagedRemapper(match DeviceMatcher, fn MapFunc, opts ...RemapOption) (*ManagedRemapper, error) {
+	o := remapOptions{name: "go-evdev remapper"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	w, err := NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	m := &ManagedRemapper{
+		match:   match,
+		mapper:  fn,
+		o:       o,
+		watcher: w,
+		quit:    make(chan struct{}),
+		leds:    map[EvCode]int32{},
+	}
+	if o.mapper != nil {
+		m.mapper, m.o.mapper = o.mapper, nil
+	}
+	return m, nil
+}
+
+// SetMapFunc replaces the mapping with fn; see SetMapper.
+func (m *ManagedRemapper) SetMapFunc(fn MapFunc) { m.SetMapper(fn) }
+
+// SetMapper replaces the mapping with mp, as Remapper.SetMapper does, for the
+// device attached now and any attached later.
+func (m *ManagedRemapper) SetMapper(mp Mapper) {
+	m.mu.Lock()
+	m.mapper = mp
+	r := m.r
+	m.mu.Unlock()
+	if r != nil {
+		r.SetMapper(mp)
+	}
+}
+
+// Output returns the virtual device, or nil if no device has been attached
+// yet.
+func (m *ManagedRemapper) Output() *VirtualDevice {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.out
+}
+
+// Run remaps the matching device whenever one is present, waiting for it
+// while none is, until Close is called (returning nil) or something other
+// than the device going away fails: the watch, creating the virtual device,
+// the grab, or a write. The panic chord and the watchdog stop it too, with
+// ErrPanicChord and ErrStalled. It blocks, so run it in its own goroutine if
+// the caller needs to do other work.
+func (m *ManagedRemapper) Run() error {
+	for {
+		d, err := m.wait()
+		if d == nil {
+			return err
+		}
+		err = m.attach(d)
+		select {
+		case <-m.quit:
+			return nil
+		default:
+		}
+		if err != nil && !errors.Is(err, unix.ENODEV) {
+			return err
+		}
+	}
+}
+
+// wait returns the first matching device present, or the next to appear. It
+// returns nil once the ManagedRemapper is closed. The virtual device, which
+// shares its source's ID, is never one: remapping it into itself would loop.
+func (m *ManagedRemapper) wait() (*Device, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	own := m.own
+	m.mu.Unlock()
+	var found *Device
+	for _, d := range devices {
+		if d.Path() == own {
+			d.Close()
+			continue
+		}
+		if ok, _ := m.match(d); ok && found == nil {
+			found = d
+			continue
+		}
+		d.Close()
+	}
+	if found != nil {
+		return found, nil
+	}
+
+	for {
+		var ev DeviceEvent
+		var ok bool
+		select {
+		case ev, ok = <-m.watcher.Events():
+		case <-m.quit:
+			return nil, nil
+		}
+		if !ok {
+			select {
+			case <-m.quit:
+				return nil, nil
+			case err := <-m.watcher.Errors():
+				return nil, err
+			}
+		}
+		if ev.Action != DeviceAdded || ev.Path == own {
+			continue
+		}
+		d, err := m.open(ev.Path)
+		if err != nil {
+			continue // gone again, or never accessible
+		}
+		if ok, _ := m.match(d); ok {
+			return d, nil
+		}
+		d.Close()
+	}
+}
+
+// open opens a newly added node, retrying while udev is still applying its
+// access rules.
+func (m *ManagedRemapper) open(path string) (*Device, error) {
+	for range 20 {
+		d, err := Open(path)
+		if !errors.Is(err, fs.ErrPermission) {
+			return d, err
+		}
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-m.quit:
+			return nil, err
+		}
+	}
+	return Open(path)
+}
+
+// attach grabs d and remaps it into the virtual device, creating that on the
+// first call, until d goes away. It closes d.
+func (m *ManagedRemapper) attach(d *Device) error {
+	defer d.Close()
+	m.mu.Lock()
+	select {
+	case <-m.quit:
+		m.mu.Unlock()
+		return nil
+	default:
+	}
+	if m.out == nil {
+		out, _, err := newOutput([]*Device{d}, m.o)
+		if err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		own, err := out.EventPath(ctx)
+		cancel()
+		if err != nil {
+			out.Close()
+			m.mu.Unlock()
+			return err
+		}
+		m.out, m.own = out, own
+		if m.o.ledFeedback {
+			m.feedbackDone = make(chan struct{})
+			go m.forwardLEDs()
+		}
+	}
+	r := &Remapper{srcs: []EventSource{d}, dst: m.out, grabbed: []*Device{d}}
+	r.setMapper(m.mapper, m.o)
+	m.src, m.r = d, r
+	leds := make([]InputEvent, 0, len(m.leds))
+	for code, value := range m.leds {
+		leds = append(leds, InputEvent{Type: EV_LED, Code: code, Value: value})
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.src, m.r = nil, nil
+		m.mu.Unlock()
+	}()
+
+	if err := d.Grab(); err != nil {
+		return err
+	}
+	defer r.ungrab()
+	if len(leds) > 0 {
+		// The device comes back with its LEDs off; best effort.
+		writeLEDs(d, leds...)
+	}
+	return r.Run() // releasing held keys when d goes away
+}
+
+// forwardLEDs copies EV_LED feedback from the virtual device to the attached
+// source, remembering it for the next, until the virtual device is closed.
+func (m *ManagedRemapper) forwardLEDs() {
+	defer close(m.feedbackDone)
+	for {
+		ev, err := m.out.ReadFeedback()
+		if err != nil {
+			return
+		}
+		if ev.Type != EV_LED {
+			continue
+		}
+		m.mu.Lock()
+		m.leds[ev.Code] = ev.Value
+		src := m.src
+		m.mu.Unlock()
+		if src != nil {
+			writeLEDs(src, ev) // the source may be going away
+		}
+	}
+}
+
+// writeLEDs sends LED changes to d as one frame.
+func writeLEDs(d *Device, evs ...InputEvent) error {
+	for _, ev := range append(evs, InputEvent{Type: EV_SYN, Code: SYN_REPORT}) {
+		if err := d.Write(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops Run, releases any keys it holds down and the attached device,
+// and destroys the virtual device. It is safe to call more than once.
+func (m *ManagedRemapper) Close() error {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		close(m.quit)
+		src, r, out := m.src, m.r, m.out
+		m.mu.Unlock()
+
+		errs := []error{m.watcher.Close()}
+		if r != nil {
+			errs = append(errs, r.releaseHeld())
+		}
+		if src != nil {
+			src.Close() // unblocks Run's read; attach closes it too
+		}
+		if out != nil {
+			errs = append(errs, out.Close())
+			if m.feedbackDone != nil {
+				<-m.feedbackDone
+			}
+		}
+		m.closeErr = firstErr(errs...)
+	})
+	return m.closeErr
+}
@@ -0,0 +1,93 @@
+package evdev
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// TestManagedRemapperReattach unplugs and replugs a virtual keyboard under a
+// ManagedRemapper and checks the remapped output survives, still carrying the
+// new source's keys. It needs root for /dev/uinput and skips otherwise.
+func TestManagedRemapperReattach(t *testing.T) {
+	const name = "go-evdev managed test"
+	testManagedRemapper(t, name, func(d *Device) (bool, error) {
+		n, err := d.Name()
+		return n == name, err
+	})
+}
+
+// TestManagedRemapperSkipsOutput matches on vendor and product alone, which
+// the virtual device shares with its source: while the source is unplugged,
+// the ManagedRemapper must not grab its own output.
+func TestManagedRemapperSkipsOutput(t *testing.T) {
+	testManagedRemapper(t, "go-evdev managed id test", func(d *Device) (bool, error) {
+		id, err := d.ID()
+		return id.Vendor == 0x9991 && id.Product == 0x9993, err
+	})
+}
+
+func testManagedRemapper(t *testing.T, name string, match DeviceMatcher) {
+	f, err := os.OpenFile(uinputPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skipf("cannot write %s (need root): %v", uinputPath, err)
+	}
+	f.Close()
+
+	id := InputID{BusType: BUS_USB, Vendor: 0x9991, Product: 0x9993, Version: 1}
+	plug := func() *VirtualKeyboard {
+		t.Helper()
+		kbd, err := NewVirtualKeyboard(name, id)
+		if err != nil {
+			t.Fatalf("NewVirtualKeyboard: %v", err)
+		}
+		return kbd
+	}
+	kbd := plug()
+
+	m, err := NewManagedRemapper(match, func(ev InputEvent) []InputEvent { return []InputEvent{ev} }, WithName(name+" out"))
+	if err != nil {
+		kbd.Close()
+		t.Fatalf("NewManagedRemapper: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- m.Run() }()
+	defer func() {
+		m.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}()
+
+	out := openByName(t, name+" out")
+	defer out.Close()
+	v := m.Output()
+
+	kbd.Close()
+	time.Sleep(200 * time.Millisecond)
+	m.mu.Lock()
+	src := m.src
+	m.mu.Unlock()
+	if src != nil {
+		t.Fatalf("attached %s while the source was unplugged", src.Path())
+	}
+	kbd = plug()
+	defer kbd.Close()
+	// Give the remapper time to grab the new device before typing on it.
+	time.Sleep(200 * time.Millisecond)
+	if err := kbd.Tap(KEY_A); err != nil {
+		t.Fatalf("Tap: %v", err)
+	}
+	for {
+		ev, err := out.ReadOne()
+		if err != nil {
+			t.Fatalf("ReadOne: %v", err)
+		}
+		if ev.Type == EV_KEY && ev.Code == KEY_A {
+			break
+		}
+	}
+	if m.Output() != v {
+		t.Error("virtual device replaced on reattach")
+	}
+}
@@ -0,0 +1,184 @@
+package evdev
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Mapper is a stateful mapping for a Remapper: it receives each source event
+// and emits its output through a MapContext, which can also schedule timer
+// callbacks — for tap timeouts, auto-release or turbo fire, which a MapFunc
+// cannot express as it only runs when an event arrives. Install one with
+// WithMapper.
+//
+// The Remapper calls Map and every timer callback one at a time from its Run
+// loop, so a Mapper needs no locking of its own.
+type Mapper interface {
+	// Map handles one source event. EV_SYN frame markers are forwarded by the
+	// Remapper and not passed to Map.
+	Map(ctx MapContext, ev InputEvent)
+}
+
+// FrameMapper is a Mapper that is also told when each source frame ends, as a
+// Pipeline needs to map whole frames. The Remapper calls EndFrame when the
+// source's EV_SYN arrives, or the source ends, before writing the frame;
+// MapContext.Source names the source. A frame left empty is not written, not
+// even its SYN_REPORT.
+type FrameMapper interface {
+	Mapper
+	EndFrame(ctx MapContext)
+}
+
+// MapContext is a Mapper's access to its Remapper. Its methods may only be
+// called from Map and timer callbacks.
+type MapContext interface {
+	// Emit queues events for output. Events emitted by Map join the frame the
+	// source event belongs to, written when the source's SYN_REPORT arrives;
+	// events emitted by a timer callback are written as a frame of their own
+	// once it returns. Include SYN_REPORT events to split them into several
+	// frames.
+	Emit(evs ...InputEvent)
+	// Source returns the index of the source the event being mapped came
+	// from, in the slice passed to NewMultiRemapper; it is 0 for a Remapper
+	// with one source, and -1 in a timer callback.
+	Source() int
+	// Now returns the current time on the Remapper's clock.
+	Now() time.Time
+	// AfterFunc schedules f to run on the Remapper's loop once d has
+	// elapsed. Stopping the returned Timer from the loop guarantees f does
+	// not run.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Map implements Mapper, emitting f's result.
+func (f MapFunc) Map(ctx MapContext, ev InputEvent) { ctx.Emit(f(ev)...) }
+
+// Map implements Mapper, emitting f's result.
+func (f SourceMapFunc) Map(ctx MapContext, ev InputEvent) { ctx.Emit(f(ctx.Source(), ev)...) }
+
+// loopContext is the MapContext of a running Remapper.
+type loopContext struct {
+	r   *Remapper
+	out *VirtualDevice // checked for strict writes, or nil
+
+	frames [][]InputEvent // Map's output for each source's current frame
+	open   []bool         // sources with a frame partly mapped
+	src    int            // source of the event being mapped
+	fired  []InputEvent   // a timer callback's output
+	timer  bool           // in a timer callback
+	err    error          // first unregistered event emitted
+
+	calls   chan func()        // timer callbacks posted to the loop
+	swaps   chan struct{}      // signalled by SetMapper; buffered, never blocks
+	pending Mapper             // mapping set by SetMapper, guarded by r.mu
+	next    Mapper             // mapping waiting for the frames to end, or nil
+	gen     int                // mappings swapped in; older timers do not run
+	raw     map[sourceKey]bool // source keys down, for the panic chord
+	done    chan struct{}      // closed when Run returns
+}
+
+func (c *loopContext) Emit(evs ...InputEvent) {
+	if c.out != nil && c.err == nil {
+		for _, e := range evs {
+			if err := c.out.unregistered(e); err != nil {
+				c.err = err
+				break
+			}
+		}
+	}
+	if c.timer {
+		c.fired = append(c.fired, evs...)
+	} else {
+		c.frames[c.src] = append(c.frames[c.src], evs...)
+	}
+}
+
+func (c *loopContext) Source() int {
+	if c.timer {
+		return -1
+	}
+	return c.src
+}
+
+func (c *loopContext) Now() time.Time { return c.r.clock.Now() }
+
+func (c *loopContext) AfterFunc(d time.Duration, f func()) Timer {
+	t := &loopTimer{}
+	gen := c.gen
+	t.t = c.r.clock.AfterFunc(d, func() {
+		select {
+		case c.calls <- func() {
+			if !t.stopped && c.gen == gen {
+				t.ran = true
+				f()
+			}
+		}:
+		case <-c.done:
+		}
+	})
+	return t
+}
+
+// loopTimer is a Timer whose callback is posted to the Remapper's loop, and
+// which Stop can still cancel while it waits there.
+type loopTimer struct {
+	t            Timer
+	stopped, ran bool // accessed only from the loop
+}
+
+func (t *loopTimer) Stop() bool {
+	if t.stopped || t.ran {
+		return false
+	}
+	t.stopped = true
+	t.t.Stop()
+	return true
+}
+
+// runTimer runs a posted timer callback and writes what it emitted as a
+// frame.
+func (c *loopContext) runTimer(f func()) error {
+	c.timer = true
+	f()
+	c.timer = false
+	evs := c.fired
+	c.fired = c.fired[:0]
+	if c.err != nil {
+		return fmt.Errorf("evdev: timer callback: %w", c.err)
+	}
+	if len(evs) == 0 {
+		return nil
+	}
+	if last := evs[len(evs)-1]; last.Type != EV_SYN || last.Code != SYN_REPORT {
+		evs = append(evs, InputEvent{Type: EV_SYN, Code: SYN_REPORT})
+	}
+	return c.r.flush(evs)
+}
+
+// swap installs the mapping SetMapper set once no source frame is partly
+// mapped, releasing the keys the old one left held.
+func (c *loopContext) swap() error {
+	c.r.mu.Lock()
+	if c.pending != nil {
+		c.next, c.pending = c.pending, nil
+	}
+	c.r.mu.Unlock()
+	if c.next == nil {
+		return nil
+	}
+	// A FrameMapper may hold a frame's events back until it ends, so a frame
+	// counts as partly mapped once Map has seen any of it.
+	if slices.Contains(c.open, true) {
+		return nil
+	}
+	if err := c.r.releaseHeld(); err != nil {
+		return err
+	}
+	c.r.mu.Lock()
+	c.r.setMapping(c.next)
+	c.r.mu.Unlock()
+	c.next = nil
+	c.gen++
+	return nil
+}
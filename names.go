@@ -76,6 +76,8 @@ func codePrefixForType(t EvType) string {
 		return "SND"
 	case EV_REP:
 		return "REP"
+	case EV_FF:
+		return "FF"
 	default:
 		return "CODE"
 	}
@@ -0,0 +1,236 @@
+package evdev
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Stage is one step of a Pipeline. It receives each frame — a source frame,
+// or one the previous stage emitted — and passes on what it wants through its
+// StageContext: the frame as is, a changed one, several, or none, now or from
+// a timer callback later.
+type Stage interface {
+	// Process handles one frame. The frame does not include its SYN_REPORT;
+	// it is only valid during the call.
+	Process(ctx StageContext, frame []InputEvent)
+}
+
+// StageFunc adapts a function to a Stage.
+type StageFunc func(ctx StageContext, frame []InputEvent)
+
+// Process implements Stage.
+func (f StageFunc) Process(ctx StageContext, frame []InputEvent) { f(ctx, frame) }
+
+// StageContext is a Stage's access to the rest of its Pipeline. Its methods
+// may only be called from Process and timer callbacks.
+type StageContext interface {
+	// Emit passes frame, without a SYN_REPORT, to the next stage, or writes it
+	// after the last. An empty frame is dropped.
+	Emit(frame []InputEvent)
+	// Source is MapContext.Source for the frame being processed.
+	Source() int
+	// Now returns the current time on the Remapper's clock.
+	Now() time.Time
+	// AfterFunc schedules f, as MapContext.AfterFunc does; frames it emits
+	// are written once it returns.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Pipeline is a FrameMapper chaining Stages, each passing frames to the next —
+// debounce, then remap, then log, say — so that each transform stays on its
+// own. Install one with WithPipeline, or WithMapper. Like other Mappers, use
+// it for one Remapper.
+type Pipeline struct {
+	stages  []Stage
+	ctxs    []pipelineContext
+	pending map[int][]InputEvent // each source's frame so far
+	out     []InputEvent         // frames emitted by the last stage
+}
+
+// NewPipeline returns a Pipeline running stages in order. With no stages, it
+// passes every frame through.
+func NewPipeline(stages ...Stage) *Pipeline {
+	p := &Pipeline{stages: stages, pending: map[int][]InputEvent{}}
+	p.ctxs = make([]pipelineContext, len(stages)+1)
+	for i := range p.ctxs {
+		p.ctxs[i] = pipelineContext{p: p, next: i}
+	}
+	return p
+}
+
+// WithPipeline maps events with NewPipeline(stages...), as WithMapper does.
+func WithPipeline(stages ...Stage) RemapOption {
+	return WithMapper(NewPipeline(stages...))
+}
+
+// Map implements Mapper, collecting the source frame.
+func (p *Pipeline) Map(ctx MapContext, ev InputEvent) {
+	p.pending[ctx.Source()] = append(p.pending[ctx.Source()], ev)
+}
+
+// EndFrame implements FrameMapper, running the source frame through the
+// stages.
+func (p *Pipeline) EndFrame(ctx MapContext) {
+	frame := p.pending[ctx.Source()]
+	p.pending[ctx.Source()] = frame[:0]
+	p.run(ctx, func() { p.ctxs[0].Emit(frame) })
+	if n := len(p.out); n > 0 {
+		// The Remapper ends the frame with the source's own SYN_REPORT.
+		p.out = p.out[:n-1]
+	}
+	ctx.Emit(p.out...)
+	p.out = p.out[:0]
+}
+
+// run runs f with ctx as every stage's MapContext.
+func (p *Pipeline) run(ctx MapContext, f func()) {
+	for i := range p.ctxs {
+		p.ctxs[i].mc = ctx
+	}
+	f()
+}
+
+// pipelineContext is the StageContext of the stage before stages[next].
+type pipelineContext struct {
+	p    *Pipeline
+	next int
+	mc   MapContext
+}
+
+func (c *pipelineContext) Emit(frame []InputEvent) {
+	if len(frame) == 0 {
+		return
+	}
+	if c.next < len(c.p.stages) {
+		c.p.stages[c.next].Process(&c.p.ctxs[c.next+1], frame)
+		return
+	}
+	c.p.out = append(c.p.out, frame...)
+	c.p.out = append(c.p.out, InputEvent{Type: EV_SYN, Code: SYN_REPORT})
+}
+
+func (c *pipelineContext) Source() int    { return c.mc.Source() }
+func (c *pipelineContext) Now() time.Time { return c.mc.Now() }
+
+func (c *pipelineContext) AfterFunc(d time.Duration, f func()) Timer {
+	mc := c.mc
+	return mc.AfterFunc(d, func() {
+		c.p.run(mc, f)
+		mc.Emit(c.p.out...)
+		c.p.out = c.p.out[:0]
+	})
+}
+
+// MapStage is a Stage applying fn to each event: a remap, or a macro
+// expansion. SYN_REPORT events in fn's result split the frame.
+func MapStage(fn MapFunc) Stage {
+	return StageFunc(func(ctx StageContext, frame []InputEvent) {
+		var out []InputEvent
+		for _, ev := range frame {
+			for _, e := range fn(ev) {
+				if e.Type == EV_SYN && e.Code == SYN_REPORT {
+					ctx.Emit(out)
+					out = out[:0]
+					continue
+				}
+				out = append(out, e)
+			}
+		}
+		ctx.Emit(out)
+	})
+}
+
+// FilterStage is a Stage dropping the events keep returns false for.
+func FilterStage(keep func(InputEvent) bool) Stage {
+	return StageFunc(func(ctx StageContext, frame []InputEvent) {
+		var out []InputEvent
+		for _, ev := range frame {
+			if keep(ev) {
+				out = append(out, ev)
+			}
+		}
+		ctx.Emit(out)
+	})
+}
+
+// LogStage is a Stage writing each frame to w as a line of comma-separated
+// events, e.g. "EV_KEY KEY_A 1, EV_MSC MSC_SCAN 30", and passing it on.
+// Write errors are ignored.
+func LogStage(w io.Writer) Stage {
+	return StageFunc(func(ctx StageContext, frame []InputEvent) {
+		s := make([]string, len(frame))
+		for i, ev := range frame {
+			s[i] = ev.String()
+		}
+		fmt.Fprintln(w, strings.Join(s, ", "))
+		ctx.Emit(frame)
+	})
+}
+
+// DebounceStage is a Stage for worn switches that chatter: once a key changes
+// state, changes within d after are held back, and the key's final state is
+// emitted when d has passed if it differs. Repeats pass while the key is down.
+func DebounceStage(d time.Duration) Stage {
+	return &debounce{d: d, keys: map[sourceKey]*debounced{}}
+}
+
+type debounce struct {
+	d    time.Duration
+	keys map[sourceKey]*debounced
+}
+
+// debounced is the state of one key.
+type debounced struct {
+	down    bool      // state passed on
+	actual  bool      // state last seen
+	changed time.Time // when down last changed
+	timer   Timer     // settling, or nil
+}
+
+func (s *debounce) Process(ctx StageContext, frame []InputEvent) {
+	var out []InputEvent
+	for _, ev := range frame {
+		if ev.Type != EV_KEY {
+			out = append(out, ev)
+			continue
+		}
+		k := sourceKey{ctx.Source(), ev.Code}
+		st := s.keys[k]
+		if st == nil {
+			st = &debounced{}
+			s.keys[k] = st
+		}
+		if ev.Value == 2 {
+			if st.down {
+				out = append(out, ev)
+			}
+			continue
+		}
+		st.actual = ev.Value != 0
+		now := ctx.Now()
+		if elapsed := now.Sub(st.changed); !st.changed.IsZero() && elapsed < s.d {
+			if st.timer == nil {
+				st.timer = ctx.AfterFunc(s.d-elapsed, func() { s.settle(ctx, k.code, st) })
+			}
+			continue
+		}
+		if st.actual != st.down {
+			st.down, st.changed = st.actual, now
+			out = append(out, ev)
+		}
+	}
+	ctx.Emit(out)
+}
+
+// settle emits a key's state at the end of its debounce interval if it
+// differs from what was passed on.
+func (s *debounce) settle(ctx StageContext, code EvCode, st *debounced) {
+	st.timer = nil
+	if st.actual == st.down {
+		return
+	}
+	st.down, st.changed = st.actual, ctx.Now()
+	ctx.Emit([]InputEvent{keyEv(code, boolValue(st.down))})
+}
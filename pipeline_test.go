@@ -0,0 +1,99 @@
+package evdev
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPipeline runs a Remapper through a Pipeline of built-in stages: each
+// source frame goes through them in turn, a macro splits into frames, and an
+// emptied frame is dropped, SYN_REPORT and all.
+func TestPipeline(t *testing.T) {
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	scan := InputEvent{Type: EV_MSC, Code: MSC_SCAN, Value: 30}
+	src := &sliceSource{evs: []InputEvent{
+		scan, keyEv(KEY_A, 1), syn,
+		scan, keyEv(KEY_A, 0), syn,
+		keyEv(KEY_F1, 1), syn,
+	}}
+	dst := &sliceSink{}
+	var log strings.Builder
+	r := NewRemapperFrom(src, dst, nil, WithPipeline(
+		FilterStage(func(ev InputEvent) bool { return ev.Type != EV_MSC }),
+		MapStage(func(ev InputEvent) []InputEvent {
+			if ev.Code == KEY_F1 {
+				// A macro: tap Ctrl+C.
+				return []InputEvent{
+					keyEv(KEY_LEFTCTRL, 1), keyEv(KEY_C, 1), syn,
+					keyEv(KEY_C, 0), keyEv(KEY_LEFTCTRL, 0),
+				}
+			}
+			if ev.Value == 0 {
+				return nil
+			}
+			return []InputEvent{ev}
+		}),
+		LogStage(&log),
+	))
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []InputEvent{
+		keyEv(KEY_A, 1), syn,
+		keyEv(KEY_LEFTCTRL, 1), keyEv(KEY_C, 1), syn,
+		keyEv(KEY_C, 0), keyEv(KEY_LEFTCTRL, 0), syn,
+		keyEv(KEY_A, 0), syn, // released as the source ended
+	}
+	if !slices.Equal(dst.evs, want) {
+		t.Errorf("emitted %v, want %v", dst.evs, want)
+	}
+	wantLog := "EV_KEY KEY_A 1\n" +
+		"EV_KEY KEY_LEFTCTRL 1, EV_KEY KEY_C 1\n" +
+		"EV_KEY KEY_C 0, EV_KEY KEY_LEFTCTRL 0\n"
+	if log.String() != wantLog {
+		t.Errorf("logged %q, want %q", log.String(), wantLog)
+	}
+}
+
+// TestDebounceStage feeds a chattering key through DebounceStage on a
+// FakeClock.
+func TestDebounceStage(t *testing.T) {
+	ctx := &fakeMapContext{clock: NewFakeClock(time.Unix(0, 0))}
+	p := NewPipeline(DebounceStage(10 * time.Millisecond))
+	key := func(value int32) {
+		p.Map(ctx, keyEv(KEY_A, value))
+		p.EndFrame(ctx)
+	}
+	expect := func(want ...InputEvent) {
+		t.Helper()
+		if !slices.Equal(ctx.evs, want) {
+			t.Errorf("emitted %v, want %v", ctx.evs, want)
+		}
+		ctx.evs = nil
+	}
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+
+	key(1)
+	expect(keyEv(KEY_A, 1))
+	ctx.clock.Advance(2 * time.Millisecond)
+	key(0) // chatter
+	key(1)
+	key(2)
+	expect(keyEv(KEY_A, 2))
+	ctx.clock.Advance(8 * time.Millisecond)
+	expect() // settled pressed, as passed on
+
+	ctx.clock.Advance(20 * time.Millisecond)
+	key(0)
+	expect(keyEv(KEY_A, 0))
+	ctx.clock.Advance(3 * time.Millisecond)
+	key(1) // chatter, then nothing
+	key(0)
+	key(1)
+	expect()
+	ctx.clock.Advance(7 * time.Millisecond)
+	expect(keyEv(KEY_A, 1), syn) // settled pressed, unlike what was passed on
+}
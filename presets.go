@@ -0,0 +1,411 @@
+package evdev
+
+import "fmt"
+
+// The preset virtual devices below each wrap a VirtualDevice created with the
+// capabilities and properties desktops expect of that kind of device, plus
+// typed helpers that emit complete frames. The embedded VirtualDevice remains
+// available for anything the helpers do not cover. Like VirtualDevice, the
+// presets are not safe for concurrent use.
+
+// emitFrame writes events followed by a SYN_REPORT as one frame.
+func (v *VirtualDevice) emitFrame(evs ...InputEvent) error {
+	return v.WriteFrame(evs)
+}
+
+func keyEvent(code EvCode, value int32) InputEvent {
+	return InputEvent{Type: EV_KEY, Code: code, Value: value}
+}
+
+func absEvent(code EvCode, value int32) InputEvent {
+	return InputEvent{Type: EV_ABS, Code: code, Value: value}
+}
+
+// VirtualKeyboard is a virtual keyboard with the standard keys, lock LEDs and
+// kernel autorepeat.
+type VirtualKeyboard struct {
+	*VirtualDevice
+}
+
+// NewVirtualKeyboard creates a virtual keyboard. It registers every key from
+// KEY_ESC to KEY_MICMUTE, the Num, Caps and Scroll Lock LEDs, and EV_REP, so a
+// key held with Press repeats until Release.
+func NewVirtualKeyboard(name string, id InputID, opts ...VirtualOption) (*VirtualKeyboard, error) {
+	caps := Capabilities{
+		Leds:   []EvCode{LED_NUML, LED_CAPSL, LED_SCROLLL},
+		Repeat: true,
+	}
+	for c := KEY_ESC; c <= KEY_MICMUTE; c++ {
+		caps.Keys = append(caps.Keys, c)
+	}
+	v, err := CreateVirtualDevice(name, id, caps, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualKeyboard{v}, nil
+}
+
+// Press presses a key and holds it.
+func (k *VirtualKeyboard) Press(code EvCode) error { return k.emitFrame(keyEvent(code, 1)) }
+
+// Release releases a held key.
+func (k *VirtualKeyboard) Release(code EvCode) error { return k.emitFrame(keyEvent(code, 0)) }
+
+// Tap types a key or chord: it presses codes in order, then releases them in
+// reverse, so Tap(KEY_LEFTCTRL, KEY_C) is Ctrl+C.
+func (k *VirtualKeyboard) Tap(codes ...EvCode) error {
+	for _, c := range codes {
+		if err := k.Press(c); err != nil {
+			return err
+		}
+	}
+	for i := len(codes) - 1; i >= 0; i-- {
+		if err := k.Release(codes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VirtualMouse is a virtual mouse with five buttons and high-resolution
+// vertical and horizontal wheels.
+type VirtualMouse struct {
+	*VirtualDevice
+}
+
+// WheelDetent is the REL_WHEEL_HI_RES value of one wheel notch.
+const WheelDetent = 120
+
+// NewVirtualMouse creates a virtual mouse.
+func NewVirtualMouse(name string, id InputID, opts ...VirtualOption) (*VirtualMouse, error) {
+	v, err := CreateVirtualDevice(name, id, Capabilities{
+		Keys:  []EvCode{BTN_LEFT, BTN_RIGHT, BTN_MIDDLE, BTN_SIDE, BTN_EXTRA},
+		Rels:  []EvCode{REL_X, REL_Y, REL_WHEEL, REL_HWHEEL, REL_WHEEL_HI_RES, REL_HWHEEL_HI_RES},
+		Props: []InputProp{INPUT_PROP_POINTER},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualMouse{v}, nil
+}
+
+// Move moves the pointer by dx, dy.
+func (m *VirtualMouse) Move(dx, dy int32) error {
+	var evs []InputEvent
+	if dx != 0 {
+		evs = append(evs, InputEvent{Type: EV_REL, Code: REL_X, Value: dx})
+	}
+	if dy != 0 {
+		evs = append(evs, InputEvent{Type: EV_REL, Code: REL_Y, Value: dy})
+	}
+	return m.emitFrame(evs...)
+}
+
+// Scroll turns the wheels by whole notches: positive dy scrolls up and
+// positive dx right. It reports both the classic and high-resolution wheel
+// events, as real mice do.
+func (m *VirtualMouse) Scroll(dx, dy int32) error {
+	var evs []InputEvent
+	if dy != 0 {
+		evs = append(evs,
+			InputEvent{Type: EV_REL, Code: REL_WHEEL, Value: dy},
+			InputEvent{Type: EV_REL, Code: REL_WHEEL_HI_RES, Value: dy * WheelDetent})
+	}
+	if dx != 0 {
+		evs = append(evs,
+			InputEvent{Type: EV_REL, Code: REL_HWHEEL, Value: dx},
+			InputEvent{Type: EV_REL, Code: REL_HWHEEL_HI_RES, Value: dx * WheelDetent})
+	}
+	return m.emitFrame(evs...)
+}
+
+// Press presses and holds a button (BTN_LEFT, BTN_RIGHT, ...), e.g. to drag.
+func (m *VirtualMouse) Press(button EvCode) error { return m.emitFrame(keyEvent(button, 1)) }
+
+// Release releases a held button.
+func (m *VirtualMouse) Release(button EvCode) error { return m.emitFrame(keyEvent(button, 0)) }
+
+// Click presses and releases a button.
+func (m *VirtualMouse) Click(button EvCode) error {
+	if err := m.Press(button); err != nil {
+		return err
+	}
+	return m.Release(button)
+}
+
+// Stick selects one of a gamepad's analog sticks.
+type Stick int
+
+const (
+	LeftStick Stick = iota
+	RightStick
+)
+
+// Trigger selects one of a gamepad's analog triggers.
+type Trigger int
+
+const (
+	LeftTrigger Trigger = iota
+	RightTrigger
+)
+
+// Gamepad axis ranges: sticks are centred on 0, triggers rest at 0.
+const (
+	StickMin   = -32768
+	StickMax   = 32767
+	TriggerMax = 255
+)
+
+// VirtualGamepad is a virtual gamepad laid out like an Xbox controller, as
+// the kernel's gamepad API describes: two sticks, two analog triggers, a
+// D-pad reported as a hat, and the BTN_SOUTH/EAST/NORTH/WEST face buttons,
+// shoulders, Select/Start/Mode and stick clicks.
+type VirtualGamepad struct {
+	*VirtualDevice
+}
+
+// NewVirtualGamepad creates a virtual gamepad. Games and SDL recognise it by
+// its identity as much as its capabilities, so pass the bus, vendor and
+// product of the controller it stands in for.
+func NewVirtualGamepad(name string, id InputID, opts ...VirtualOption) (*VirtualGamepad, error) {
+	stick := AbsInfo{Minimum: StickMin, Maximum: StickMax, Fuzz: 16, Flat: 128}
+	trigger := AbsInfo{Maximum: TriggerMax}
+	hat := AbsInfo{Minimum: -1, Maximum: 1}
+	v, err := CreateVirtualDevice(name, id, Capabilities{
+		Keys: []EvCode{
+			BTN_SOUTH, BTN_EAST, BTN_NORTH, BTN_WEST, BTN_TL, BTN_TR,
+			BTN_SELECT, BTN_START, BTN_MODE, BTN_THUMBL, BTN_THUMBR,
+		},
+		Abs: []AbsAxis{
+			{ABS_X, stick}, {ABS_Y, stick}, {ABS_RX, stick}, {ABS_RY, stick},
+			{ABS_Z, trigger}, {ABS_RZ, trigger},
+			{ABS_HAT0X, hat}, {ABS_HAT0Y, hat},
+		},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualGamepad{v}, nil
+}
+
+// SetStick moves a stick to x, y in [StickMin, StickMax]; positive y is down.
+func (g *VirtualGamepad) SetStick(s Stick, x, y int32) error {
+	cx, cy := ABS_X, ABS_Y
+	if s == RightStick {
+		cx, cy = ABS_RX, ABS_RY
+	}
+	return g.emitFrame(absEvent(cx, x), absEvent(cy, y))
+}
+
+// SetTrigger sets how far a trigger is pulled, from 0 to TriggerMax.
+func (g *VirtualGamepad) SetTrigger(t Trigger, value int32) error {
+	code := ABS_Z
+	if t == RightTrigger {
+		code = ABS_RZ
+	}
+	return g.emitFrame(absEvent(code, value))
+}
+
+// SetDPad sets the D-pad: x and y are -1, 0 or 1, with -1 left and up.
+func (g *VirtualGamepad) SetDPad(x, y int32) error {
+	return g.emitFrame(absEvent(ABS_HAT0X, x), absEvent(ABS_HAT0Y, y))
+}
+
+// Press presses and holds a button (BTN_SOUTH, BTN_START, ...).
+func (g *VirtualGamepad) Press(button EvCode) error { return g.emitFrame(keyEvent(button, 1)) }
+
+// Release releases a held button.
+func (g *VirtualGamepad) Release(button EvCode) error { return g.emitFrame(keyEvent(button, 0)) }
+
+// Area is the extent of an absolute-position surface — a touchscreen or
+// tablet — in device units, and its resolution in units per millimetre, from
+// which desktops derive its physical size.
+type Area struct {
+	Width, Height int32
+	Resolution    int32
+}
+
+// axes returns the X and Y axes spanning the area with the given codes.
+func (a Area) axes(x, y EvCode) []AbsAxis {
+	return []AbsAxis{
+		{x, AbsInfo{Maximum: a.Width - 1, Resolution: a.Resolution}},
+		{y, AbsInfo{Maximum: a.Height - 1, Resolution: a.Resolution}},
+	}
+}
+
+// maxTrackingID bounds the tracking ids a VirtualTouchscreen assigns.
+const maxTrackingID = 65535
+
+// VirtualTouchscreen is a virtual multitouch screen using the kernel's slot
+// protocol (type B): each finger occupies a slot from Touch until Lift. It
+// also reports the first finger on the single-touch axes and BTN_TOUCH, for
+// clients that do not understand multitouch.
+type VirtualTouchscreen struct {
+	*VirtualDevice
+	contacts []int32 // tracking id per slot, -1 when lifted
+	nextID   int32
+}
+
+// NewVirtualTouchscreen creates a virtual touchscreen covering area, tracking
+// up to slots simultaneous fingers.
+func NewVirtualTouchscreen(name string, id InputID, area Area, slots int, opts ...VirtualOption) (*VirtualTouchscreen, error) {
+	if slots < 1 {
+		return nil, fmt.Errorf("evdev: touchscreen needs at least one slot, got %d", slots)
+	}
+	abs := append(area.axes(ABS_X, ABS_Y), area.axes(ABS_MT_POSITION_X, ABS_MT_POSITION_Y)...)
+	abs = append(abs,
+		AbsAxis{ABS_MT_SLOT, AbsInfo{Maximum: int32(slots - 1)}},
+		AbsAxis{ABS_MT_TRACKING_ID, AbsInfo{Maximum: maxTrackingID}})
+	v, err := CreateVirtualDevice(name, id, Capabilities{
+		Keys:  []EvCode{BTN_TOUCH},
+		Abs:   abs,
+		Props: []InputProp{INPUT_PROP_DIRECT},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newVirtualTouchscreen(v, slots), nil
+}
+
+func newVirtualTouchscreen(v *VirtualDevice, slots int) *VirtualTouchscreen {
+	t := &VirtualTouchscreen{VirtualDevice: v, contacts: make([]int32, slots)}
+	for i := range t.contacts {
+		t.contacts[i] = -1
+	}
+	return t
+}
+
+// Touch puts a finger down in slot at x, y, or moves the finger already there.
+func (t *VirtualTouchscreen) Touch(slot int, x, y int32) error {
+	if slot < 0 || slot >= len(t.contacts) {
+		return fmt.Errorf("evdev: touch slot %d out of range [0, %d)", slot, len(t.contacts))
+	}
+	evs := []InputEvent{absEvent(ABS_MT_SLOT, int32(slot))}
+	if t.contacts[slot] < 0 {
+		if t.active() == 0 {
+			evs = append(evs, keyEvent(BTN_TOUCH, 1))
+		}
+		t.contacts[slot] = t.nextID
+		t.nextID = (t.nextID + 1) % (maxTrackingID + 1)
+		evs = append(evs, absEvent(ABS_MT_TRACKING_ID, t.contacts[slot]))
+	}
+	evs = append(evs, absEvent(ABS_MT_POSITION_X, x), absEvent(ABS_MT_POSITION_Y, y))
+	if slot == t.first() {
+		evs = append(evs, absEvent(ABS_X, x), absEvent(ABS_Y, y))
+	}
+	return t.emitFrame(evs...)
+}
+
+// Lift lifts the finger in slot. Lifting an empty slot does nothing.
+func (t *VirtualTouchscreen) Lift(slot int) error {
+	if slot < 0 || slot >= len(t.contacts) || t.contacts[slot] < 0 {
+		return nil
+	}
+	t.contacts[slot] = -1
+	evs := []InputEvent{absEvent(ABS_MT_SLOT, int32(slot)), absEvent(ABS_MT_TRACKING_ID, -1)}
+	if t.active() == 0 {
+		evs = append(evs, keyEvent(BTN_TOUCH, 0))
+	}
+	return t.emitFrame(evs...)
+}
+
+// active counts the fingers down.
+func (t *VirtualTouchscreen) active() int {
+	n := 0
+	for _, id := range t.contacts {
+		if id >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// first returns the lowest occupied slot, whose finger drives the
+// single-touch axes, or -1.
+func (t *VirtualTouchscreen) first() int {
+	for slot, id := range t.contacts {
+		if id >= 0 {
+			return slot
+		}
+	}
+	return -1
+}
+
+// Tablet pen axis ranges: pressure runs from 0 to TabletMaxPressure, and tilt
+// is in degrees from vertical, -TabletMaxTilt to TabletMaxTilt.
+const (
+	TabletMaxPressure = 4095
+	TabletMaxTilt     = 90
+)
+
+// VirtualTablet is a virtual pen tablet reporting position, pressure and tilt.
+type VirtualTablet struct {
+	*VirtualDevice
+	inRange  bool
+	touching bool
+}
+
+// NewVirtualTablet creates a virtual pen tablet covering area, with two
+// stylus buttons.
+func NewVirtualTablet(name string, id InputID, area Area, opts ...VirtualOption) (*VirtualTablet, error) {
+	tilt := AbsInfo{Minimum: -TabletMaxTilt, Maximum: TabletMaxTilt, Resolution: 57} // units per radian
+	abs := append(area.axes(ABS_X, ABS_Y),
+		AbsAxis{ABS_PRESSURE, AbsInfo{Maximum: TabletMaxPressure}},
+		AbsAxis{ABS_TILT_X, tilt},
+		AbsAxis{ABS_TILT_Y, tilt})
+	v, err := CreateVirtualDevice(name, id, Capabilities{
+		Keys:  []EvCode{BTN_TOOL_PEN, BTN_TOUCH, BTN_STYLUS, BTN_STYLUS2},
+		Abs:   abs,
+		Props: []InputProp{INPUT_PROP_POINTER},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualTablet{VirtualDevice: v}, nil
+}
+
+// Pen moves the pen to x, y with the given pressure and tilt, bringing it into
+// range first if needed. The pen touches the surface while pressure is
+// positive and hovers at 0.
+func (t *VirtualTablet) Pen(x, y, pressure, tiltX, tiltY int32) error {
+	var evs []InputEvent
+	if !t.inRange {
+		t.inRange = true
+		evs = append(evs, keyEvent(BTN_TOOL_PEN, 1))
+	}
+	evs = append(evs,
+		absEvent(ABS_X, x), absEvent(ABS_Y, y), absEvent(ABS_PRESSURE, pressure),
+		absEvent(ABS_TILT_X, tiltX), absEvent(ABS_TILT_Y, tiltY))
+	if touching := pressure > 0; touching != t.touching {
+		t.touching = touching
+		evs = append(evs, keyEvent(BTN_TOUCH, boolValue(touching)))
+	}
+	return t.emitFrame(evs...)
+}
+
+// Leave lifts the pen out of range. It does nothing if the pen is not in
+// range.
+func (t *VirtualTablet) Leave() error {
+	if !t.inRange {
+		return nil
+	}
+	var evs []InputEvent
+	if t.touching {
+		t.touching = false
+		evs = append(evs, absEvent(ABS_PRESSURE, 0), keyEvent(BTN_TOUCH, 0))
+	}
+	t.inRange = false
+	return t.emitFrame(append(evs, keyEvent(BTN_TOOL_PEN, 0))...)
+}
+
+// PressButton presses or releases a stylus button (BTN_STYLUS, BTN_STYLUS2).
+func (t *VirtualTablet) PressButton(button EvCode, pressed bool) error {
+	return t.emitFrame(keyEvent(button, boolValue(pressed)))
+}
+
+func boolValue(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+package evdev
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// recordingDevice returns a VirtualDevice writing to a temporary file and a
+// function returning everything written to it so far, split into frames.
+func recordingDevice(t *testing.T) (*VirtualDevice, func() [][]InputEvent) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return &VirtualDevice{f: f}, func() [][]InputEvent {
+		r, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		dec := NewDecoder(r)
+		var frames [][]InputEvent
+		var frame []InputEvent
+		for {
+			ev, err := dec.ReadOne()
+			if err != nil {
+				return frames
+			}
+			if ev.Type == EV_SYN {
+				frames = append(frames, frame)
+				frame = nil
+				continue
+			}
+			frame = append(frame, ev)
+		}
+	}
+}
+
+func checkFrames(t *testing.T, got, want [][]InputEvent) {
+	t.Helper()
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("frames:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestVirtualKeyboardTap(t *testing.T) {
+	v, frames := recordingDevice(t)
+	k := &VirtualKeyboard{v}
+	if err := k.Tap(KEY_LEFTCTRL, KEY_C); err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, frames(), [][]InputEvent{
+		{keyEvent(KEY_LEFTCTRL, 1)}, {keyEvent(KEY_C, 1)},
+		{keyEvent(KEY_C, 0)}, {keyEvent(KEY_LEFTCTRL, 0)},
+	})
+}
+
+func TestVirtualMouse(t *testing.T) {
+	v, frames := recordingDevice(t)
+	m := &VirtualMouse{v}
+	if err := m.Move(5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Scroll(0, -2); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Click(BTN_LEFT); err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, frames(), [][]InputEvent{
+		{{Type: EV_REL, Code: REL_X, Value: 5}},
+		{{Type: EV_REL, Code: REL_WHEEL, Value: -2}, {Type: EV_REL, Code: REL_WHEEL_HI_RES, Value: -240}},
+		{keyEvent(BTN_LEFT, 1)}, {keyEvent(BTN_LEFT, 0)},
+	})
+}
+
+func TestVirtualGamepad(t *testing.T) {
+	v, frames := recordingDevice(t)
+	g := &VirtualGamepad{v}
+	if err := g.SetStick(RightStick, StickMax, StickMin); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetTrigger(LeftTrigger, 128); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetDPad(-1, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, frames(), [][]InputEvent{
+		{absEvent(ABS_RX, StickMax), absEvent(ABS_RY, StickMin)},
+		{absEvent(ABS_Z, 128)},
+		{absEvent(ABS_HAT0X, -1), absEvent(ABS_HAT0Y, 0)},
+	})
+}
+
+// TestVirtualTouchscreen checks the type B slot protocol: tracking ids are
+// assigned on contact and cleared on lift, and the single-touch axes follow
+// the lowest active slot.
+func TestVirtualTouchscreen(t *testing.T) {
+	v, frames := recordingDevice(t)
+	ts := newVirtualTouchscreen(v, 2)
+	for _, step := range []func() error{
+		func() error { return ts.Touch(1, 10, 20) },
+		func() error { return ts.Touch(0, 30, 40) },
+		func() error { return ts.Touch(1, 11, 21) },
+		func() error { return ts.Lift(0) },
+		func() error { return ts.Lift(0) },
+		func() error { return ts.Lift(1) },
+	} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkFrames(t, frames(), [][]InputEvent{
+		{absEvent(ABS_MT_SLOT, 1), keyEvent(BTN_TOUCH, 1), absEvent(ABS_MT_TRACKING_ID, 0),
+			absEvent(ABS_MT_POSITION_X, 10), absEvent(ABS_MT_POSITION_Y, 20), absEvent(ABS_X, 10), absEvent(ABS_Y, 20)},
+		{absEvent(ABS_MT_SLOT, 0), absEvent(ABS_MT_TRACKING_ID, 1),
+			absEvent(ABS_MT_POSITION_X, 30), absEvent(ABS_MT_POSITION_Y, 40), absEvent(ABS_X, 30), absEvent(ABS_Y, 40)},
+		{absEvent(ABS_MT_SLOT, 1), absEvent(ABS_MT_POSITION_X, 11), absEvent(ABS_MT_POSITION_Y, 21)},
+		{absEvent(ABS_MT_SLOT, 0), absEvent(ABS_MT_TRACKING_ID, -1)},
+		{absEvent(ABS_MT_SLOT, 1), absEvent(ABS_MT_TRACKING_ID, -1), keyEvent(BTN_TOUCH, 0)},
+	})
+	if err := ts.Touch(2, 0, 0); err == nil {
+		t.Error("Touch(2) on a two-slot screen succeeded")
+	}
+}
+
+func TestVirtualTablet(t *testing.T) {
+	v, frames := recordingDevice(t)
+	tab := &VirtualTablet{VirtualDevice: v}
+	if err := tab.Pen(100, 200, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Pen(100, 200, 500, 10, -5); err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Leave(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.Leave(); err != nil {
+		t.Fatal(err)
+	}
+	pen := func(p, tx, ty int32) []InputEvent {
+		return []InputEvent{absEvent(ABS_X, 100), absEvent(ABS_Y, 200), absEvent(ABS_PRESSURE, p),
+			absEvent(ABS_TILT_X, tx), absEvent(ABS_TILT_Y, ty)}
+	}
+	checkFrames(t, frames(), [][]InputEvent{
+		append([]InputEvent{keyEvent(BTN_TOOL_PEN, 1)}, pen(0, 0, 0)...),
+		append(pen(500, 10, -5), keyEvent(BTN_TOUCH, 1)),
+		{absEvent(ABS_PRESSURE, 0), keyEvent(BTN_TOUCH, 0), keyEvent(BTN_TOOL_PEN, 0)},
+	})
+}
@@ -1,9 +1,15 @@
 package evdev
 
 import (
+	"cmp"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 )
 
 // MapFunc transforms a source event into the events to emit in its place.
@@ -18,14 +24,55 @@ import (
 //	func(ev InputEvent) []InputEvent { return []InputEvent{ev} }
 type MapFunc func(InputEvent) []InputEvent
 
+// SourceMapFunc is a MapFunc for a Remapper with several sources, which is
+// also told the index of the source, in the slice passed to NewMultiRemapper,
+// that ev came from — so a foot pedal can act as Shift for a keyboard, say.
+type SourceMapFunc func(src int, ev InputEvent) []InputEvent
+
 // Remapper grabs a source device exclusively and re-emits its events — as
-// transformed by a MapFunc — through a uinput virtual device. It packages the
+// transformed by a MapFunc or Mapper — through a uinput virtual device. It packages the
 // grab -> read -> transform -> inject loop with correct setup and teardown, so a
-// client only has to express the mapping.
+// client only has to express the mapping. NewMultiRemapper merges several
+// sources into the one virtual device.
+//
+// The loop itself only needs an EventSource and an EventSink; NewRemapperFrom
+// runs it between arbitrary endpoints (a recording, a socket, a test fake).
 type Remapper struct {
-	src *Device
-	out *VirtualDevice
-	fn  MapFunc
+	srcs   []EventSource
+	dst    EventSink
+	mapper Mapper
+	clock  Clock
+
+	// chord is the panic chord, and watchdog the stall timeout; see
+	// WithPanicChord and WithWatchdog.
+	chord      []EvCode
+	watchdog   time.Duration
+	ungrabOnce sync.Once
+	ungrabErr  error
+
+	// grabbed and out are the device grabs and virtual device NewRemapper set
+	// up, released by Close. Both are nil for NewRemapperFrom, whose caller
+	// owns the endpoints.
+	grabbed []*Device
+	out     *VirtualDevice
+
+	// leds are the sources WithLEDFeedback forwards LED changes to.
+	leds []*Device
+
+	// feedbackDone is closed when the LED forwarding goroutine started by
+	// WithLEDFeedback exits; nil without it.
+	feedbackDone chan struct{}
+
+	// mu serializes writes to dst, so Close can release held keys while Run
+	// is writing, and guards down, running and stop.
+	mu      sync.Mutex
+	down    map[EvCode]bool // output keys written down
+	running *loopContext    // loop of the Run in progress, or nil
+	stop    context.CancelCauseFunc
+
+	// owned records, for a MapFunc or SourceMapFunc, the output keys each
+	// source key's press emitted down. Run's loop only.
+	owned map[sourceKey][]EvCode
 
 	closeOnce sync.Once
 	closeErr  error
@@ -35,10 +82,24 @@ type Remapper struct {
 type RemapOption func(*remapOptions)
 
 type remapOptions struct {
-	name  string
-	extra Capabilities
+	name        string
+	extra       Capabilities
+	ledFeedback bool
+	virtual     []VirtualOption
+	physSuffix  string
+	mapper      Mapper
+	clock       Clock
+	panicChord  []EvCode
+	watchdog    time.Duration
+
+	// absMoves are absolute axes a mapping moves to another code, registered
+	// with the range of the axis moved when the sources lack them.
+	absMoves []absMove
 }
 
+// absMove is an absolute axis mapped to another.
+type absMove struct{ from, to EvCode }
+
 // WithName sets the virtual device's name (default "go-evdev remapper").
 func WithName(name string) RemapOption {
 	return func(o *remapOptions) { o.name = name }
@@ -46,7 +107,9 @@ func WithName(name string) RemapOption {
 
 // WithExtraKeys registers EV_KEY codes on the virtual device beyond those the
 // source supports — needed when a mapping emits keys the source lacks, e.g. a
-// mouse button that types a letter or a Ctrl+C combo.
+// mouse button that types a letter or a Ctrl+C combo. Pass
+// WithVirtualOptions(WithStrictWrites()) to have Run report a forgotten key
+// rather than the kernel silently dropping it.
 func WithExtraKeys(keys ...EvCode) RemapOption {
 	return func(o *remapOptions) { o.extra.Keys = append(o.extra.Keys, keys...) }
 }
@@ -57,6 +120,45 @@ func WithExtraCapabilities(c Capabilities) RemapOption {
 	return func(o *remapOptions) { o.extra = mergeCaps(o.extra, c) }
 }
 
+// WithLEDFeedback forwards LED changes the system makes on the virtual device —
+// an application toggling Caps Lock, say — back to the grabbed source, so its
+// lights keep matching the lock state. It needs write access to the source's
+// device node, checked by NewRemapper. With several sources, every source with
+// LEDs gets the changes. Forwarding is best effort and stops at the first
+// failed write.
+func WithLEDFeedback() RemapOption {
+	return func(o *remapOptions) { o.ledFeedback = true }
+}
+
+// WithMapper maps events with m instead of the function passed to
+// NewRemapper or NewMultiRemapper, which may then be nil.
+func WithMapper(m Mapper) RemapOption {
+	return func(o *remapOptions) { o.mapper = m }
+}
+
+// WithClock sets the clock behind MapContext.Now and AfterFunc (default
+// SystemClock); tests pass a FakeClock.
+func WithClock(c Clock) RemapOption {
+	return func(o *remapOptions) { o.clock = c }
+}
+
+// WithVirtualOptions passes options such as WithPhys to CreateVirtualDevice
+// when NewRemapper creates the virtual device. They override WithPhysSuffix.
+func WithVirtualOptions(opts ...VirtualOption) RemapOption {
+	return func(o *remapOptions) { o.virtual = append(o.virtual, opts...) }
+}
+
+// WithPhysSuffix gives the virtual device the source's phys with suffix
+// appended — "usb-0000:00:14.0-2/input0" becomes
+// "usb-0000:00:14.0-2/input0/remap" for suffix "/remap" — so udev rules can
+// match the remapped device by where its source is plugged in while still
+// telling the two apart. A source without a phys leaves it unset. The uniq is
+// not copied, as mainline kernels cannot set it; where the kernel can, pass
+// WithUniq through WithVirtualOptions.
+func WithPhysSuffix(suffix string) RemapOption {
+	return func(o *remapOptions) { o.physSuffix = suffix }
+}
+
 // NewRemapper grabs src exclusively and builds a virtual device mirroring its
 // capabilities (plus any added via options), ready to re-emit events through fn.
 // Call Run to process events and Close to release the grab and destroy the
@@ -65,81 +167,615 @@ func WithExtraCapabilities(c Capabilities) RemapOption {
 // The caller retains ownership of src and must Close it separately; closing src
 // is also how a blocked Run is unblocked (see Run).
 func NewRemapper(src *Device, fn MapFunc, opts ...RemapOption) (*Remapper, error) {
+	return newDeviceRemapper([]*Device{src}, fn, opts)
+}
+
+// NewMultiRemapper is NewRemapper for several sources — a keyboard and its
+// separate media-key node, or a keyboard and a foot pedal — that should act as
+// one device. It grabs them all and builds a single virtual device with the
+// union of their capabilities; where two sources have the same absolute axis,
+// the first one's range is used. The virtual device takes the first source's
+// ID and, with WithPhysSuffix, its phys.
+//
+// fn is told which source each event came from; a Mapper installed with
+// WithMapper gets it from MapContext.Source. Each source's frames are kept
+// apart, so events mapped from one source are written when that source's
+// SYN_REPORT arrives.
+func NewMultiRemapper(srcs []*Device, fn SourceMapFunc, opts ...RemapOption) (*Remapper, error) {
+	if len(srcs) == 0 {
+		return nil, errors.New("evdev: NewMultiRemapper: no sources")
+	}
+	return newDeviceRemapper(srcs, fn, opts)
+}
+
+// newDeviceRemapper grabs srcs and creates the virtual device merging them.
+func newDeviceRemapper(srcs []*Device, fn Mapper, opts []RemapOption) (*Remapper, error) {
 	o := remapOptions{name: "go-evdev remapper"}
 	for _, opt := range opts {
 		opt(&o)
 	}
-
-	caps, err := CapabilitiesOf(src)
+	out, leds, err := newOutput(srcs, o)
 	if err != nil {
 		return nil, err
 	}
+	if o.ledFeedback {
+		for _, src := range leds {
+			if _, err := src.writer(); err != nil {
+				out.Close()
+				return nil, err
+			}
+		}
+	}
+	r := &Remapper{dst: out, out: out}
+	for _, src := range srcs {
+		if err := src.Grab(); err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.srcs = append(r.srcs, src)
+		r.grabbed = append(r.grabbed, src)
+	}
+	r.setMapper(fn, o)
+	if o.ledFeedback {
+		r.leds = leds
+		r.feedbackDone = make(chan struct{})
+		go r.forwardLEDs()
+	}
+	return r, nil
+}
+
+// newOutput creates the virtual device mirroring srcs, also returning the
+// sources with LEDs.
+func newOutput(srcs []*Device, o remapOptions) (*VirtualDevice, []*Device, error) {
+	var caps Capabilities
+	var leds []*Device
+	for _, src := range srcs {
+		c, err := CapabilitiesOf(src)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.Abs = slices.DeleteFunc(c.Abs, func(a AbsAxis) bool {
+			return slices.ContainsFunc(caps.Abs, func(b AbsAxis) bool { return a.Code == b.Code })
+		})
+		caps = mergeCaps(caps, c)
+		if len(c.Leds) > 0 {
+			leds = append(leds, src)
+		}
+	}
+	caps.Keys, caps.Rels = dedupe(caps.Keys), dedupe(caps.Rels)
+	caps.Mscs, caps.Sws, caps.Leds, caps.Snds = dedupe(caps.Mscs), dedupe(caps.Sws), dedupe(caps.Leds), dedupe(caps.Snds)
+	caps.Props = dedupe(caps.Props)
+	// The source's autorepeat arrives as value-2 events and is forwarded, so
+	// kernel repeat on the mirror would double it; and nothing here services
+	// force-feedback uploads, which would stall the clients sending them.
+	caps.Repeat, caps.FFs, caps.FFEffects = false, nil, 0
 	caps = mergeCaps(caps, o.extra)
+	caps.Abs = moveAbs(caps.Abs, o.absMoves)
 
-	id, err := src.ID()
+	id, err := srcs[0].ID()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	var vopts []VirtualOption
+	if o.physSuffix != "" {
+		// EVIOCGPHYS fails with ENOENT when the field is unset.
+		if phys, err := srcs[0].Phys(); err == nil && phys != "" {
+			vopts = append(vopts, WithPhys(phys+o.physSuffix))
+		}
 	}
-	out, err := CreateVirtualDevice(o.name, id, caps)
+	out, err := CreateVirtualDevice(o.name, id, caps, append(vopts, o.virtual...)...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if err := src.Grab(); err != nil {
-		out.Close()
-		return nil, err
+	return out, leds, nil
+}
+
+// moveAbs adds the targets of moves missing from abs, each with the range of
+// the axis it is moved from. A move from an axis abs lacks adds nothing.
+func moveAbs(abs []AbsAxis, moves []absMove) []AbsAxis {
+	for _, m := range moves {
+		if slices.ContainsFunc(abs, func(a AbsAxis) bool { return a.Code == m.to }) {
+			continue
+		}
+		if i := slices.IndexFunc(abs, func(a AbsAxis) bool { return a.Code == m.from }); i >= 0 {
+			abs = append(abs, AbsAxis{Code: m.to, AbsInfo: abs[i].AbsInfo})
+		}
 	}
-	return &Remapper{src: src, out: out, fn: fn}, nil
+	return abs
 }
 
-// Output returns the virtual device that events are emitted through, for callers
-// that want to inject additional events directly (e.g. a timed macro driven from
-// another goroutine).
-func (r *Remapper) Output() *VirtualDevice { return r.out }
+// dedupe sorts codes and drops repeats.
+func dedupe[T cmp.Ordered](codes []T) []T {
+	slices.Sort(codes)
+	return slices.Compact(codes)
+}
 
-// Run reads, transforms, and re-emits events until the source returns io.EOF
-// (returning nil) or another error. It blocks, so run it in its own goroutine if
-// the caller needs to do other work; a slow MapFunc backpressures the source. To
-// stop a running Run, Close the source device so its ReadOne unblocks.
-func (r *Remapper) Run() error {
+// forwardLEDs copies EV_LED feedback from the virtual device to the sources
+// with LEDs until the virtual device is closed or a write fails.
+func (r *Remapper) forwardLEDs() {
+	defer close(r.feedbackDone)
 	for {
-		ev, err := r.src.ReadOne()
+		ev, err := r.out.ReadFeedback()
 		if err != nil {
-			if errors.Is(err, io.EOF) {
+			return
+		}
+		if ev.Type != EV_LED {
+			continue
+		}
+		for _, src := range r.leds {
+			if writeLEDs(src, ev) != nil {
+				return
+			}
+		}
+	}
+}
+
+// NewRemapperFrom builds a Remapper that reads from src and writes the mapped
+// events to dst, for endpoints other than a grabbed device and its virtual
+// mirror — e.g. replaying a recording into a VirtualDevice, or mapping a live
+// device into a log. Nothing is grabbed or created: the caller owns both src
+// and dst, and Close has nothing to release. Of the options, only those
+// concerning the mapping and the loop (WithMapper, WithClock, WithPanicChord,
+// WithWatchdog) apply.
+func NewRemapperFrom(src EventSource, dst EventSink, fn MapFunc, opts ...RemapOption) *Remapper {
+	var o remapOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r := &Remapper{srcs: []EventSource{src}, dst: dst}
+	r.setMapper(fn, o)
+	return r
+}
+
+// setMapper installs the mapping, clock and safeguards the options select.
+func (r *Remapper) setMapper(fn Mapper, o remapOptions) {
+	if o.mapper != nil {
+		fn = o.mapper
+	}
+	r.setMapping(fn)
+	r.chord, r.watchdog = o.panicChord, o.watchdog
+	r.clock = o.clock
+	if r.clock == nil {
+		r.clock = SystemClock
+	}
+}
+
+// setMapping installs m, forgetting what the previous mapping's presses did.
+func (r *Remapper) setMapping(m Mapper) {
+	r.mapper = m
+	r.owned = nil
+	switch m.(type) {
+	case MapFunc, SourceMapFunc:
+		// A Mapper keeps its own state; a function's releases are
+		// checked against what its presses did.
+		r.owned = map[sourceKey][]EvCode{}
+	}
+}
+
+// Output returns the virtual device that events are emitted through. It is nil
+// for a Remapper from NewRemapperFrom whose sink is not a *VirtualDevice.
+// Events written to it directly may land in the middle of a frame Run is
+// writing; a Mapper's timer callbacks emit from the loop instead.
+func (r *Remapper) Output() *VirtualDevice {
+	if r.out != nil {
+		return r.out
+	}
+	v, _ := r.dst.(*VirtualDevice)
+	return v
+}
+
+// readResult is one ReadOne result, passed from a reader goroutine of Run.
+type readResult struct {
+	src int
+	ev  InputEvent
+	err error
+}
+
+// Run reads, transforms, and re-emits events until the source returns io.EOF
+// (returning nil) or another error. With several sources, it returns nil once
+// all of them have returned io.EOF, or the first other error. It blocks, so
+// run it in its own goroutine if the caller needs to do other work; a slow
+// mapping backpressures the source. Stop ends it; so does closing the source.
+//
+// Mapped events are buffered until the source's EV_SYN ends the frame, then
+// emitted together — in a single write when the sink is a BatchSink, such as
+// the VirtualDevice NewRemapper creates. Timer callbacks scheduled by a Mapper
+// run between source events, on the same loop.
+//
+// Run tracks the keys it leaves held down on the sink. When a MapFunc or
+// SourceMapFunc maps a key's release, the keys the press emitted are released
+// too. If the mapping changed in between — a closure reading a profile, say —
+// and its release leaves them down, they are released in place of the keys the
+// mapping now names, so they are not left stuck and a key still held for
+// another reason is not released. When Run returns, it releases every key
+// still held.
+//
+// If the sink is a VirtualDevice created with WithStrictWrites, a mapped event
+// it did not register stops Run with an error naming the source event that
+// produced it.
+//
+// The panic chord (see WithPanicChord) stops Run with ErrPanicChord, and a
+// watchdog set with WithWatchdog with ErrStalled; both release the grab first.
+//
+// Each source is read from a goroutine of its own. When Run returns, it
+// interrupts the pending ReadOne of a source that has a SetReadDeadline
+// method, as *Device does, and waits for its goroutine to exit, leaving the
+// source ready for reading again. The goroutine of any other source exits once
+// its pending ReadOne returns, and the event it read, if any, is lost.
+func (r *Remapper) Run() error { return r.RunContext(context.Background()) }
+
+// errStopped is the cause of a RunContext cancelled by Stop.
+var errStopped = errors.New("evdev: remapper stopped")
+
+// RunContext is Run, also returning — with ctx.Err() — promptly once ctx is
+// done. Only one Run or RunContext may be in progress at a time.
+func (r *Remapper) RunContext(ctx context.Context) (err error) {
+	parent := ctx
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	lc := &loopContext{
+		r:      r,
+		out:    r.Output(),
+		frames: make([][]InputEvent, len(r.srcs)),
+		open:   make([]bool, len(r.srcs)),
+		calls:  make(chan func()),
+		swaps:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	r.mu.Lock()
+	if r.running != nil {
+		r.mu.Unlock()
+		return errors.New("evdev: Remapper is already running")
+	}
+	r.running, r.stop = lc, cancel
+	r.mu.Unlock()
+
+	events := make(chan readResult)
+	var interrupt []func()
+	for i, src := range r.srcs {
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			for {
+				ev, err := src.ReadOne()
+				select {
+				case events <- readResult{i, ev, err}:
+				case <-lc.done:
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+		if d, ok := src.(interface{ SetReadDeadline(time.Time) error }); ok {
+			interrupt = append(interrupt, func() {
+				if d.SetReadDeadline(time.Unix(1, 0)) == nil {
+					<-exited
+					d.SetReadDeadline(time.Time{})
+				}
+			})
+		}
+	}
+	defer func() {
+		r.mu.Lock()
+		r.running, r.stop = nil, nil
+		// A mapping set too late to swap in is used by the next Run.
+		if lc.pending != nil {
+			r.setMapping(lc.pending)
+		} else if lc.next != nil {
+			r.setMapping(lc.next)
+		}
+		r.mu.Unlock()
+	}()
+	defer func() {
+		close(lc.done)
+		for _, f := range interrupt {
+			f()
+		}
+	}()
+	defer func() { err = firstErr(err, r.releaseHeld()) }()
+
+	wd := &watchdog{r: r, d: r.watchdog}
+	defer wd.disarm()
+
+	for live := len(r.srcs); ; {
+		if wd.disarm() {
+			return ErrStalled
+		}
+		var res readResult
+		select {
+		case res = <-events:
+			wd.arm()
+		case f := <-lc.calls:
+			wd.arm()
+			if err := lc.runTimer(f); err != nil {
+				return err
+			}
+			continue
+		case <-lc.swaps:
+			wd.arm()
+			if err := lc.swap(); err != nil {
+				return err
+			}
+			continue
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), errStopped) {
 				return nil
 			}
-			return err
+			return parent.Err()
+		}
+		frame := &lc.frames[res.src]
+		lc.src = res.src
+		fm, framed := r.mapper.(FrameMapper)
+		if res.err != nil || res.ev.Type == EV_SYN {
+			if framed {
+				fm.EndFrame(lc)
+				if lc.err != nil {
+					return fmt.Errorf("evdev: mapping frame: %w", lc.err)
+				}
+			}
+		}
+		if res.err != nil || res.ev.Type == EV_SYN {
+			lc.open[res.src] = false
 		}
-		// Forward frame markers verbatim; map only real events.
-		if ev.Type == EV_SYN {
-			if err := r.out.Write(ev); err != nil {
+		if res.err != nil {
+			if err := r.flush(*frame); err != nil {
 				return err
 			}
+			*frame = nil
+			if !errors.Is(res.err, io.EOF) {
+				return res.err
+			}
+			if live--; live == 0 {
+				return nil
+			}
 			continue
 		}
-		for _, e := range r.fn(ev) {
-			if err := r.out.Write(e); err != nil {
+		// Forward frame markers verbatim; map only real events. A frame a
+		// FrameMapper emptied is dropped, marker and all.
+		if res.ev.Type == EV_SYN {
+			if len(*frame) > 0 || !framed || res.ev.Code != SYN_REPORT {
+				if err := r.flush(append(*frame, res.ev)); err != nil {
+					return err
+				}
+			}
+			*frame = (*frame)[:0]
+			if err := lc.swap(); err != nil {
 				return err
 			}
+			continue
+		}
+		if len(r.chord) > 0 && res.ev.Type == EV_KEY && lc.panicChord(res.src, res.ev) {
+			r.ungrab()
+			return ErrPanicChord
+		}
+		n := len(*frame)
+		lc.open[res.src] = true
+		r.mapper.Map(lc, res.ev)
+		if r.owned != nil && res.ev.Type == EV_KEY {
+			r.track(res.src, res.ev, frame, n)
+		}
+		if lc.err != nil {
+			return fmt.Errorf("evdev: mapping %s: %w", res.ev, lc.err)
+		}
+	}
+}
+
+// Stop makes a Run or RunContext in progress return nil, without waiting for
+// it to. The source stays open, so Run may be called again. Stop does nothing
+// if the Remapper is not running.
+func (r *Remapper) Stop() {
+	r.mu.Lock()
+	stop := r.stop
+	r.mu.Unlock()
+	if stop != nil {
+		stop(errStopped)
+	}
+}
+
+// SetMapFunc replaces the mapping with fn; see SetMapper.
+func (r *Remapper) SetMapFunc(fn MapFunc) { r.SetMapper(fn) }
+
+// SetMapper replaces the mapping with m — to switch profiles, say — without
+// stopping Run. A running Run swaps it in between frames, once no source has
+// a frame partly mapped: it first releases every key the old mapping left
+// held, and timer callbacks the old mapping scheduled no longer run. SetMapper
+// does not wait for the swap, so the mapping itself may call it, as a
+// profile-switching hotkey does; of several calls before the swap, the last
+// wins.
+func (r *Remapper) SetMapper(m Mapper) {
+	r.mu.Lock()
+	lc := r.running
+	if lc == nil {
+		r.setMapping(m)
+	} else {
+		lc.pending = m
+	}
+	r.mu.Unlock()
+	if lc != nil {
+		select {
+		case lc.swaps <- struct{}{}:
+		default: // already signalled
+		}
+	}
+}
+
+// sourceKey is a key on one of a Remapper's sources.
+type sourceKey struct {
+	src  int
+	code EvCode
+}
+
+// track records the keys a source key's press mapped to, in the frame from
+// index n on, and makes sure its release releases those. A release leaving
+// one of them down means the mapping changed since the press: its releases
+// of other keys, meant for what the press now maps to, are dropped, and the
+// keys still down are released after its own events.
+func (r *Remapper) track(src int, ev InputEvent, frame *[]InputEvent, n int) {
+	k := sourceKey{src, ev.Code}
+	switch ev.Value {
+	case 1:
+		var codes []EvCode
+		for _, e := range (*frame)[n:] {
+			if e.Type == EV_KEY && e.Value == 1 {
+				codes = append(codes, e.Code)
+			}
+		}
+		if len(codes) > 0 {
+			r.owned[k] = codes
+		} else {
+			delete(r.owned, k)
+		}
+	case 0:
+		owned, ok := r.owned[k]
+		if !ok {
+			return
+		}
+		delete(r.owned, k)
+		var stuck []EvCode
+		for _, code := range slices.Backward(owned) {
+			if r.isDown(code, *frame) {
+				stuck = append(stuck, code)
+			}
+		}
+		if len(stuck) == 0 {
+			return
+		}
+		out := (*frame)[:n]
+		var pressed []EvCode // by the release itself
+		for _, e := range (*frame)[n:] {
+			switch {
+			case e.Type == EV_KEY && e.Value == 1:
+				pressed = append(pressed, e.Code)
+			case e.Type == EV_KEY && e.Value == 0:
+				if !slices.Contains(pressed, e.Code) && !slices.Contains(owned, e.Code) {
+					continue
+				}
+			case e.Type == EV_SYN && e.Code == SYN_REPORT:
+				if len(out) == n || out[len(out)-1].Type == EV_SYN {
+					continue // its frame is empty now
+				}
+			}
+			out = append(out, e)
+		}
+		for _, code := range stuck {
+			out = append(out, InputEvent{Type: EV_KEY, Code: code})
+		}
+		*frame = out
+	}
+}
+
+// isDown reports whether key code is down once frame, not yet written, is.
+func (r *Remapper) isDown(code EvCode, frame []InputEvent) bool {
+	for _, e := range slices.Backward(frame) {
+		if e.Type == EV_KEY && e.Code == code {
+			return e.Value != 0
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.down[code]
+}
+
+// flush emits a buffered frame.
+func (r *Remapper) flush(frame []InputEvent) error {
+	if len(frame) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.write(frame); err != nil {
+		return err
+	}
+	for _, e := range frame {
+		if e.Type != EV_KEY {
+			continue
+		}
+		if e.Value == 0 {
+			delete(r.down, e.Code)
+			continue
 		}
+		if r.down == nil {
+			r.down = map[EvCode]bool{}
+		}
+		r.down[e.Code] = true
 	}
+	return nil
 }
 
-// Close releases the source grab and destroys the virtual device. It does not
-// close the source device, which the caller owns. It is safe to call more than
-// once.
+// releaseHeld writes a release for every key left down, as one frame.
+func (r *Remapper) releaseHeld() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.down) == 0 {
+		return nil
+	}
+	var frame []InputEvent
+	for _, code := range slices.Sorted(maps.Keys(r.down)) {
+		frame = append(frame, InputEvent{Type: EV_KEY, Code: code})
+	}
+	clear(r.down)
+	return r.write(append(frame, InputEvent{Type: EV_SYN, Code: SYN_REPORT}))
+}
+
+// write writes frame to dst, with r.mu held.
+func (r *Remapper) write(frame []InputEvent) error {
+	if b, ok := r.dst.(BatchSink); ok {
+		return b.WriteEvents(frame)
+	}
+	for _, ev := range frame {
+		if err := r.dst.Write(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases any keys a running Run holds down, then the source grabs, and
+// destroys the virtual device. It does not close the source devices, which the
+// caller owns. It is safe to call more than once, and is a no-op for a
+// Remapper from NewRemapperFrom.
 func (r *Remapper) Close() error {
 	r.closeOnce.Do(func() {
-		r.closeErr = firstErr(r.src.Ungrab(), r.out.Close())
+		if r.out == nil {
+			return
+		}
+		r.closeErr = firstErr(r.releaseHeld(), r.ungrab(), r.out.Close())
+		if r.feedbackDone != nil {
+			<-r.feedbackDone // closing out ended its ReadFeedback
+		}
 	})
 	return r.closeErr
 }
 
+// ungrab releases the source grabs, once: on Close, or earlier for the panic
+// chord or the watchdog.
+func (r *Remapper) ungrab() error {
+	r.ungrabOnce.Do(func() {
+		var errs []error
+		for _, src := range r.grabbed {
+			errs = append(errs, src.Ungrab())
+		}
+		r.ungrabErr = firstErr(errs...)
+	})
+	return r.ungrabErr
+}
+
 // mergeCaps returns the union of two capability sets.
 func mergeCaps(a, b Capabilities) Capabilities {
 	return Capabilities{
 		Keys:  append(a.Keys, b.Keys...),
 		Rels:  append(a.Rels, b.Rels...),
+		Abs:   append(a.Abs, b.Abs...),
 		Mscs:  append(a.Mscs, b.Mscs...),
+		Sws:   append(a.Sws, b.Sws...),
+		Leds:  append(a.Leds, b.Leds...),
+		Snds:  append(a.Snds, b.Snds...),
+		FFs:   append(a.FFs, b.FFs...),
 		Props: append(a.Props, b.Props...),
+
+		Repeat:    a.Repeat || b.Repeat,
+		FFEffects: max(a.FFEffects, b.FFEffects),
 	}
 }
@@ -1,6 +1,15 @@
 package evdev
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestRemapOptions(t *testing.T) {
 	o := remapOptions{name: "go-evdev remapper"}
@@ -8,6 +17,10 @@ func TestRemapOptions(t *testing.T) {
 		WithName("swapper"),
 		WithExtraKeys(KEY_C, KEY_LEFTCTRL),
 		WithExtraCapabilities(Capabilities{Rels: []EvCode{REL_X}}),
+		WithVirtualOptions(WithPhys("remap0")),
+		WithPhysSuffix("/remap"),
+		WithPanicChord(KEY_LEFTALT, KEY_SYSRQ),
+		WithWatchdog(time.Second),
 	} {
 		opt(&o)
 	}
@@ -21,6 +34,19 @@ func TestRemapOptions(t *testing.T) {
 	if len(o.extra.Rels) != 1 || o.extra.Rels[0] != REL_X {
 		t.Errorf("extra rels = %v, want [REL_X]", o.extra.Rels)
 	}
+	if o.physSuffix != "/remap" {
+		t.Errorf("physSuffix = %q, want /remap", o.physSuffix)
+	}
+	if !slices.Equal(o.panicChord, []EvCode{KEY_LEFTALT, KEY_SYSRQ}) || o.watchdog != time.Second {
+		t.Errorf("panicChord, watchdog = %v, %v, want [KEY_LEFTALT KEY_SYSRQ], 1s", o.panicChord, o.watchdog)
+	}
+	var v virtualOptions
+	for _, opt := range o.virtual {
+		opt(&v)
+	}
+	if v.phys != "remap0" {
+		t.Errorf("virtual phys = %q, want remap0", v.phys)
+	}
 }
 
 func TestMergeCaps(t *testing.T) {
@@ -38,3 +64,515 @@ func TestMergeCaps(t *testing.T) {
 		t.Errorf("merged props = %v, want [INPUT_PROP_POINTER]", m.Props)
 	}
 }
+
+// Device and VirtualDevice are the canonical source and sink; a Device is
+// also a sink for the LED feedback sent back to it.
+var (
+	_ EventSource = (*Device)(nil)
+	_ EventSink   = (*VirtualDevice)(nil)
+	_ EventSink   = (*Device)(nil)
+	_ BatchSink   = (*VirtualDevice)(nil)
+	_ BatchSink   = (*Encoder)(nil)
+)
+
+// sliceSource is an EventSource replaying a fixed list of events, then io.EOF.
+type sliceSource struct{ evs []InputEvent }
+
+func (s *sliceSource) ReadOne() (InputEvent, error) {
+	if len(s.evs) == 0 {
+		return InputEvent{}, io.EOF
+	}
+	ev := s.evs[0]
+	s.evs = s.evs[1:]
+	return ev, nil
+}
+
+// sliceSink is an EventSink recording everything written to it.
+type sliceSink struct{ evs []InputEvent }
+
+func (s *sliceSink) Write(ev InputEvent) error {
+	s.evs = append(s.evs, ev)
+	return nil
+}
+
+// TestRemapperFrom runs the mapping loop between fakes: SYN frames pass
+// through untouched, mapped events are rewritten, dropped events vanish, and
+// a key left down is released when the source ends.
+func TestRemapperFrom(t *testing.T) {
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	src := &sliceSource{evs: []InputEvent{
+		{Type: EV_KEY, Code: KEY_CAPSLOCK, Value: 1}, syn,
+		{Type: EV_KEY, Code: KEY_A, Value: 1}, syn,
+	}}
+	dst := &sliceSink{}
+	fn := func(ev InputEvent) []InputEvent {
+		switch ev.Code {
+		case KEY_CAPSLOCK:
+			ev.Code = KEY_ESC
+		case KEY_A:
+			return nil
+		}
+		return []InputEvent{ev}
+	}
+
+	r := NewRemapperFrom(src, dst, fn)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if r.Output() != nil {
+		t.Errorf("Output() = %v, want nil for a non-virtual sink", r.Output())
+	}
+
+	want := []InputEvent{{Type: EV_KEY, Code: KEY_ESC, Value: 1}, syn, syn, {Type: EV_KEY, Code: KEY_ESC}, syn}
+	if !slices.Equal(dst.evs, want) {
+		t.Errorf("emitted %v, want %v", dst.evs, want)
+	}
+}
+
+// batchSink is a BatchSink recording each WriteEvents call separately.
+type batchSink struct {
+	sliceSink
+	batches [][]InputEvent
+}
+
+func (s *batchSink) WriteEvents(evs []InputEvent) error {
+	s.batches = append(s.batches, slices.Clone(evs))
+	return nil
+}
+
+// TestRemapperBatches checks that a BatchSink receives each mapped frame,
+// SYN_REPORT included, in one call, and that a frame cut short by the end of
+// the source is still delivered.
+func TestRemapperBatches(t *testing.T) {
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	x := InputEvent{Type: EV_REL, Code: REL_X, Value: 3}
+	y := InputEvent{Type: EV_REL, Code: REL_Y, Value: -1}
+	src := &sliceSource{evs: []InputEvent{x, y, syn, syn, x}}
+	dst := &batchSink{}
+	double := func(ev InputEvent) []InputEvent { return []InputEvent{ev, ev} }
+
+	if err := NewRemapperFrom(src, dst, double).Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := [][]InputEvent{{x, x, y, y, syn}, {syn}, {x, x}}
+	if !slices.EqualFunc(dst.batches, want, slices.Equal) {
+		t.Errorf("batches = %v, want %v", dst.batches, want)
+	}
+	if len(dst.evs) != 0 {
+		t.Errorf("Write called with %v, want only WriteEvents", dst.evs)
+	}
+}
+
+// TestRemapperStrict checks that Run reports a mapped event the strict
+// virtual device did not register, naming the source event behind it.
+func TestRemapperStrict(t *testing.T) {
+	v, _ := recordingDevice(t)
+	v.strict = true
+	v.register(Capabilities{Keys: []EvCode{KEY_CAPSLOCK}})
+	src := &sliceSource{evs: []InputEvent{{Type: EV_KEY, Code: KEY_CAPSLOCK, Value: 1}}}
+	toCtrl := func(ev InputEvent) []InputEvent {
+		ev.Code = KEY_LEFTCTRL
+		return []InputEvent{ev}
+	}
+
+	err := NewRemapperFrom(src, v, toCtrl).Run()
+	if !errors.Is(err, ErrNotRegistered) || !strings.Contains(err.Error(), "mapping EV_KEY KEY_CAPSLOCK 1") {
+		t.Errorf("Run error = %v, want ErrNotRegistered for KEY_CAPSLOCK's mapping", err)
+	}
+}
+
+// chanSource is an EventSource fed from a channel, ending with io.EOF when
+// it is closed.
+type chanSource chan InputEvent
+
+func (c chanSource) ReadOne() (InputEvent, error) {
+	ev, ok := <-c
+	if !ok {
+		return InputEvent{}, io.EOF
+	}
+	return ev, nil
+}
+
+// chanSink is a BatchSink passing each batch to a channel.
+type chanSink chan []InputEvent
+
+func (c chanSink) Write(ev InputEvent) error { return c.WriteEvents([]InputEvent{ev}) }
+
+func (c chanSink) WriteEvents(evs []InputEvent) error {
+	c <- slices.Clone(evs)
+	return nil
+}
+
+// autoRelease is a Mapper that releases each key it presses 50ms later, or
+// when the key is pressed again.
+type autoRelease struct{ timers map[EvCode]Timer }
+
+func (a *autoRelease) Map(ctx MapContext, ev InputEvent) {
+	if ev.Type != EV_KEY || ev.Value != 1 {
+		return
+	}
+	if t := a.timers[ev.Code]; t != nil && t.Stop() {
+		ctx.Emit(InputEvent{Type: EV_KEY, Code: ev.Code}, InputEvent{Type: EV_SYN, Code: SYN_REPORT})
+	}
+	ctx.Emit(ev)
+	a.timers[ev.Code] = ctx.AfterFunc(50*time.Millisecond, func() {
+		ctx.Emit(InputEvent{Type: EV_KEY, Code: ev.Code})
+	})
+}
+
+// TestRemapperMapper runs a timer-driven Mapper on a FakeClock: its callbacks
+// are written as frames of their own, and a stopped timer never fires.
+func TestRemapperMapper(t *testing.T) {
+	src, dst := make(chanSource), make(chanSink)
+	clock := NewFakeClock(time.Unix(0, 0))
+	r := NewRemapperFrom(src, dst, nil, WithMapper(&autoRelease{timers: map[EvCode]Timer{}}), WithClock(clock))
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	down, up := keyEv(KEY_A, 1), keyEv(KEY_A, 0)
+	expect := func(want ...InputEvent) {
+		t.Helper()
+		if got := <-dst; !slices.Equal(got, want) {
+			t.Errorf("wrote %v, want %v", got, want)
+		}
+	}
+
+	src <- down
+	src <- syn
+	expect(down, syn)
+	clock.Advance(50 * time.Millisecond)
+	expect(up, syn)
+
+	src <- down
+	src <- syn
+	expect(down, syn)
+	clock.Advance(30 * time.Millisecond)
+	src <- up // ignored by the mapper
+	src <- down
+	src <- syn
+	expect(up, syn, down, syn)
+	clock.Advance(30 * time.Millisecond) // the first timer was stopped
+	clock.Advance(20 * time.Millisecond)
+	expect(up, syn)
+
+	close(src)
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+// TestRemapperSources runs the loop over two sources, a keyboard and a pedal
+// acting as Shift: the mapping is told where each event came from, and each
+// source's frames are written as they end, not mixed with the other's.
+func TestRemapperSources(t *testing.T) {
+	kbd, pedal, dst := make(chanSource), make(chanSource), make(chanSink)
+	r := &Remapper{srcs: []EventSource{kbd, pedal}, dst: dst}
+	r.setMapper(SourceMapFunc(func(src int, ev InputEvent) []InputEvent {
+		if src == 1 {
+			ev.Code = KEY_LEFTSHIFT
+		}
+		return []InputEvent{ev}
+	}), remapOptions{})
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	expect := func(want ...InputEvent) {
+		t.Helper()
+		if got := <-dst; !slices.Equal(got, want) {
+			t.Errorf("wrote %v, want %v", got, want)
+		}
+	}
+
+	kbd <- keyEv(KEY_A, 1)
+	pedal <- keyEv(BTN_0, 1)
+	pedal <- syn
+	expect(keyEv(KEY_LEFTSHIFT, 1), syn)
+	kbd <- syn
+	expect(keyEv(KEY_A, 1), syn)
+
+	close(pedal)
+	kbd <- keyEv(KEY_A, 0)
+	kbd <- syn
+	expect(keyEv(KEY_A, 0), syn)
+	close(kbd)
+	expect(keyEv(KEY_LEFTSHIFT, 0), syn) // still held when the sources ended
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+// TestRemapperHeldKeys checks that a release goes to the keys its press
+// mapped to, whatever the mapping says by then.
+func TestRemapperHeldKeys(t *testing.T) {
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	src := &sliceSource{evs: []InputEvent{
+		keyEv(KEY_A, 1), syn, // mapped to B
+		keyEv(KEY_C, 1), syn, // A now mapped to C
+		keyEv(KEY_A, 0), syn, // releases B, not the C held
+		keyEv(KEY_C, 0), syn,
+		keyEv(KEY_F1, 1), syn, // a combo, released as mapped
+		keyEv(KEY_F1, 0), syn,
+		keyEv(KEY_F2, 1), syn, // taps X, then Y on release
+		keyEv(KEY_F2, 0), syn,
+		keyEv(KEY_F3, 1), syn, // Shift, released before tapping B
+		keyEv(KEY_F3, 0), syn,
+	}}
+	dst := &sliceSink{}
+	to := KEY_B
+	r := NewRemapperFrom(src, dst, func(ev InputEvent) []InputEvent {
+		switch ev.Code {
+		case KEY_A:
+			ev.Code = to
+		case KEY_C:
+			to = KEY_C
+		case KEY_F1:
+			return comboEvents([]EvCode{KEY_LEFTCTRL, KEY_X}, ev.Value)
+		case KEY_F2:
+			tap := map[int32]EvCode{1: KEY_X, 0: KEY_Y}[ev.Value]
+			return []InputEvent{keyEv(tap, 1), syn, keyEv(tap, 0)}
+		case KEY_F3:
+			if ev.Value == 1 {
+				return []InputEvent{keyEv(KEY_LEFTSHIFT, 1)}
+			}
+			return []InputEvent{keyEv(KEY_LEFTSHIFT, 0), keyEv(KEY_B, 1), syn, keyEv(KEY_B, 0)}
+		}
+		return []InputEvent{ev}
+	})
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []InputEvent{
+		keyEv(KEY_B, 1), syn,
+		keyEv(KEY_C, 1), syn,
+		keyEv(KEY_B, 0), syn,
+		keyEv(KEY_C, 0), syn,
+		keyEv(KEY_LEFTCTRL, 1), syn, keyEv(KEY_X, 1), syn,
+		keyEv(KEY_X, 0), syn, keyEv(KEY_LEFTCTRL, 0), syn,
+		keyEv(KEY_X, 1), syn, keyEv(KEY_X, 0), syn,
+		keyEv(KEY_Y, 1), syn, keyEv(KEY_Y, 0), syn,
+		keyEv(KEY_LEFTSHIFT, 1), syn,
+		keyEv(KEY_LEFTSHIFT, 0), keyEv(KEY_B, 1), syn, keyEv(KEY_B, 0), syn,
+	}
+	if !slices.Equal(dst.evs, want) {
+		t.Errorf("emitted %v, want %v", dst.evs, want)
+	}
+}
+
+// TestRemapperStop stops Run on a source read through a pipe, which, like an
+// event node, supports read deadlines: Run returns without the source being
+// closed, and a second Run picks up where the first left off.
+func TestRemapperStop(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	src := &Device{f: pr, path: "pipe"}
+	defer src.Close()
+	dst := make(chanSink)
+	r := NewRemapperFrom(src, dst, func(ev InputEvent) []InputEvent { return []InputEvent{ev} })
+
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	send := func(evs ...InputEvent) {
+		t.Helper()
+		buf := make([]byte, len(evs)*sizeofInputEvent)
+		for i, ev := range evs {
+			NativeLayout.Put(buf[i*sizeofInputEvent:], ev)
+		}
+		if _, err := pw.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(ctx context.Context) chan error {
+		done := make(chan error, 1)
+		go func() { done <- r.RunContext(ctx) }()
+		return done
+	}
+	x := InputEvent{Type: EV_REL, Code: REL_X, Value: 1}
+
+	done := run(context.Background())
+	send(x, syn)
+	if got := <-dst; !slices.Equal(got, []InputEvent{x, syn}) {
+		t.Errorf("wrote %v, want %v", got, []InputEvent{x, syn})
+	}
+	r.Stop()
+	if err := <-done; err != nil {
+		t.Errorf("Run after Stop: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done = run(ctx)
+	send(x, syn)
+	if got := <-dst; !slices.Equal(got, []InputEvent{x, syn}) {
+		t.Errorf("second Run wrote %v, want %v", got, []InputEvent{x, syn})
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run after cancel = %v, want context.Canceled", err)
+	}
+}
+
+// TestRemapperSetMapFunc swaps the mapping while a key it pressed is held:
+// the key is released at the swap, and the new mapping takes over.
+func TestRemapperSetMapFunc(t *testing.T) {
+	src, dst := make(chanSource), make(chanSink)
+	to := func(code EvCode) MapFunc {
+		return func(ev InputEvent) []InputEvent {
+			ev.Code = code
+			return []InputEvent{ev}
+		}
+	}
+	r := NewRemapperFrom(src, dst, to(KEY_B))
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	expect := func(want ...InputEvent) {
+		t.Helper()
+		if got := <-dst; !slices.Equal(got, want) {
+			t.Errorf("wrote %v, want %v", got, want)
+		}
+	}
+
+	src <- keyEv(KEY_A, 1)
+	src <- syn
+	expect(keyEv(KEY_B, 1), syn)
+	r.SetMapFunc(to(KEY_C))
+	expect(keyEv(KEY_B, 0), syn)
+	src <- keyEv(KEY_A, 0)
+	src <- keyEv(KEY_A, 1)
+	src <- syn
+	expect(keyEv(KEY_C, 0), keyEv(KEY_C, 1), syn)
+
+	close(src)
+	expect(keyEv(KEY_C, 0), syn)
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+// TestRemapperSetMapperPipeline swaps out a Pipeline, which holds each frame
+// back until it ends, in the middle of a frame: the swap waits for the frame.
+func TestRemapperSetMapperPipeline(t *testing.T) {
+	src, dst := make(chanSource), make(chanSink)
+	r := NewRemapperFrom(src, dst, nil, WithPipeline())
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	scan := InputEvent{Type: EV_MSC, Code: MSC_SCAN, Value: 30}
+	src <- keyEv(KEY_A, 1)
+	src <- scan // read once Run has taken KEY_A
+	r.SetMapFunc(func(ev InputEvent) []InputEvent { return nil })
+	src <- syn
+	for _, want := range [][]InputEvent{
+		{keyEv(KEY_A, 1), scan, syn},
+		{keyEv(KEY_A, 0), syn}, // released by the swap
+	} {
+		if got := <-dst; !slices.Equal(got, want) {
+			t.Errorf("wrote %v, want %v", got, want)
+		}
+	}
+	close(src)
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+// TestRemapperSetMapFuncFromMap switches profiles with a hotkey: the mapping
+// calls SetMapFunc itself, and the new one takes over after the frame.
+func TestRemapperSetMapFuncFromMap(t *testing.T) {
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	src := &sliceSource{evs: []InputEvent{
+		keyEv(KEY_A, 1), keyEv(KEY_A, 0), syn,
+		keyEv(KEY_F1, 1), syn,
+		keyEv(KEY_A, 1), keyEv(KEY_A, 0), syn,
+	}}
+	dst := &sliceSink{}
+	var r *Remapper
+	r = NewRemapperFrom(src, dst, func(ev InputEvent) []InputEvent {
+		if ev.Code == KEY_F1 {
+			r.SetMapFunc(func(ev InputEvent) []InputEvent {
+				ev.Code = KEY_B
+				return []InputEvent{ev}
+			})
+			return nil
+		}
+		return []InputEvent{ev}
+	})
+	done := make(chan error, 1)
+	go func() { done <- r.Run() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run deadlocked on SetMapFunc from the mapping")
+	}
+	want := []InputEvent{
+		keyEv(KEY_A, 1), keyEv(KEY_A, 0), syn,
+		syn,
+		keyEv(KEY_B, 1), keyEv(KEY_B, 0), syn,
+	}
+	if !slices.Equal(dst.evs, want) {
+		t.Errorf("emitted %v, want %v", dst.evs, want)
+	}
+}
+
+// TestRemapperPanicChord checks the chord is seen on source events whatever
+// the mapping does — here, swallowing every key.
+func TestRemapperPanicChord(t *testing.T) {
+	syn := InputEvent{Type: EV_SYN, Code: SYN_REPORT}
+	src := &sliceSource{evs: []InputEvent{
+		keyEv(KEY_LEFTCTRL, 1), syn,
+		keyEv(KEY_ESC, 1), syn,
+		keyEv(KEY_ESC, 0), syn,
+		keyEv(KEY_BACKSPACE, 1), syn,
+		keyEv(KEY_ESC, 1), syn,
+		keyEv(KEY_ENTER, 1), syn,
+		keyEv(KEY_A, 1), syn,
+	}}
+	dst := &sliceSink{}
+	r := NewRemapperFrom(src, dst, func(ev InputEvent) []InputEvent {
+		if ev.Code == KEY_LEFTCTRL {
+			return []InputEvent{ev}
+		}
+		return nil
+	}, WithPanicChord(DefaultPanicChord...))
+	if err := r.Run(); !errors.Is(err, ErrPanicChord) {
+		t.Fatalf("Run = %v, want ErrPanicChord", err)
+	}
+	want := []InputEvent{keyEv(KEY_LEFTCTRL, 1), syn, syn, syn, syn, syn, keyEv(KEY_LEFTCTRL, 0), syn}
+	if !slices.Equal(dst.evs, want) {
+		t.Errorf("emitted %v, want %v", dst.evs, want)
+	}
+}
+
+// TestRemapperWatchdog stalls the mapping past the watchdog's timeout: Run
+// reports it once the mapping returns, without writing the stalled frame.
+func TestRemapperWatchdog(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	src, dst := make(chanSource), make(chanSink)
+	mapping, unblock := make(chan struct{}), make(chan struct{})
+	r := NewRemapperFrom(src, dst, func(ev InputEvent) []InputEvent {
+		close(mapping)
+		<-unblock
+		return []InputEvent{ev}
+	}, WithClock(clock), WithWatchdog(time.Second))
+	done := make(chan error)
+	go func() { done <- r.Run() }()
+
+	src <- keyEv(KEY_A, 1)
+	<-mapping
+	clock.Advance(time.Second)
+	close(unblock)
+	if err := <-done; !errors.Is(err, ErrStalled) {
+		t.Errorf("Run = %v, want ErrStalled", err)
+	}
+}
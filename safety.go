@@ -0,0 +1,94 @@
+package evdev
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPanicChord is a panic chord to pass to WithPanicChord: Backspace,
+// Escape and Enter held together, which normal typing rarely produces.
+var DefaultPanicChord = []EvCode{KEY_BACKSPACE, KEY_ESC, KEY_ENTER}
+
+// ErrPanicChord is returned by Run when the panic chord was pressed.
+var ErrPanicChord = errors.New("evdev: panic chord pressed")
+
+// ErrStalled is returned by Run when the watchdog found the mapping loop
+// stalled, once the loop resumes.
+var ErrStalled = errors.New("evdev: mapping loop stalled")
+
+// WithPanicChord sets the panic chord: keys which, held down together on the
+// source, make the Remapper release its grab and Run return ErrPanicChord — a
+// way out when a broken mapping swallows every key. The chord is checked on
+// the source's own events, before the mapping sees them, so it works whatever
+// the mapping does. There is no chord unless this option sets one, and with
+// no keys it turns the chord off.
+//
+//	r, err := evdev.NewRemapper(src, fn, evdev.WithPanicChord(evdev.DefaultPanicChord...))
+func WithPanicChord(keys ...EvCode) RemapOption {
+	return func(o *remapOptions) { o.panicChord = slices.Clone(keys) }
+}
+
+// WithWatchdog makes the Remapper release its grab if mapping one event —
+// including writing the result — takes longer than d, as a mapping stuck in a
+// loop or deadlock would, so the source's keys reach the system again. Run
+// returns ErrStalled if the loop ever resumes. The watchdog runs on the
+// Remapper's clock.
+func WithWatchdog(d time.Duration) RemapOption {
+	return func(o *remapOptions) { o.watchdog = d }
+}
+
+// panicChord reports whether ev, on source src, completes the panic chord,
+// keeping track of the source keys held down.
+func (c *loopContext) panicChord(src int, ev InputEvent) bool {
+	k := sourceKey{src, ev.Code}
+	if ev.Value == 0 {
+		delete(c.raw, k)
+		return false
+	}
+	if c.raw == nil {
+		c.raw = map[sourceKey]bool{}
+	}
+	c.raw[k] = true
+	if ev.Value != 1 {
+		return false
+	}
+	for _, code := range c.r.chord {
+		held := false
+		for k := range c.raw {
+			held = held || k.code == code
+		}
+		if !held {
+			return false
+		}
+	}
+	return true
+}
+
+// watchdog ungrabs a Remapper's sources when an event takes too long to map.
+type watchdog struct {
+	r       *Remapper
+	d       time.Duration
+	t       Timer // armed while an event is handled; loop only
+	stalled atomic.Bool
+}
+
+// arm starts timing the handling of one event.
+func (w *watchdog) arm() {
+	if w.d > 0 {
+		w.t = w.r.clock.AfterFunc(w.d, func() {
+			w.stalled.Store(true)
+			w.r.ungrab()
+		})
+	}
+}
+
+// disarm stops timing, reporting whether the loop was found stalled.
+func (w *watchdog) disarm() bool {
+	if w.t != nil {
+		w.t.Stop()
+		w.t = nil
+	}
+	return w.stalled.Load()
+}
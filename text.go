@@ -0,0 +1,232 @@
+package evdev
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParseEvent parses the text form produced by InputEvent.String — "TYPE CODE
+// value", e.g. "EV_KEY KEY_A 1" — back into an event. The value may be omitted
+// for EV_SYN events, as String omits it. Types and codes accept the same forms
+// as ParseType and ParseCode. The timestamp is left zero.
+func ParseEvent(s string) (InputEvent, error) {
+	f := strings.Fields(s)
+	if len(f) < 2 || len(f) > 3 {
+		return InputEvent{}, fmt.Errorf("evdev: malformed event %q: want \"TYPE CODE [value]\"", s)
+	}
+	t, err := ParseType(f[0])
+	if err != nil {
+		return InputEvent{}, err
+	}
+	c, err := ParseCode(t, f[1])
+	if err != nil {
+		return InputEvent{}, err
+	}
+	ev := InputEvent{Type: t, Code: c}
+	switch {
+	case len(f) == 3:
+		v, err := strconv.ParseInt(f[2], 0, 32)
+		if err != nil {
+			return InputEvent{}, fmt.Errorf("evdev: malformed event value %q: %w", f[2], err)
+		}
+		ev.Value = int32(v)
+	case t != EV_SYN:
+		return InputEvent{}, fmt.Errorf("evdev: malformed event %q: missing value", s)
+	}
+	return ev, nil
+}
+
+// ParseType parses an event type: an EV_* name ("EV_KEY"), the numeric
+// fallback String produces for unknown types ("EV_?(0x1f)"), or a bare number.
+func ParseType(s string) (EvType, error) {
+	if t, ok := evTypeByName[s]; ok {
+		return t, nil
+	}
+	if n, ok := parseNumeric(s, "EV"); ok {
+		return EvType(n), nil
+	}
+	return 0, fmt.Errorf("evdev: unknown event type %q", s)
+}
+
+// ParseCode parses a code within the namespace of event type t, so names are
+// only accepted for their own type: ParseCode(EV_KEY, "BTN_LEFT") succeeds but
+// ParseCode(EV_ABS, "KEY_A") fails, although KEY_A and ABS_ codes share numbers.
+// It accepts any name or alias of the code, the numeric fallback CodeName
+// produces for unknown codes ("KEY_?(0x1ff)"), or a bare number.
+func ParseCode(t EvType, s string) (EvCode, error) {
+	if c, ok := evCodeByName[s]; ok && typeOfCodeName(s) == t {
+		return c, nil
+	}
+	if n, ok := parseNumeric(s, codePrefixForType(t)); ok {
+		return EvCode(n), nil
+	}
+	return 0, fmt.Errorf("evdev: unknown %s code %q", t, s)
+}
+
+// CodeAliases returns every name for code c in the namespace of event type t,
+// canonical name (the one CodeName returns) first and the rest sorted — e.g.
+// CodeAliases(EV_KEY, BTN_LEFT) is ["BTN_MOUSE", "BTN_LEFT"]. It returns nil
+// for a code with no name.
+func CodeAliases(t EvType, c EvCode) []string {
+	names := codeAliases()[t][c]
+	if len(names) == 0 {
+		return nil
+	}
+	return slices.Clone(names)
+}
+
+// codeAliases indexes evCodeByName by type and code, canonical name first.
+var codeAliases = sync.OnceValue(func() map[EvType]map[EvCode][]string {
+	idx := map[EvType]map[EvCode][]string{}
+	for name, c := range evCodeByName {
+		t := typeOfCodeName(name)
+		if idx[t] == nil {
+			idx[t] = map[EvCode][]string{}
+		}
+		idx[t][c] = append(idx[t][c], name)
+	}
+	for t, codes := range idx {
+		for c, names := range codes {
+			canonical := evCodeNames[t][c]
+			slices.SortFunc(names, func(a, b string) int {
+				switch {
+				case a == canonical:
+					return -1
+				case b == canonical:
+					return 1
+				}
+				return strings.Compare(a, b)
+			})
+		}
+	}
+	return idx
+})
+
+// typeOfCodeName returns the event type whose namespace a code name belongs
+// to, judged by its prefix ("BTN_LEFT" -> EV_KEY), or EV_MAX if none matches.
+func typeOfCodeName(name string) EvType {
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return EV_MAX
+	}
+	if prefix == "BTN" {
+		return EV_KEY
+	}
+	for t := range evCodeNames {
+		if codePrefixForType(t) == prefix {
+			return t
+		}
+	}
+	return EV_MAX
+}
+
+// parseNumeric parses the "PREFIX_?(0x1f)" fallback form produced for unnamed
+// values, or a bare decimal or 0x-prefixed number.
+func parseNumeric(s, prefix string) (uint16, bool) {
+	if inner, ok := strings.CutPrefix(s, prefix+"_?("); ok {
+		s, ok = strings.CutSuffix(inner, ")")
+		if !ok {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseUint(s, 0, 16)
+	return uint16(n), err == nil
+}
+
+// TypedCode is an event code together with the type whose namespace it
+// belongs to, so it can be written and read back as a bare name: its text form
+// is the code's name ("KEY_A", "REL_X"), and parsing infers the type from the
+// name's prefix. It is the form to use for codes in configuration files.
+type TypedCode struct {
+	Type EvType
+	Code EvCode
+}
+
+// ParseTypedCode parses a code name, inferring its type from the prefix
+// ("BTN_LEFT" -> EV_KEY). Like ParseCode it accepts aliases and the numeric
+// fallback form ("ABS_?(0x3e)"); a bare number is rejected, as it names no type.
+func ParseTypedCode(s string) (TypedCode, error) {
+	t := typeOfCodeName(s)
+	if t == EV_MAX {
+		return TypedCode{}, fmt.Errorf("evdev: unknown code %q", s)
+	}
+	c, err := ParseCode(t, s)
+	if err != nil {
+		return TypedCode{}, err
+	}
+	return TypedCode{Type: t, Code: c}, nil
+}
+
+// String returns the code's name within its type, as CodeName does.
+func (tc TypedCode) String() string { return CodeName(tc.Type, tc.Code) }
+
+// MarshalText implements encoding.TextMarshaler.
+func (tc TypedCode) MarshalText() ([]byte, error) { return []byte(tc.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseTypedCode.
+func (tc *TypedCode) UnmarshalText(b []byte) error {
+	v, err := ParseTypedCode(string(b))
+	if err != nil {
+		return err
+	}
+	*tc = v
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, producing the String form.
+func (t EvType) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseType.
+func (t *EvType) UnmarshalText(b []byte) error {
+	v, err := ParseType(string(b))
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, producing the String form.
+func (b BusType) MarshalText() ([]byte, error) { return []byte(b.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts a BUS_* name,
+// the "BUS_?(0x..)" fallback, or a bare number.
+func (b *BusType) UnmarshalText(text []byte) error {
+	s := string(text)
+	for v, name := range busNames {
+		if name == s {
+			*b = v
+			return nil
+		}
+	}
+	n, ok := parseNumeric(s, "BUS")
+	if !ok {
+		return fmt.Errorf("evdev: unknown bus type %q", s)
+	}
+	*b = BusType(n)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, producing the String form.
+func (p InputProp) MarshalText() ([]byte, error) { return []byte(p.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts an
+// INPUT_PROP_* name, the "INPUT_PROP_?(0x..)" fallback, or a bare number.
+func (p *InputProp) UnmarshalText(text []byte) error {
+	s := string(text)
+	for v, name := range propNames {
+		if name == s {
+			*p = v
+			return nil
+		}
+	}
+	n, ok := parseNumeric(s, "INPUT_PROP")
+	if !ok {
+		return fmt.Errorf("evdev: unknown input property %q", s)
+	}
+	*p = InputProp(n)
+	return nil
+}
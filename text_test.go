@@ -0,0 +1,121 @@
+package evdev
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// TestParseEvent checks ParseEvent inverts InputEvent.String, including the
+// EV_SYN form without a value and unknown-code fallbacks.
+func TestParseEvent(t *testing.T) {
+	for _, ev := range []InputEvent{
+		{Type: EV_KEY, Code: KEY_A, Value: 1},
+		{Type: EV_SYN, Code: SYN_REPORT},
+		{Type: EV_REL, Code: REL_WHEEL, Value: -1},
+		{Type: EV_ABS, Code: ABS_MT_POSITION_X, Value: 1024},
+		{Type: EV_KEY, Code: 0x2fe, Value: 0},
+		{Type: 0x1e, Code: 7, Value: 3},
+	} {
+		s := ev.String()
+		got, err := ParseEvent(s)
+		if err != nil {
+			t.Errorf("ParseEvent(%q): %v", s, err)
+			continue
+		}
+		if got != ev {
+			t.Errorf("ParseEvent(%q) = %+v, want %+v", s, got, ev)
+		}
+	}
+
+	for _, s := range []string{"", "EV_KEY", "EV_KEY KEY_A", "EV_KEY REL_X 1", "EV_KEY KEY_A one", "EV_KEY KEY_A 1 2"} {
+		if _, err := ParseEvent(s); err == nil {
+			t.Errorf("ParseEvent(%q) succeeded, want error", s)
+		}
+	}
+}
+
+// TestParseCode checks codes are scoped to their type and that numeric forms
+// are accepted.
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		t    EvType
+		s    string
+		want EvCode
+		ok   bool
+	}{
+		{EV_KEY, "KEY_A", KEY_A, true},
+		{EV_KEY, "BTN_LEFT", BTN_LEFT, true},
+		{EV_KEY, "BTN_MOUSE", BTN_LEFT, true},
+		{EV_ABS, "ABS_X", ABS_X, true},
+		{EV_ABS, "KEY_A", 0, false},
+		{EV_REL, "ABS_X", 0, false},
+		{EV_KEY, "KEY_?(0x1ff)", 0x1ff, true},
+		{EV_KEY, "REL_?(0x1)", 0, false},
+		{EV_KEY, "0x1e", KEY_A, true},
+		{EV_KEY, "30", KEY_A, true},
+		{EV_KEY, "KEY_NOPE", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseCode(tt.t, tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseCode(%s, %q) = %v, %v; want %v, ok=%v", tt.t, tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCodeAliases(t *testing.T) {
+	if got, want := CodeAliases(EV_KEY, BTN_LEFT), []string{"BTN_MOUSE", "BTN_LEFT"}; !slices.Equal(got, want) {
+		t.Errorf("CodeAliases(EV_KEY, BTN_LEFT) = %v, want %v", got, want)
+	}
+	if got, want := CodeAliases(EV_KEY, KEY_A), []string{"KEY_A"}; !slices.Equal(got, want) {
+		t.Errorf("CodeAliases(EV_KEY, KEY_A) = %v, want %v", got, want)
+	}
+	// REL_X and ABS_X are both 0 but live in separate namespaces.
+	if got, want := CodeAliases(EV_REL, REL_X), []string{"REL_X"}; !slices.Equal(got, want) {
+		t.Errorf("CodeAliases(EV_REL, REL_X) = %v, want %v", got, want)
+	}
+	if got := CodeAliases(EV_KEY, 0x2fe); got != nil {
+		t.Errorf("CodeAliases(EV_KEY, 0x2fe) = %v, want nil", got)
+	}
+}
+
+// TestTextMarshaling round-trips the text-marshaled types through JSON.
+func TestTextMarshaling(t *testing.T) {
+	type doc struct {
+		Type  EvType
+		Bus   BusType
+		Prop  InputProp
+		Codes []TypedCode
+	}
+	in := doc{
+		Type:  EV_ABS,
+		Bus:   BUS_BLUETOOTH,
+		Prop:  INPUT_PROP_DIRECT,
+		Codes: []TypedCode{{EV_KEY, KEY_A}, {EV_KEY, BTN_LEFT}, {EV_ABS, ABS_X}, {EV_KEY, 0x2fe}},
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"Type":"EV_ABS","Bus":"BUS_BLUETOOTH","Prop":"INPUT_PROP_DIRECT",` +
+		`"Codes":["KEY_A","BTN_MOUSE","ABS_X","KEY_?(0x2fe)"]}`
+	if string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+
+	var out doc
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Type != in.Type || out.Bus != in.Bus || out.Prop != in.Prop || !slices.Equal(out.Codes, in.Codes) {
+		t.Errorf("Unmarshal = %+v, want %+v", out, in)
+	}
+
+	for _, s := range []string{`"30"`, `"BTN_?(0x1)"`, `"NOPE_X"`, `"EV_KEY"`} {
+		var tc TypedCode
+		if err := json.Unmarshal([]byte(s), &tc); err == nil {
+			t.Errorf("Unmarshal TypedCode %s succeeded, want error", s)
+		}
+	}
+}
@@ -1,16 +1,30 @@
 package evdev
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
 // uinputPath is the kernel's uinput control device, used to create virtual
-// input devices that inject events as if from real hardware.
-const uinputPath = "/dev/uinput"
+// input devices that inject events as if from real hardware. Some systems
+// create it as uinputAltPath instead.
+const (
+	uinputPath    = "/dev/uinput"
+	uinputAltPath = "/dev/input/uinput"
+)
 
 // uinputMaxNameSize matches UINPUT_MAX_NAME_SIZE in <linux/uinput.h>.
 const uinputMaxNameSize = 80
@@ -22,72 +36,281 @@ type uinputSetup struct {
 	FFEffectsMax uint32
 }
 
+// uinputUserDev mirrors struct uinput_user_dev, which kernels before 4.5
+// (uinput version 5) take written to the control file in place of UI_DEV_SETUP
+// and UI_ABS_SETUP.
+type uinputUserDev struct {
+	Name         [uinputMaxNameSize]byte
+	ID           InputID
+	FFEffectsMax uint32
+	Absmax       [ABS_CNT]int32
+	Absmin       [ABS_CNT]int32
+	Absfuzz      [ABS_CNT]int32
+	Absflat      [ABS_CNT]int32
+}
+
+// uinputAbsSetup mirrors struct uinput_abs_setup, the argument to UI_ABS_SETUP.
+type uinputAbsSetup struct {
+	Code uint16
+	_    uint16
+	Info AbsInfo
+}
+
 // uinput request builders (type byte 'U'); see <linux/uinput.h>.
 const uinputType = 'U'
 
 func uiDevCreate() uintptr  { return io0(uinputType, 1) }
 func uiDevDestroy() uintptr { return io0(uinputType, 2) }
 func uiDevSetup() uintptr   { return iow(uinputType, 3, unsafe.Sizeof(uinputSetup{})) }
+func uiAbsSetup() uintptr   { return iow(uinputType, 4, unsafe.Sizeof(uinputAbsSetup{})) }
 
 func uiSetEvbit() uintptr   { return iow(uinputType, 100, unsafe.Sizeof(int32(0))) }
 func uiSetKeybit() uintptr  { return iow(uinputType, 101, unsafe.Sizeof(int32(0))) }
 func uiSetRelbit() uintptr  { return iow(uinputType, 102, unsafe.Sizeof(int32(0))) }
+func uiSetAbsbit() uintptr  { return iow(uinputType, 103, unsafe.Sizeof(int32(0))) }
 func uiSetMscbit() uintptr  { return iow(uinputType, 104, unsafe.Sizeof(int32(0))) }
+func uiSetLedbit() uintptr  { return iow(uinputType, 105, unsafe.Sizeof(int32(0))) }
+func uiSetSndbit() uintptr  { return iow(uinputType, 106, unsafe.Sizeof(int32(0))) }
+func uiSetFfbit() uintptr   { return iow(uinputType, 107, unsafe.Sizeof(int32(0))) }
+func uiSetSwbit() uintptr   { return iow(uinputType, 109, unsafe.Sizeof(int32(0))) }
 func uiSetPropbit() uintptr { return iow(uinputType, 110, unsafe.Sizeof(int32(0))) }
 
+// UI_SET_PHYS and UI_SET_UNIQ take a pointer to a NUL-terminated string, and
+// their encoded size is that of the pointer, not the string.
+func uiSetPhys() uintptr { return iow(uinputType, 108, unsafe.Sizeof(uintptr(0))) }
+func uiSetUniq() uintptr { return iow(uinputType, 111, unsafe.Sizeof(uintptr(0))) }
+
+// uiGetSysname builds UI_GET_SYSNAME, which reads the device's sysfs name
+// ("input23") into a buffer of the given length.
+func uiGetSysname(length uintptr) uintptr { return ioc(iocRead, uinputType, 44, length) }
+func uiGetVersion() uintptr               { return ior(uinputType, 45, unsafe.Sizeof(uint32(0))) }
+
+// uinputSysDir is the sysfs directory holding uinput-created input devices.
+const uinputSysDir = "/sys/devices/virtual/input"
+
+// eventNodePoll is how often EventPath checks for the device node.
+const eventNodePoll = 10 * time.Millisecond
+
+// Force-feedback requests arrive on the uinput file as EV_UINPUT events whose
+// value is the request id; see <linux/uinput.h>.
+const (
+	evUinput   EvType = 0x0101
+	uiFFUpload EvCode = 1
+	uiFFErase  EvCode = 2
+)
+
+// sizeofFFEffect is sizeof(struct ff_effect): a 16-byte header, then a union
+// whose largest member (ff_periodic_effect) ends in a pointer.
+const sizeofFFEffect = 40 + nativeWordSize
+
+// uinputFFUpload mirrors struct uinput_ff_upload. The effects are carried
+// opaquely: uploads are acknowledged without being interpreted.
+type uinputFFUpload struct {
+	RequestID uint32
+	Retval    int32
+	Effect    [sizeofFFEffect]byte
+	Old       [sizeofFFEffect]byte
+}
+
+// uinputFFErase mirrors struct uinput_ff_erase.
+type uinputFFErase struct {
+	RequestID uint32
+	Retval    int32
+	EffectID  uint32
+}
+
+func uiBeginFFUpload() uintptr {
+	return ioc(iocRead|iocWrite, uinputType, 200, unsafe.Sizeof(uinputFFUpload{}))
+}
+func uiEndFFUpload() uintptr { return iow(uinputType, 201, unsafe.Sizeof(uinputFFUpload{})) }
+func uiBeginFFErase() uintptr {
+	return ioc(iocRead|iocWrite, uinputType, 202, unsafe.Sizeof(uinputFFErase{}))
+}
+func uiEndFFErase() uintptr { return iow(uinputType, 203, unsafe.Sizeof(uinputFFErase{})) }
+
+// defaultFFEffects is the effect slot count used when Capabilities enables
+// force feedback without setting FFEffects; it matches the kernel's memoryless
+// force-feedback devices.
+const defaultFFEffects = 16
+
 // Capabilities describes what a VirtualDevice can emit. Enable the event types
-// and codes you intend to write: the kernel drops events whose code was not
-// registered before the device was created. CapabilitiesOf copies these from a
-// real device.
+// and codes you intend to write: the kernel silently drops events whose code
+// was not registered before the device was created, unless WithStrictWrites
+// turns them into errors. CapabilitiesOf copies these from a real device.
 //
-// EV_ABS axes (joysticks, touch) are not yet supported; they need per-axis
-// ranges via UI_ABS_SETUP.
+// The presets (NewVirtualKeyboard, NewVirtualMouse, ...) fill these in for
+// common kinds of device.
 type Capabilities struct {
 	Keys  []EvCode    // EV_KEY codes (keyboard keys and BTN_* buttons)
 	Rels  []EvCode    // EV_REL codes (relative axes: REL_X, REL_WHEEL, ...)
+	Abs   []AbsAxis   // EV_ABS axes with their ranges (ABS_X, ABS_MT_SLOT, ...)
 	Mscs  []EvCode    // EV_MSC codes (e.g. MSC_SCAN)
+	Sws   []EvCode    // EV_SW codes (switches: SW_LID, SW_TABLET_MODE, ...)
+	Leds  []EvCode    // EV_LED codes (LED_CAPSL, LED_NUML, ...)
+	Snds  []EvCode    // EV_SND codes (SND_BELL, SND_CLICK, ...)
+	FFs   []EvCode    // EV_FF codes (force-feedback effects: FF_RUMBLE, FF_GAIN, ...)
 	Props []InputProp // device properties (INPUT_PROP_*)
+
+	// Repeat enables EV_REP: the kernel then autorepeats held keys itself,
+	// emitting value-2 events, so a writer must not also send its own repeats.
+	Repeat bool
+
+	// FFEffects is how many force-feedback effects the device can hold at
+	// once. The kernel requires it with FFs; zero means 16.
+	FFEffects int
 }
 
 // VirtualDevice is a uinput-backed input device. Events written to it are
-// injected into the system as if produced by real hardware. Close destroys it.
+// injected into the system as if produced by real hardware, and events the
+// system sends to it — LED state, bells, force-feedback playback — can be read
+// back with ReadFeedback. Close destroys it.
 type VirtualDevice struct {
 	f *os.File
+
+	// caps is what was registered at creation, and registered indexes it for
+	// Registered. strict makes writes check it.
+	caps       Capabilities
+	registered map[typeCode]struct{}
+	strict     bool
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
+// typeCode is an event type and code pair.
+type typeCode struct {
+	t EvType
+	c EvCode
+}
+
+// ErrNotRegistered is wrapped by the error a VirtualDevice created with
+// WithStrictWrites returns for an event whose type or code it did not
+// register.
+var ErrNotRegistered = errors.New("event not registered on virtual device")
+
+// ErrUniqUnsupported is wrapped by the error CreateVirtualDevice returns when
+// WithUniq is given and the kernel has no UI_SET_UNIQ.
+var ErrUniqUnsupported = errors.New("kernel cannot set a virtual device's uniq")
+
 // CapabilitiesOf reads a real device's capabilities so a VirtualDevice can
 // mirror it — the basis for a remapper that grabs a source device and re-emits
-// a transformed stream. EV_ABS axes are not copied (see Capabilities).
+// a transformed stream.
 func CapabilitiesOf(d *Device) (Capabilities, error) {
 	var caps Capabilities
-	var err error
-	if caps.Keys, err = d.CapableCodes(EV_KEY); err != nil {
-		return Capabilities{}, err
+	for _, c := range []struct {
+		t     EvType
+		codes *[]EvCode
+	}{
+		{EV_KEY, &caps.Keys},
+		{EV_REL, &caps.Rels},
+		{EV_MSC, &caps.Mscs},
+		{EV_SW, &caps.Sws},
+		{EV_LED, &caps.Leds},
+		{EV_SND, &caps.Snds},
+		{EV_FF, &caps.FFs},
+	} {
+		codes, err := d.CapableCodes(c.t)
+		if err != nil {
+			return Capabilities{}, err
+		}
+		*c.codes = codes
 	}
-	if caps.Rels, err = d.CapableCodes(EV_REL); err != nil {
+	abs, err := d.CapableCodes(EV_ABS)
+	if err != nil {
 		return Capabilities{}, err
 	}
-	if caps.Mscs, err = d.CapableCodes(EV_MSC); err != nil {
-		return Capabilities{}, err
+	for _, c := range abs {
+		info, err := d.AbsInfo(c)
+		if err != nil {
+			return Capabilities{}, err
+		}
+		caps.Abs = append(caps.Abs, AbsAxis{Code: c, AbsInfo: info})
 	}
 	if caps.Props, err = d.CapableProps(); err != nil {
 		return Capabilities{}, err
 	}
+	types, err := d.CapableTypes()
+	if err != nil {
+		return Capabilities{}, err
+	}
+	caps.Repeat = slices.Contains(types, EV_REP)
+	if len(caps.FFs) > 0 {
+		if caps.FFEffects, err = d.FFEffects(); err != nil {
+			return Capabilities{}, err
+		}
+	}
 	return caps, nil
 }
 
+// VirtualOption configures a VirtualDevice at creation.
+type VirtualOption func(*virtualOptions)
+
+type virtualOptions struct {
+	phys, uniq string
+	path       string
+	file       *os.File
+	strict     bool
+}
+
+// WithPhys sets the virtual device's physical topology path (UI_SET_PHYS), the
+// string Device.Phys reports and udev exposes as ATTRS{phys}. Without it the
+// path is empty, so a distinct phys such as "go-evdev/remap0" is how udev
+// rules and libinput quirks tell one virtual device from another.
+func WithPhys(phys string) VirtualOption {
+	return func(o *virtualOptions) { o.phys = phys }
+}
+
+// WithUniq sets the virtual device's unique identifier (UI_SET_UNIQ). Mainline
+// kernels do not provide that ioctl; where the kernel rejects it,
+// CreateVirtualDevice fails with an error wrapping ErrUniqUnsupported, and the
+// device can be created again without it.
+func WithUniq(uniq string) VirtualOption {
+	return func(o *virtualOptions) { o.uniq = uniq }
+}
+
+// WithStrictWrites makes the device's writes fail with an error wrapping
+// ErrNotRegistered, naming the event and the Capabilities field it belongs in,
+// instead of letting the kernel silently drop an event that was not
+// registered. Nothing is written from a batch containing such an event.
+func WithStrictWrites() VirtualOption {
+	return func(o *virtualOptions) { o.strict = true }
+}
+
+// WithUinputPath opens the uinput control device at path instead of trying
+// /dev/uinput and then /dev/input/uinput.
+func WithUinputPath(path string) VirtualOption {
+	return func(o *virtualOptions) { o.path = path }
+}
+
+// WithUinputFile creates the device through f, an already-open uinput control
+// file, e.g. one passed down by a privileged parent process. CreateVirtualDevice
+// takes ownership of f: it is closed if creation fails, and by Close. Open it
+// read-write and non-blocking for ReadFeedback, and for Close to interrupt it.
+func WithUinputFile(f *os.File) VirtualOption {
+	return func(o *virtualOptions) { o.file = f }
+}
+
 // CreateVirtualDevice creates and registers a uinput device with the given name,
 // identity, and capabilities. The returned device is live; write events with
 // WriteEvent/Write and flush each batch with Sync. The caller must Close it.
 //
 // Requires write access to /dev/uinput (root, or membership in a group with
-// access plus a udev rule).
-func CreateVirtualDevice(name string, id InputID, caps Capabilities) (*VirtualDevice, error) {
-	f, err := os.OpenFile(uinputPath, os.O_WRONLY|unix.O_NONBLOCK, 0)
+// access plus a udev rule); /dev/input/uinput is tried if /dev/uinput does not
+// exist. On kernels older than 4.5, which lack UI_DEV_SETUP, the device is set
+// up through the legacy uinput_user_dev interface, which cannot set axis
+// resolutions.
+func CreateVirtualDevice(name string, id InputID, caps Capabilities, opts ...VirtualOption) (*VirtualDevice, error) {
+	var o virtualOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	f, err := openUinput(o)
 	if err != nil {
-		return nil, fmt.Errorf("evdev: open %s: %w", uinputPath, err)
+		return nil, err
 	}
-	v := &VirtualDevice{f: f}
+	v := &VirtualDevice{f: f, strict: o.strict}
+	v.register(caps)
 
 	// The kernel requires every event type and code be registered before the
 	// device is created.
@@ -95,25 +318,190 @@ func CreateVirtualDevice(name string, id InputID, caps Capabilities) (*VirtualDe
 		f.Close()
 		return nil, err
 	}
-
-	setup := uinputSetup{ID: id}
-	copyCName(setup.Name[:], name)
-	if err := ioctl(f.Fd(), uiDevSetup(), unsafe.Pointer(&setup)); err != nil {
+	if o.phys != "" {
+		if err := v.setString(uiSetPhys(), o.phys); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("evdev: UI_SET_PHYS: %w", err)
+		}
+	}
+	if o.uniq != "" {
+		if err := v.setString(uiSetUniq(), o.uniq); err != nil {
+			f.Close()
+			if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOTTY) {
+				err = ErrUniqUnsupported
+			}
+			return nil, fmt.Errorf("evdev: UI_SET_UNIQ: %w", err)
+		}
+	}
+	if err := v.setup(name, id, caps); err != nil {
 		f.Close()
-		return nil, fmt.Errorf("evdev: UI_DEV_SETUP: %w", err)
+		return nil, err
 	}
-	if err := ioctl(f.Fd(), uiDevCreate(), nil); err != nil {
+	if err := v.control(func(fd uintptr) error { return ioctl(fd, uiDevCreate(), nil) }); err != nil {
 		f.Close()
 		return nil, fmt.Errorf("evdev: UI_DEV_CREATE: %w", err)
 	}
 	return v, nil
 }
 
+// register records caps as the device's registered capabilities.
+func (v *VirtualDevice) register(caps Capabilities) {
+	v.caps = caps
+	v.caps = v.Capabilities() // a copy, as the caller may reuse caps
+	if len(caps.FFs) > 0 {
+		v.caps.FFEffects = cmp.Or(caps.FFEffects, defaultFFEffects)
+	}
+	v.registered = map[typeCode]struct{}{}
+	add := func(t EvType, codes []EvCode) {
+		for _, c := range codes {
+			v.registered[typeCode{t, c}] = struct{}{}
+		}
+	}
+	add(EV_KEY, caps.Keys)
+	add(EV_REL, caps.Rels)
+	for _, a := range caps.Abs {
+		add(EV_ABS, []EvCode{a.Code})
+	}
+	add(EV_MSC, caps.Mscs)
+	add(EV_SW, caps.Sws)
+	add(EV_LED, caps.Leds)
+	add(EV_SND, caps.Snds)
+	add(EV_FF, caps.FFs)
+	if caps.Repeat {
+		add(EV_REP, []EvCode{REP_DELAY, REP_PERIOD})
+	}
+}
+
+// Capabilities returns the capabilities the device was created with. The
+// result is a copy.
+func (v *VirtualDevice) Capabilities() Capabilities {
+	c := v.caps
+	c.Keys, c.Rels, c.Abs = slices.Clone(c.Keys), slices.Clone(c.Rels), slices.Clone(c.Abs)
+	c.Mscs, c.Sws, c.Leds = slices.Clone(c.Mscs), slices.Clone(c.Sws), slices.Clone(c.Leds)
+	c.Snds, c.FFs, c.Props = slices.Clone(c.Snds), slices.Clone(c.FFs), slices.Clone(c.Props)
+	return c
+}
+
+// Registered reports whether the device can emit events of type t and code c.
+// EV_SYN is always registered.
+func (v *VirtualDevice) Registered(t EvType, c EvCode) bool {
+	if t == EV_SYN {
+		return true
+	}
+	_, ok := v.registered[typeCode{t, c}]
+	return ok
+}
+
+// capsField names the Capabilities field that registers each event type.
+var capsField = map[EvType]string{
+	EV_KEY: "Keys", EV_REL: "Rels", EV_ABS: "Abs", EV_MSC: "Mscs", EV_SW: "Sws",
+	EV_LED: "Leds", EV_SND: "Snds", EV_FF: "FFs", EV_REP: "Repeat",
+}
+
+// unregistered returns an error describing ev if the device is strict and did
+// not register it, or nil.
+func (v *VirtualDevice) unregistered(ev InputEvent) error {
+	if !v.strict || v.Registered(ev.Type, ev.Code) {
+		return nil
+	}
+	if field, ok := capsField[ev.Type]; ok {
+		return fmt.Errorf("%s: %w (add it to Capabilities.%s)", ev, ErrNotRegistered, field)
+	}
+	return fmt.Errorf("%s: %w", ev, ErrNotRegistered)
+}
+
+// openUinput opens the uinput control file the options select.
+func openUinput(o virtualOptions) (*os.File, error) {
+	if o.file != nil {
+		return o.file, nil
+	}
+	paths := []string{uinputPath, uinputAltPath}
+	if o.path != "" {
+		paths = []string{o.path}
+	}
+	var first error
+	for _, p := range paths {
+		// Read-write, so ReadFeedback can read what the kernel sends back; and
+		// non-blocking, so that read goes through the runtime poller and Close
+		// interrupts it.
+		f, err := os.OpenFile(p, os.O_RDWR|unix.O_NONBLOCK, 0)
+		if err == nil {
+			return f, nil
+		}
+		if first == nil {
+			first = err
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			// The node exists but cannot be opened; report that rather
+			// than the absence of an alternative.
+			break
+		}
+	}
+	return nil, fmt.Errorf("evdev: open uinput: %w", first)
+}
+
+// setup gives the device its name, identity and axis ranges, with UI_DEV_SETUP
+// and UI_ABS_SETUP where the kernel has them and the legacy uinput_user_dev
+// write where UI_DEV_SETUP fails with EINVAL.
+func (v *VirtualDevice) setup(name string, id InputID, caps Capabilities) error {
+	setup := uinputSetup{ID: id}
+	copyCName(setup.Name[:], name)
+	if len(caps.FFs) > 0 {
+		setup.FFEffectsMax = uint32(cmp.Or(caps.FFEffects, defaultFFEffects))
+	}
+	err := v.control(func(fd uintptr) error { return ioctl(fd, uiDevSetup(), unsafe.Pointer(&setup)) })
+	if errors.Is(err, unix.EINVAL) {
+		return v.setupLegacy(setup, caps.Abs)
+	}
+	if err != nil {
+		return fmt.Errorf("evdev: UI_DEV_SETUP: %w", err)
+	}
+	for _, a := range caps.Abs {
+		abs := uinputAbsSetup{Code: uint16(a.Code), Info: a.AbsInfo}
+		if err := v.control(func(fd uintptr) error { return ioctl(fd, uiAbsSetup(), unsafe.Pointer(&abs)) }); err != nil {
+			return fmt.Errorf("evdev: UI_ABS_SETUP %s: %w", CodeName(EV_ABS, a.Code), err)
+		}
+	}
+	return nil
+}
+
+// setupLegacy writes the setup as a struct uinput_user_dev.
+func (v *VirtualDevice) setupLegacy(setup uinputSetup, axes []AbsAxis) error {
+	dev := legacyUserDev(setup, axes)
+	b := unsafe.Slice((*byte)(unsafe.Pointer(&dev)), unsafe.Sizeof(dev))
+	if _, err := v.f.Write(b); err != nil {
+		return fmt.Errorf("evdev: write uinput_user_dev: %w", err)
+	}
+	return nil
+}
+
+// legacyUserDev converts a setup and axis ranges to a uinput_user_dev.
+func legacyUserDev(setup uinputSetup, axes []AbsAxis) uinputUserDev {
+	dev := uinputUserDev{Name: setup.Name, ID: setup.ID, FFEffectsMax: setup.FFEffectsMax}
+	for _, a := range axes {
+		if a.Code >= ABS_CNT {
+			continue // rejected by UI_SET_ABSBIT already
+		}
+		dev.Absmin[a.Code] = a.Minimum
+		dev.Absmax[a.Code] = a.Maximum
+		dev.Absfuzz[a.Code] = a.Fuzz
+		dev.Absflat[a.Code] = a.Flat
+	}
+	return dev
+}
+
+// setString passes s to a uinput ioctl taking a C string.
+func (v *VirtualDevice) setString(req uintptr, s string) error {
+	b := append([]byte(s), 0)
+	return v.control(func(fd uintptr) error { return ioctl(fd, req, unsafe.Pointer(&b[0])) })
+}
+
 // enable registers each capability bit with the kernel via the UI_SET_* ioctls,
 // which take the type/code as a scalar argument.
 func (v *VirtualDevice) enable(caps Capabilities) error {
-	fd := int(v.f.Fd())
-	set := func(req uintptr, val int) error { return unix.IoctlSetInt(fd, uint(req), val) }
+	set := func(req uintptr, val int) error {
+		return v.control(func(fd uintptr) error { return unix.IoctlSetInt(int(fd), uint(req), val) })
+	}
 
 	enableType := func(t EvType, setCode uintptr, codes []EvCode) error {
 		if len(codes) == 0 {
@@ -139,6 +527,26 @@ func (v *VirtualDevice) enable(caps Capabilities) error {
 	if err := enableType(EV_MSC, uiSetMscbit(), caps.Mscs); err != nil {
 		return err
 	}
+	if err := v.enableAbs(caps.Abs); err != nil {
+		return err
+	}
+	if err := enableType(EV_SW, uiSetSwbit(), caps.Sws); err != nil {
+		return err
+	}
+	if err := enableType(EV_LED, uiSetLedbit(), caps.Leds); err != nil {
+		return err
+	}
+	if err := enableType(EV_SND, uiSetSndbit(), caps.Snds); err != nil {
+		return err
+	}
+	if err := enableType(EV_FF, uiSetFfbit(), caps.FFs); err != nil {
+		return err
+	}
+	if caps.Repeat {
+		if err := set(uiSetEvbit(), int(EV_REP)); err != nil {
+			return fmt.Errorf("evdev: UI_SET_EVBIT %s: %w", EV_REP, err)
+		}
+	}
 	for _, p := range caps.Props {
 		if err := set(uiSetPropbit(), int(p)); err != nil {
 			return fmt.Errorf("evdev: UI_SET_PROPBIT %s: %w", p, err)
@@ -147,6 +555,135 @@ func (v *VirtualDevice) enable(caps Capabilities) error {
 	return nil
 }
 
+// enableAbs registers absolute axes; setup gives them their ranges.
+func (v *VirtualDevice) enableAbs(axes []AbsAxis) error {
+	if len(axes) == 0 {
+		return nil
+	}
+	if err := v.control(func(fd uintptr) error { return unix.IoctlSetInt(int(fd), uint(uiSetEvbit()), int(EV_ABS)) }); err != nil {
+		return fmt.Errorf("evdev: UI_SET_EVBIT %s: %w", EV_ABS, err)
+	}
+	for _, a := range axes {
+		if err := v.control(func(fd uintptr) error { return unix.IoctlSetInt(int(fd), uint(uiSetAbsbit()), int(a.Code)) }); err != nil {
+			return fmt.Errorf("evdev: enable %s: %w", CodeName(EV_ABS, a.Code), err)
+		}
+	}
+	return nil
+}
+
+// UinputVersion returns the kernel's uinput interface version (UI_GET_VERSION).
+// SysPath needs version 3 or later (Linux 3.15); before version 5 (Linux 4.5)
+// CreateVirtualDevice falls back to the legacy setup.
+func (v *VirtualDevice) UinputVersion() (int, error) {
+	var ver uint32
+	if err := v.control(func(fd uintptr) error { return ioctl(fd, uiGetVersion(), unsafe.Pointer(&ver)) }); err != nil {
+		return 0, fmt.Errorf("evdev: UI_GET_VERSION: %w", err)
+	}
+	return int(ver), nil
+}
+
+// SysPath returns the device's sysfs directory, e.g.
+// /sys/devices/virtual/input/input23 (UI_GET_SYSNAME). Its event* entry names
+// the evdev node; see EventPath.
+func (v *VirtualDevice) SysPath() (string, error) {
+	buf := make([]byte, 64)
+	var n int
+	err := v.control(func(fd uintptr) error {
+		var e error
+		n, e = ioctlBuf(fd, uiGetSysname(uintptr(len(buf))), buf)
+		return e
+	})
+	if err != nil {
+		return "", fmt.Errorf("evdev: UI_GET_SYSNAME: %w", err)
+	}
+	return filepath.Join(uinputSysDir, string(bytes.TrimRight(buf[:n], "\x00"))), nil
+}
+
+// EventPath returns the device's evdev node, e.g. /dev/input/event7, which
+// Open accepts — to read back what the device emits in a test, or to query it
+// like any other device. The node is created asynchronously after
+// CreateVirtualDevice returns, so EventPath waits for it to appear, giving up
+// when ctx is done.
+func (v *VirtualDevice) EventPath(ctx context.Context) (string, error) {
+	sys, err := v.SysPath()
+	if err != nil {
+		return "", err
+	}
+	tick := time.NewTicker(eventNodePoll)
+	defer tick.Stop()
+	for {
+		if matches, _ := filepath.Glob(filepath.Join(sys, "event*")); len(matches) > 0 {
+			path := filepath.Join(devDir, filepath.Base(matches[0]))
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("evdev: wait for event node of %s: %w", sys, ctx.Err())
+		case <-tick.C:
+		}
+	}
+}
+
+// ReadFeedback blocks until the system sends the device an event and returns
+// it: EV_LED when an application changes a keyboard LED (the Caps Lock light),
+// EV_SND for a bell or click, EV_REP when the repeat rate is set, and EV_FF when
+// a force-feedback effect is played (Code is the effect id, Value the play
+// count, 0 to stop). The kernel only sends events the device registered in its
+// Capabilities.
+//
+// Force-feedback effect uploads and erasures are accepted automatically while
+// ReadFeedback runs; a device with FFs must therefore have its feedback read,
+// or applications uploading effects stall until the kernel times them out.
+//
+// Close unblocks a pending ReadFeedback, which then returns an error wrapping
+// os.ErrClosed. It is safe to call concurrently with Write.
+func (v *VirtualDevice) ReadFeedback() (InputEvent, error) {
+	var rec [sizeofInputEvent]byte
+	for {
+		if _, err := io.ReadFull(v.f, rec[:]); err != nil {
+			return InputEvent{}, fmt.Errorf("evdev: read feedback: %w", err)
+		}
+		ev := NativeLayout.Decode(rec[:])
+		if ev.Type != evUinput {
+			return ev, nil
+		}
+		if err := v.ackFF(ev); err != nil {
+			return InputEvent{}, err
+		}
+	}
+}
+
+// ackFF accepts the force-feedback upload or erase request ev announces.
+func (v *VirtualDevice) ackFF(ev InputEvent) error {
+	switch ev.Code {
+	case uiFFUpload:
+		up := uinputFFUpload{RequestID: uint32(ev.Value)}
+		if err := v.control(func(fd uintptr) error {
+			if err := ioctl(fd, uiBeginFFUpload(), unsafe.Pointer(&up)); err != nil {
+				return err
+			}
+			up.Retval = 0
+			return ioctl(fd, uiEndFFUpload(), unsafe.Pointer(&up))
+		}); err != nil {
+			return fmt.Errorf("evdev: acknowledge FF upload: %w", err)
+		}
+	case uiFFErase:
+		er := uinputFFErase{RequestID: uint32(ev.Value)}
+		if err := v.control(func(fd uintptr) error {
+			if err := ioctl(fd, uiBeginFFErase(), unsafe.Pointer(&er)); err != nil {
+				return err
+			}
+			er.Retval = 0
+			return ioctl(fd, uiEndFFErase(), unsafe.Pointer(&er))
+		}); err != nil {
+			return fmt.Errorf("evdev: acknowledge FF erase: %w", err)
+		}
+	}
+	return nil
+}
+
 // WriteEvent injects a single event. Call Sync after writing a batch to deliver
 // it as one atomic packet.
 func (v *VirtualDevice) WriteEvent(t EvType, c EvCode, value int32) error {
@@ -156,32 +693,87 @@ func (v *VirtualDevice) WriteEvent(t EvType, c EvCode, value int32) error {
 // Write injects a raw event. The Time field is ignored — the kernel timestamps
 // emitted events itself.
 func (v *VirtualDevice) Write(ev InputEvent) error {
+	if err := v.unregistered(ev); err != nil {
+		return fmt.Errorf("evdev: write event: %w", err)
+	}
 	ev.Time = unix.Timeval{}
-	b := (*[sizeofInputEvent]byte)(unsafe.Pointer(&ev))[:]
-	if _, err := v.f.Write(b); err != nil {
+	var rec [sizeofInputEvent]byte
+	NativeLayout.Put(rec[:], ev)
+	if _, err := v.f.Write(rec[:]); err != nil {
 		return fmt.Errorf("evdev: write event: %w", err)
 	}
 	return nil
 }
 
+// WriteEvents injects evs with a single write, which for high-rate devices
+// costs far less than one Write per event. Time fields are ignored, as for
+// Write.
+func (v *VirtualDevice) WriteEvents(evs []InputEvent) error {
+	return v.writeEvents(evs, false)
+}
+
+// WriteFrame injects evs followed by EV_SYN/SYN_REPORT, all in a single write,
+// delivering them as one atomic packet.
+func (v *VirtualDevice) WriteFrame(evs []InputEvent) error {
+	return v.writeEvents(evs, true)
+}
+
+func (v *VirtualDevice) writeEvents(evs []InputEvent, sync bool) error {
+	for _, ev := range evs {
+		if err := v.unregistered(ev); err != nil {
+			return fmt.Errorf("evdev: write events: %w", err)
+		}
+	}
+	n := len(evs)
+	if sync {
+		n++
+	}
+	buf := make([]byte, 0, n*sizeofInputEvent)
+	for _, ev := range evs {
+		ev.Time = unix.Timeval{}
+		buf = NativeLayout.Append(buf, ev)
+	}
+	if sync {
+		buf = NativeLayout.Append(buf, InputEvent{Type: EV_SYN, Code: SYN_REPORT})
+	}
+	if _, err := v.f.Write(buf); err != nil {
+		return fmt.Errorf("evdev: write events: %w", err)
+	}
+	return nil
+}
+
 // Sync emits EV_SYN/SYN_REPORT, flushing events written since the last Sync.
 func (v *VirtualDevice) Sync() error {
 	return v.WriteEvent(EV_SYN, SYN_REPORT, 0)
 }
 
-// Close destroys the virtual device and closes the control file. It is safe to
-// call more than once.
+// Close destroys the virtual device and closes the control file, unblocking a
+// concurrent ReadFeedback. It is safe to call more than once.
 func (v *VirtualDevice) Close() error {
-	if v.f == nil {
-		return nil
+	v.closeOnce.Do(func() {
+		derr := v.control(func(fd uintptr) error { return ioctl(fd, uiDevDestroy(), nil) })
+		cerr := v.f.Close()
+		if derr != nil {
+			cerr = fmt.Errorf("evdev: UI_DEV_DESTROY: %w", derr)
+		}
+		v.closeErr = cerr
+	})
+	return v.closeErr
+}
+
+// control runs fn with the control file's descriptor without detaching it from
+// the runtime poller, as Device.control does, so a ReadFeedback blocked on the
+// file stays interruptible by Close.
+func (v *VirtualDevice) control(fn func(fd uintptr) error) error {
+	rc, err := v.f.SyscallConn()
+	if err != nil {
+		return err
 	}
-	derr := ioctl(v.f.Fd(), uiDevDestroy(), nil)
-	cerr := v.f.Close()
-	v.f = nil
-	if derr != nil {
-		return fmt.Errorf("evdev: UI_DEV_DESTROY: %w", derr)
+	var fnErr error
+	if cErr := rc.Control(func(fd uintptr) { fnErr = fn(fd) }); cErr != nil {
+		return cErr
 	}
-	return cerr
+	return fnErr
 }
 
 // copyCName copies s into a fixed C char array, guaranteeing NUL termination.
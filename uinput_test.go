@@ -1,8 +1,15 @@
 package evdev
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -20,8 +27,18 @@ func TestUinputEncoding(t *testing.T) {
 		{"UI_SET_EVBIT", uiSetEvbit(), 0x40045564},
 		{"UI_SET_KEYBIT", uiSetKeybit(), 0x40045565},
 		{"UI_SET_RELBIT", uiSetRelbit(), 0x40045566},
+		{"UI_SET_ABSBIT", uiSetAbsbit(), 0x40045567},
 		{"UI_SET_MSCBIT", uiSetMscbit(), 0x40045568},
+		{"UI_SET_LEDBIT", uiSetLedbit(), 0x40045569},
+		{"UI_SET_SNDBIT", uiSetSndbit(), 0x4004556a},
+		{"UI_SET_FFBIT", uiSetFfbit(), 0x4004556b},
+		{"UI_SET_SWBIT", uiSetSwbit(), 0x4004556d},
 		{"UI_SET_PROPBIT", uiSetPropbit(), 0x4004556e},
+		{"UI_ABS_SETUP", uiAbsSetup(), 0x401c5504},
+		{"UI_GET_SYSNAME(64)", uiGetSysname(64), 0x8040552c},
+		{"UI_GET_VERSION", uiGetVersion(), 0x8004552d},
+		{"UI_BEGIN_FF_ERASE", uiBeginFFErase(), 0xc00c55ca},
+		{"UI_END_FF_ERASE", uiEndFFErase(), 0x400c55cb},
 	}
 	for _, tt := range tests {
 		if tt.got != tt.want {
@@ -38,6 +55,83 @@ func TestUinputSetupSize(t *testing.T) {
 	}
 }
 
+// UI_SET_PHYS and UI_SET_UNIQ encode the size of a char pointer.
+func TestUinputStringIoctls(t *testing.T) {
+	size := uintptr(nativeWordSize) << iocSizeShift
+	if got, want := uiSetPhys(), 0x4000556c|size; got != want {
+		t.Errorf("UI_SET_PHYS = %#x, want %#x", got, want)
+	}
+	if got, want := uiSetUniq(), 0x4000556f|size; got != want {
+		t.Errorf("UI_SET_UNIQ = %#x, want %#x", got, want)
+	}
+}
+
+// struct uinput_user_dev is the name, input_id, ff_effects_max and four
+// ABS_CNT arrays: 80 + 8 + 4 + 4*64*4 bytes.
+func TestUinputUserDevSize(t *testing.T) {
+	if got := unsafe.Sizeof(uinputUserDev{}); got != 1116 {
+		t.Errorf("sizeof(uinputUserDev) = %d, want 1116", got)
+	}
+}
+
+func TestLegacyUserDev(t *testing.T) {
+	setup := uinputSetup{ID: InputID{BusType: BUS_USB, Vendor: 1}, FFEffectsMax: 4}
+	copyCName(setup.Name[:], "pad")
+	dev := legacyUserDev(setup, []AbsAxis{
+		{ABS_X, AbsInfo{Minimum: -10, Maximum: 10, Fuzz: 1, Flat: 2, Resolution: 5}},
+		{ABS_PRESSURE, AbsInfo{Maximum: 255}},
+	})
+	if dev.Name != setup.Name || dev.ID != setup.ID || dev.FFEffectsMax != 4 {
+		t.Errorf("identity = %q %+v %d", dev.Name[:3], dev.ID, dev.FFEffectsMax)
+	}
+	if dev.Absmin[ABS_X] != -10 || dev.Absmax[ABS_X] != 10 || dev.Absfuzz[ABS_X] != 1 || dev.Absflat[ABS_X] != 2 {
+		t.Errorf("ABS_X = %d..%d fuzz %d flat %d", dev.Absmin[ABS_X], dev.Absmax[ABS_X], dev.Absfuzz[ABS_X], dev.Absflat[ABS_X])
+	}
+	if dev.Absmax[ABS_PRESSURE] != 255 || dev.Absmax[ABS_Y] != 0 {
+		t.Errorf("Absmax[ABS_PRESSURE] = %d, Absmax[ABS_Y] = %d", dev.Absmax[ABS_PRESSURE], dev.Absmax[ABS_Y])
+	}
+}
+
+func TestOpenUinput(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "uinput")
+	if _, err := openUinput(virtualOptions{path: missing}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("openUinput(%s) error = %v, want ErrNotExist", missing, err)
+	}
+	f, err := os.CreateTemp(t.TempDir(), "uinput")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, err := openUinput(virtualOptions{path: missing, file: f}); got != f || err != nil {
+		t.Errorf("openUinput with a file = %v, %v; want that file", got, err)
+	}
+}
+
+// struct uinput_abs_setup is a __u16 code, padding, and struct input_absinfo.
+func TestUinputAbsSetupSize(t *testing.T) {
+	if got := unsafe.Sizeof(uinputAbsSetup{}); got != 28 {
+		t.Errorf("sizeof(uinputAbsSetup) = %d, want 28", got)
+	}
+}
+
+// struct uinput_ff_upload holds two struct ff_effect, whose size depends on the
+// pointer at the end of its union: 104 bytes on 64-bit ABIs, 96 on 32-bit.
+func TestUinputFFUploadSize(t *testing.T) {
+	want := uintptr(96)
+	if nativeWordSize == 8 {
+		want = 104
+	}
+	if got := unsafe.Sizeof(uinputFFUpload{}); got != want {
+		t.Errorf("sizeof(uinputFFUpload) = %d, want %d", got, want)
+	}
+	if got := uiBeginFFUpload(); got != 0xc00055c8|want<<iocSizeShift {
+		t.Errorf("UI_BEGIN_FF_UPLOAD = %#x", got)
+	}
+	if got := unsafe.Sizeof(uinputFFErase{}); got != 12 {
+		t.Errorf("sizeof(uinputFFErase) = %d, want 12", got)
+	}
+}
+
 // TestVirtualDeviceSmoke creates a real virtual keyboard, emits a keypress, and
 // tears it down. It needs write access to /dev/uinput (root), so it skips
 // otherwise — keeping the suite green for unprivileged/CI runs.
@@ -49,12 +143,35 @@ func TestVirtualDeviceSmoke(t *testing.T) {
 	f.Close()
 
 	id := InputID{BusType: BUS_USB, Vendor: 0x1234, Product: 0x5678, Version: 1}
-	v, err := CreateVirtualDevice("go-evdev test keyboard", id, Capabilities{Keys: []EvCode{KEY_A}})
+	v, err := CreateVirtualDevice("go-evdev test keyboard", id, Capabilities{Keys: []EvCode{KEY_A}},
+		WithPhys("go-evdev/test0"))
 	if err != nil {
 		t.Fatalf("CreateVirtualDevice: %v", err)
 	}
 	defer v.Close()
 
+	sys, err := v.SysPath()
+	if err != nil {
+		t.Fatalf("SysPath: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	path, err := v.EventPath(ctx)
+	if err != nil {
+		t.Fatalf("EventPath: %v", err)
+	}
+	d, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", path, err)
+	}
+	defer d.Close()
+	if name, err := d.Name(); err != nil || name != "go-evdev test keyboard" {
+		t.Errorf("%s (from %s) is named %q, %v; want the virtual device", path, sys, name, err)
+	}
+	if phys, err := d.Phys(); err != nil || phys != "go-evdev/test0" {
+		t.Errorf("Phys() = %q, %v; want go-evdev/test0", phys, err)
+	}
+
 	for _, val := range []int32{1, 0} { // press, release
 		if err := v.WriteEvent(EV_KEY, KEY_A, val); err != nil {
 			t.Fatalf("WriteEvent: %v", err)
@@ -63,4 +180,86 @@ func TestVirtualDeviceSmoke(t *testing.T) {
 			t.Fatalf("Sync: %v", err)
 		}
 	}
+
+	// Mainline kernels have no UI_SET_UNIQ, which must be reported.
+	u, err := CreateVirtualDevice("go-evdev test keyboard", id, Capabilities{Keys: []EvCode{KEY_A}}, WithUniq("test0"))
+	if err == nil {
+		u.Close()
+	} else if !errors.Is(err, ErrUniqUnsupported) {
+		t.Errorf("CreateVirtualDevice(WithUniq): %v, want nil or ErrUniqUnsupported", err)
+	}
+}
+
+// TestVirtualDeviceFeedback sets an LED on a virtual keyboard through its event
+// node, as a desktop does for Caps Lock, and reads it back with ReadFeedback.
+// It needs write access to /dev/uinput (root), so it skips otherwise.
+func TestVirtualDeviceFeedback(t *testing.T) {
+	f, err := os.OpenFile(uinputPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("cannot open %s (need root): %v", uinputPath, err)
+	}
+	f.Close()
+
+	id := InputID{BusType: BUS_USB, Vendor: 0x1234, Product: 0x5678, Version: 1}
+	caps := Capabilities{Keys: []EvCode{KEY_CAPSLOCK}, Leds: []EvCode{LED_CAPSL}}
+	v, err := CreateVirtualDevice("go-evdev feedback test", id, caps)
+	if err != nil {
+		t.Fatalf("CreateVirtualDevice: %v", err)
+	}
+	defer v.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	path, err := v.EventPath(ctx)
+	if err != nil {
+		t.Fatalf("EventPath: %v", err)
+	}
+	d, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", path, err)
+	}
+	defer d.Close()
+	if err := d.Write(InputEvent{Type: EV_LED, Code: LED_CAPSL, Value: 1}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := d.Write(InputEvent{Type: EV_SYN, Code: SYN_REPORT}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	ev, err := v.ReadFeedback()
+	if err != nil {
+		t.Fatalf("ReadFeedback: %v", err)
+	}
+	if ev.Type != EV_LED || ev.Code != LED_CAPSL || ev.Value != 1 {
+		t.Errorf("feedback = %v, want EV_LED LED_CAPSL 1", ev)
+	}
+}
+
+func TestVirtualDeviceStrictWrites(t *testing.T) {
+	v, frames := recordingDevice(t)
+	v.strict = true
+	v.register(Capabilities{Keys: []EvCode{KEY_A}, Rels: []EvCode{REL_X}, Repeat: true})
+
+	if !v.Registered(EV_KEY, KEY_A) || !v.Registered(EV_SYN, SYN_REPORT) || !v.Registered(EV_REP, REP_DELAY) {
+		t.Error("Registered reports a registered code missing")
+	}
+	if v.Registered(EV_KEY, KEY_B) || v.Registered(EV_ABS, ABS_X) {
+		t.Error("Registered reports an unregistered code present")
+	}
+	if c := v.Capabilities(); !slices.Equal(c.Keys, []EvCode{KEY_A}) || !c.Repeat {
+		t.Errorf("Capabilities() = %+v", c)
+	}
+
+	if err := v.WriteFrame([]InputEvent{keyEvent(KEY_A, 1)}); err != nil {
+		t.Fatalf("WriteFrame(KEY_A): %v", err)
+	}
+	err := v.WriteFrame([]InputEvent{keyEvent(KEY_A, 0), keyEvent(KEY_LEFTCTRL, 1)})
+	if !errors.Is(err, ErrNotRegistered) || !strings.Contains(err.Error(), "KEY_LEFTCTRL") || !strings.Contains(err.Error(), "Capabilities.Keys") {
+		t.Errorf("WriteFrame(KEY_LEFTCTRL) error = %v, want ErrNotRegistered naming the code and field", err)
+	}
+	if err := v.Write(absEvent(ABS_X, 1)); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Write(ABS_X) error = %v, want ErrNotRegistered", err)
+	}
+	// The rejected frame must not be partly written.
+	checkFrames(t, frames(), [][]InputEvent{{keyEvent(KEY_A, 1)}})
 }